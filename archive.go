@@ -0,0 +1,134 @@
+package updater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// archiveSuffixes are the release archives binaries are extracted from, such
+// as the archives of a goreleaser release.
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".zip"}
+
+// archiveSuffix returns the archive suffix of the asset name, or "" if the
+// asset is not an archive.
+func archiveSuffix(name string) string {
+	lower := strings.ToLower(name)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// walkArchive calls fn for every regular file in the archive at the path
+// archive, named name, with its mode.
+func walkArchive(archive, name string, fn func(file string, mode fs.FileMode, r io.Reader) error) error {
+	if archiveSuffix(name) == ".zip" {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			err = fn(f.Name, f.Mode(), rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr.Name, hdr.FileInfo().Mode(), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// extractArchiveBinary copies the binary from the archive at the path archive,
+// named name, to dest. The binary is the file named binary, with or without
+// .exe, or else the only executable in the archive, so READMEs and licenses
+// packed alongside it are skipped.
+func extractArchiveBinary(archive, name, binary, dest string) error {
+	var match string
+	var executables []string
+	err := walkArchive(archive, name, func(file string, mode fs.FileMode, r io.Reader) error {
+		base := path.Base(file)
+		if binary != "" && match == "" && strings.EqualFold(strings.TrimSuffix(base, ".exe"), binary) {
+			match = file
+		}
+		if mode&0111 != 0 || strings.HasSuffix(strings.ToLower(base), ".exe") {
+			executables = append(executables, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if match == "" {
+		if len(executables) != 1 {
+			return fmt.Errorf("failed to find the binary %s in %s", binary, name)
+		}
+		match = executables[0]
+	}
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", match, err)
+	}
+	defer out.Close()
+
+	found := false
+	err = walkArchive(archive, name, func(file string, mode fs.FileMode, r io.Reader) error {
+		if file != match || found {
+			return nil
+		}
+		found = true
+		if _, err := io.Copy(out, r); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", match, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
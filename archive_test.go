@@ -0,0 +1,131 @@
+package updater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// archiveFile is a file packed by makeArchive.
+type archiveFile struct {
+	name    string
+	mode    int64
+	content string
+}
+
+// makeArchive returns a .tar.gz or, for a name ending in .zip, a .zip archive
+// of files.
+func makeArchive(t *testing.T, name string, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if strings.HasSuffix(name, ".zip") {
+		zw := zip.NewWriter(&buf)
+		for _, file := range files {
+			header := &zip.FileHeader{Name: file.name, Method: zip.Deflate}
+			header.SetMode(os.FileMode(file.mode))
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				t.Fatalf("failed to create %s: %v", file.name, err)
+			}
+			w.Write([]byte(file.content))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return buf.Bytes()
+	}
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		tw.WriteHeader(&tar.Header{Name: file.name, Mode: file.mode, Size: int64(len(file.content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(file.content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestExtractArchiveBinary(t *testing.T) {
+	goreleaser := []archiveFile{
+		{name: "README.md", mode: 0644, content: "readme"},
+		{name: "LICENSE", mode: 0644, content: "license"},
+		{name: "agent", mode: 0755, content: "binary"},
+	}
+	testCases := []struct {
+		name     string
+		archive  string
+		files    []archiveFile
+		binary   string
+		expected string
+		errMatch string
+	}{
+		{name: "tar.gz by name", archive: "agent_linux_amd64.tar.gz", files: goreleaser, binary: "agent", expected: "binary"},
+		{name: "zip by name", archive: "agent_darwin_arm64.zip", files: goreleaser, binary: "agent", expected: "binary"},
+		{
+			name:    "exe in subdirectory",
+			archive: "agent_windows_amd64.zip",
+			files: []archiveFile{
+				{name: "agent_windows_amd64/README.md", mode: 0644, content: "readme"},
+				{name: "agent_windows_amd64/agent.exe", mode: 0644, content: "windows binary"},
+			},
+			binary:   "agent",
+			expected: "windows binary",
+		},
+		{
+			name:    "named binary among executables",
+			archive: "agent.tgz",
+			files: []archiveFile{
+				{name: "install.sh", mode: 0755, content: "script"},
+				{name: "agent", mode: 0755, content: "binary"},
+			},
+			binary:   "agent",
+			expected: "binary",
+		},
+		{name: "single executable with another name", archive: "agent.tar.gz", files: goreleaser, binary: "renamed", expected: "binary"},
+		{
+			name:    "ambiguous executables",
+			archive: "agent.tar.gz",
+			files: []archiveFile{
+				{name: "install.sh", mode: 0755, content: "script"},
+				{name: "agent", mode: 0755, content: "binary"},
+			},
+			binary:   "renamed",
+			errMatch: "failed to find the binary renamed in agent.tar.gz",
+		},
+		{name: "no executable", archive: "agent.zip", files: goreleaser[:2], binary: "agent", errMatch: "failed to find the binary"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "archive")
+			if err := os.WriteFile(archive, makeArchive(t, tc.archive, tc.files), 0644); err != nil {
+				t.Fatalf("failed to write archive: %v", err)
+			}
+			dest := filepath.Join(dir, "binary")
+			err := extractArchiveBinary(archive, tc.archive, tc.binary, dest)
+			if tc.errMatch != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errMatch) {
+					t.Fatalf("expected error containing %q, got %v", tc.errMatch, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractArchiveBinary failed: %v", err)
+			}
+			data, _ := os.ReadFile(dest)
+			if string(data) != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, data)
+			}
+		})
+	}
+
+	if err := extractArchiveBinary(filepath.Join(t.TempDir(), "missing"), "agent.tar.gz", "agent", filepath.Join(t.TempDir(), "binary")); err == nil {
+		t.Error("expected an error for a missing archive")
+	}
+}
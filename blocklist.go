@@ -0,0 +1,120 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// BlockedAssetName is the name of a release asset that marks a GitHub release as
+// blocked. Uploading an empty file with this name to a broken release stops
+// clients from installing it. Alternatively, add an "updater:blocked" directive
+// to the release body.
+const BlockedAssetName = "BLOCKED"
+
+// BlockList holds the contents of a blocked.json file, listing versions that
+// must never be installed.
+//
+// Example of blocked.json:
+//
+//	{
+//	  "versions": ["1.4.0", "v1.4.1"]
+//	}
+type BlockList struct {
+	Versions []string `json:"versions"` // The blocked versions, with or without a 'v' prefix.
+}
+
+// FetchBlockList fetches and parses a blocked.json file from the given URL.
+// A missing or empty file is treated as an empty block list.
+func FetchBlockList(blockListURL string) (*BlockList, error) {
+	resp, err := clientFor(blockListURL).Get(blockListURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch block list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &BlockList{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch block list: status code %d", resp.StatusCode)
+	}
+
+	var list BlockList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse block list: %w", err)
+	}
+	return &list, nil
+}
+
+// fetchImplicitBlockList fetches the blocked.json that a source may serve next
+// to its releases. Unlike a configured block list it is optional: if it cannot
+// be fetched, the failure is printed to w and an empty block list is returned, so
+// that an unreachable or malformed blocked.json does not stop update checks.
+func fetchImplicitBlockList(w io.Writer, blockListURL string) *BlockList {
+	list, err := FetchBlockList(blockListURL)
+	if err != nil {
+		fmt.Fprintf(w, "%v; continuing without it\n", err)
+		return &BlockList{}
+	}
+	return list
+}
+
+// blockListURLFor returns the location of blocked.json next to latest.json on a
+// generic HTTP update server.
+func blockListURLFor(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	u.Path += "/blocked.json"
+	return u.String(), nil
+}
+
+// isReleaseBlocked reports whether a GitHub release has been marked as blocked,
+// either with a BLOCKED asset or an updater:blocked directive in its body.
+func isReleaseBlocked(release *Release) bool {
+	for _, asset := range release.Assets {
+		if strings.EqualFold(asset.Name, BlockedAssetName) {
+			return true
+		}
+	}
+	_, blocked := parseReleaseDirectives(release.Body)["blocked"]
+	return blocked
+}
+
+// versionSet is a set of versions, compared irrespective of 'v' prefix and
+// build metadata.
+type versionSet map[string]bool
+
+func newVersionSet(versions ...string) versionSet {
+	s := make(versionSet)
+	s.add(versions...)
+	return s
+}
+
+func (s versionSet) add(versions ...string) {
+	for _, v := range versions {
+		s[canonicalVersion(v)] = true
+	}
+}
+
+func (s versionSet) has(version string) bool {
+	return s[canonicalVersion(version)]
+}
+
+// canonicalVersion normalizes a version for equality checks. Invalid versions
+// are returned unchanged.
+func canonicalVersion(version string) string {
+	v := formatVersionForComparison(strings.TrimSpace(version))
+	if c := semver.Canonical(v); c != "" {
+		return c
+	}
+	return v
+}
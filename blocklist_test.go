@@ -0,0 +1,274 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchBlockList(t *testing.T) {
+	testCases := []struct {
+		name             string
+		handler          http.HandlerFunc
+		expectError      bool
+		expectedVersions int
+	}{
+		{
+			name: "Valid blocked.json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"versions": ["1.4.0", "v1.4.1"]}`)
+			},
+			expectedVersions: 2,
+		},
+		{
+			name: "Missing blocked.json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+		},
+		{
+			name:    "Empty blocked.json",
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+		},
+		{
+			name: "Invalid JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"versions": [`)
+			},
+			expectError: true,
+		},
+		{
+			name: "Server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(tc.handler)
+			defer server.Close()
+
+			list, err := FetchBlockList(server.URL + "/blocked.json")
+			if (err != nil) != tc.expectError {
+				t.Fatalf("Expected error: %v, got: %v", tc.expectError, err)
+			}
+			if err == nil && len(list.Versions) != tc.expectedVersions {
+				t.Errorf("Expected %d blocked versions, got %d", tc.expectedVersions, len(list.Versions))
+			}
+		})
+	}
+}
+
+func TestIsReleaseBlocked(t *testing.T) {
+	testCases := []struct {
+		name     string
+		release  Release
+		expected bool
+	}{
+		{"Plain release", Release{TagName: "v1.0.0", Body: "Bug fixes"}, false},
+		{"BLOCKED asset", Release{TagName: "v1.0.0", Assets: []ReleaseAsset{{Name: "BLOCKED"}}}, true},
+		{"Directive in body", Release{TagName: "v1.0.0", Body: "Broken build\n\nupdater:blocked"}, true},
+		{"Directive in comment", Release{TagName: "v1.0.0", Body: "Notes <!-- updater:blocked -->"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isReleaseBlocked(&tc.release); got != tc.expected {
+				t.Errorf("Expected blocked: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestVersionSet(t *testing.T) {
+	set := newVersionSet("1.4.0", "v2.0.0+build.1")
+	for _, v := range []string{"v1.4.0", "1.4.0", "v1.4", "2.0.0"} {
+		if !set.has(v) {
+			t.Errorf("expected %q to be in the set", v)
+		}
+	}
+	if set.has("v1.4.1") {
+		t.Errorf("did not expect v1.4.1 to be in the set")
+	}
+}
+
+func TestFilterReleasesSkipsBlocked(t *testing.T) {
+	releases := []Release{
+		{TagName: "v1.2.0", Assets: []ReleaseAsset{{Name: BlockedAssetName}}},
+		{TagName: "v1.1.0"},
+	}
+	release := filterReleases(releases, "stable")
+	if release == nil || release.TagName != "v1.1.0" {
+		t.Errorf("expected the blocked release to be skipped, got %v", release)
+	}
+}
+
+func TestUpdateService_Blocked(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	defer func() { NewGithubClient = originalNewGithubClient }()
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{
+					{TagName: "v1.3.0", Body: "<!-- updater:blocked -->"},
+					{TagName: "v1.2.0"},
+					{TagName: "v1.1.0"},
+				}, nil
+			},
+		}
+	}
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+
+	testCases := []struct {
+		name            string
+		version         string
+		config          UpdateServiceConfig
+		expectedTag     string
+		expectAvailable bool
+	}{
+		{
+			name:        "Blocked release is skipped",
+			version:     "v1.1.0",
+			config:      UpdateServiceConfig{BlockedVersions: []string{"1.2.0"}},
+			expectedTag: "v1.1.0",
+		},
+		{
+			name:        "Blocked current version without downgrade",
+			version:     "v1.3.0",
+			config:      UpdateServiceConfig{BlockedVersions: []string{"1.0.0"}},
+			expectedTag: "v1.2.0",
+		},
+		{
+			name:            "Blocked current version with downgrade",
+			version:         "v1.3.0",
+			config:          UpdateServiceConfig{DowngradeFromBlocked: true},
+			expectedTag:     "v1.2.0",
+			expectAvailable: true,
+		},
+		{
+			name:        "Good current version is not downgraded",
+			version:     "v1.2.0",
+			config:      UpdateServiceConfig{DowngradeFromBlocked: true},
+			expectedTag: "v1.2.0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			Version = tc.version
+			tc.config.RepoURL = "https://github.com/owner/repo"
+			service, err := NewUpdateService(tc.config)
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+
+			release, available, err := service.CheckForNewerVersion()
+			if err != nil {
+				t.Fatalf("CheckForNewerVersion failed: %v", err)
+			}
+			if release == nil || release.TagName != tc.expectedTag {
+				t.Errorf("Expected release %q, got %v", tc.expectedTag, release)
+			}
+			if available != tc.expectAvailable {
+				t.Errorf("Expected update available: %v, got: %v", tc.expectAvailable, available)
+			}
+		})
+	}
+}
+
+func TestCheckForUpdatesHTTP_Blocked(t *testing.T) {
+	latest := "1.2.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprintf(w, `{"version": %q, "url": "http://example.com/update"}`, latest)
+		case "/blocked.json":
+			fmt.Fprintln(w, `{"versions": ["1.2.0", "1.1.0"]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+
+	var updates []string
+	originalDoUpdate := DoUpdate
+	defer func() { DoUpdate = originalDoUpdate }()
+	DoUpdate = func(url string) error {
+		updates = append(updates, url)
+		return nil
+	}
+
+	Version = "1.0.0"
+	if err := CheckForUpdatesHTTP(server.URL); err != nil {
+		t.Fatalf("CheckForUpdatesHTTP failed: %v", err)
+	}
+	if len(updates) != 0 {
+		t.Fatalf("expected blocked release not to be applied, got %v", updates)
+	}
+
+	// A client on a blocked version is rolled back once latest.json points at a good release.
+	latest = "1.0.5"
+	Version = "1.1.0"
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:              server.URL,
+		CheckOnStartup:       CheckAndUpdateOnStartup,
+		DowngradeFromBlocked: true,
+		LockPath:             filepath.Join(t.TempDir(), "update.lock"),
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Errorf("expected a downgrade away from the blocked version, got %v", updates)
+	}
+}
+
+func TestBlockList_ImplicitIsOptional(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprint(w, `{"version": "1.2.0", "url": "http://example.com/update"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "1.0.0"
+
+	if err := CheckOnlyHTTP(server.URL); err != nil {
+		t.Errorf("expected a failing blocked.json to be ignored, got %v", err)
+	}
+
+	service, err := NewUpdateService(UpdateServiceConfig{RepoURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	if _, err := service.blockedVersions(); err != nil {
+		t.Errorf("expected a failing blocked.json to be ignored, got %v", err)
+	}
+
+	service, err = NewUpdateService(UpdateServiceConfig{RepoURL: server.URL, BlockListURL: server.URL + "/blocked.json"})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	if _, err := service.blockedVersions(); err == nil {
+		t.Errorf("expected a failing configured block list to be an error")
+	}
+}
@@ -0,0 +1,442 @@
+package updater
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// BundleFormat is the version of the bundle format written by CreateBundle.
+const BundleFormat = 1
+
+// The files of a bundle besides the binaries.
+const (
+	bundleManifestName  = "manifest.json"
+	bundleSignatureName = "manifest.json.sig"
+	bundleChecksumsName = "checksums.txt"
+)
+
+// maxBundleMetadataSize limits how much of the manifest and signature is read,
+// so that a corrupt bundle cannot exhaust memory before it is verified.
+const maxBundleMetadataSize = 1 << 20
+
+// ErrInvalidBundle is returned when a bundle is malformed, its signature does
+// not verify, or a binary does not match its checksum.
+var ErrInvalidBundle = errors.New("invalid update bundle")
+
+// BundleManifest describes the contents of an update bundle. It is signed, and
+// the checksums it lists authenticate the binaries.
+type BundleManifest struct {
+	// Format is the version of the bundle format.
+	Format int `json:"format"`
+	// Name is the name of the product, if known.
+	Name string `json:"name,omitempty"`
+	// Version is the release version of every binary in the bundle.
+	Version string `json:"version"`
+	// Binaries are the platform binaries in the bundle.
+	Binaries []BundleBinary `json:"binaries"`
+}
+
+// BundleBinary is one platform binary in an update bundle.
+type BundleBinary struct {
+	// OS and Arch are the platform of the binary, as in GOOS and GOARCH.
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// Path is the location of the binary in the bundle, e.g.
+	// "linux_amd64/agent".
+	Path string `json:"path"`
+	// Size is the size of the binary in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the binary.
+	SHA256 string `json:"sha256"`
+}
+
+// Binary returns the binary for the platform goos/goarch, or nil if the bundle
+// has none.
+func (m *BundleManifest) Binary(goos, goarch string) *BundleBinary {
+	for i := range m.Binaries {
+		if m.Binaries[i].OS == goos && m.Binaries[i].Arch == goarch {
+			return &m.Binaries[i]
+		}
+	}
+	return nil
+}
+
+// BundleFile is a local binary to be added to a bundle by CreateBundle.
+type BundleFile struct {
+	// OS and Arch are the platform of the binary, as in GOOS and GOARCH.
+	OS   string
+	Arch string
+	// Path is the binary on the local filesystem.
+	Path string
+}
+
+// CreateBundle writes a signed update bundle for version to w: a gzipped tar
+// archive holding the binaries in files, one per platform, a manifest listing
+// their checksums, the manifest's signature made with key, and a checksums.txt
+// in the format of sha256sum. It returns the manifest.
+func CreateBundle(w io.Writer, name, version string, files []BundleFile, key ed25519.PrivateKey) (*BundleManifest, error) {
+	if version == "" {
+		return nil, fmt.Errorf("a bundle needs a version")
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("a bundle needs at least one binary")
+	}
+
+	manifest := &BundleManifest{Format: BundleFormat, Name: name, Version: version}
+	for _, file := range files {
+		if file.OS == "" || file.Arch == "" {
+			return nil, fmt.Errorf("platform of %s is unknown", file.Path)
+		}
+		if manifest.Binary(file.OS, file.Arch) != nil {
+			return nil, fmt.Errorf("more than one binary for %s/%s", file.OS, file.Arch)
+		}
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read binary: %w", err)
+		}
+		sum, err := fileSHA256(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read binary: %w", err)
+		}
+		manifest.Binaries = append(manifest.Binaries, BundleBinary{
+			OS:     file.OS,
+			Arch:   file.Arch,
+			Path:   path.Join(file.OS+"_"+file.Arch, filepath.Base(file.Path)),
+			Size:   info.Size(),
+			SHA256: sum,
+		})
+	}
+	sort.Slice(manifest.Binaries, func(i, j int) bool { return manifest.Binaries[i].Path < manifest.Binaries[j].Path })
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n"
+	var checksums strings.Builder
+	for _, binary := range manifest.Binaries {
+		fmt.Fprintf(&checksums, "%s  %s\n", binary.SHA256, binary.Path)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{bundleManifestName, data},
+		{bundleSignatureName, []byte(signature)},
+		{bundleChecksumsName, []byte(checksums.String())},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.data))}); err != nil {
+			return nil, fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return nil, fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+	for _, file := range files {
+		binary := manifest.Binary(file.OS, file.Arch)
+		if err := addBundleFile(tw, binary, file.Path); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return manifest, nil
+}
+
+// addBundleFile copies the binary at path into the bundle as binary.
+func addBundleFile(tw *tar.Writer, binary *BundleBinary, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read binary: %w", err)
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(&tar.Header{Name: binary.Path, Mode: 0755, Size: binary.Size}); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// walkBundle calls fn for each regular file in the bundle at path.
+func walkBundle(path string, fn func(name string, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadBundleManifest returns the manifest of the bundle at path after
+// verifying its signature with key. The binaries are not read; see
+// VerifyBundle.
+func ReadBundleManifest(path string, key ed25519.PublicKey) (*BundleManifest, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: expected %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+	var data, signature []byte
+	err := walkBundle(path, func(name string, r io.Reader) error {
+		var err error
+		switch name {
+		case bundleManifestName:
+			data, err = io.ReadAll(io.LimitReader(r, maxBundleMetadataSize))
+		case bundleSignatureName:
+			signature, err = io.ReadAll(io.LimitReader(r, maxBundleMetadataSize))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == nil || signature == nil {
+		return nil, fmt.Errorf("%w: missing %s or %s", ErrInvalidBundle, bundleManifestName, bundleSignatureName)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil || !ed25519.Verify(key, data, sig) {
+		return nil, fmt.Errorf("%w: signature does not match the public key", ErrInvalidBundle)
+	}
+
+	var manifest BundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if manifest.Format != BundleFormat {
+		return nil, fmt.Errorf("%w: unsupported format %d", ErrInvalidBundle, manifest.Format)
+	}
+	if manifest.Version == "" {
+		return nil, fmt.Errorf("%w: manifest has no version", ErrInvalidBundle)
+	}
+	return &manifest, nil
+}
+
+// VerifyBundle verifies the signature of the bundle at path with key and the
+// checksum of every binary it lists, and returns its manifest.
+func VerifyBundle(path string, key ed25519.PublicKey) (*BundleManifest, error) {
+	manifest, err := ReadBundleManifest(path, key)
+	if err != nil {
+		return nil, err
+	}
+	verified := make(map[string]bool)
+	err = walkBundle(path, func(name string, r io.Reader) error {
+		for _, binary := range manifest.Binaries {
+			if binary.Path == name {
+				verified[name] = true
+				return checkBundleBinary(&binary, r, io.Discard)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, binary := range manifest.Binaries {
+		if !verified[binary.Path] {
+			return nil, fmt.Errorf("%w: %s is missing", ErrInvalidBundle, binary.Path)
+		}
+	}
+	return manifest, nil
+}
+
+// extractBundleBinary copies binary from the bundle at path to dest,
+// verifying its checksum.
+func extractBundleBinary(path string, binary *BundleBinary, dest string) error {
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", binary.Path, err)
+	}
+	defer out.Close()
+
+	found := false
+	err = walkBundle(path, func(name string, r io.Reader) error {
+		if name != binary.Path || found {
+			return nil
+		}
+		found = true
+		return checkBundleBinary(binary, r, out)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: %s is missing", ErrInvalidBundle, binary.Path)
+	}
+	return out.Close()
+}
+
+// checkBundleBinary copies the binary from r to w and verifies its size and
+// checksum.
+func checkBundleBinary(binary *BundleBinary, r io.Reader, w io.Writer) error {
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(h, w), io.LimitReader(r, binary.Size+1))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", binary.Path, err)
+	}
+	if n != binary.Size || hex.EncodeToString(h.Sum(nil)) != binary.SHA256 {
+		return fmt.Errorf("%w: checksum of %s does not match the manifest", ErrInvalidBundle, binary.Path)
+	}
+	return nil
+}
+
+// ApplyBundle installs the binary for this platform from the update bundle at
+// path, holding the update lock while it is applied. The bundle's signature
+// must verify with the configured PublicKey and the binary must match its
+// checksum. The manifest is returned once the signature is verified, even if
+// the bundle is not applied. Like UpdateTo, versions older than the current
+// version require AllowDowngrade, and versions that are blocked or outside the
+// configured constraint are refused. Bundles are meant for sites without
+// network access, so only the configured BlockedVersions are consulted, not
+// remote block lists.
+func (s *UpdateService) ApplyBundle(path string) (*BundleManifest, error) {
+	if len(s.config.PublicKey) == 0 {
+		return nil, fmt.Errorf("a public key is required to verify update bundles")
+	}
+	manifest, err := ReadBundleManifest(path, s.config.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	display := formatVersionForDisplay(manifest.Version, s.config.ForceSemVerPrefix)
+	binary := manifest.Binary(runtime.GOOS, runtime.GOARCH)
+	if binary == nil {
+		return manifest, fmt.Errorf("bundle %s has no binary for %s/%s", display, runtime.GOOS, runtime.GOARCH)
+	}
+	if newVersionSet(s.config.BlockedVersions...).has(manifest.Version) {
+		return manifest, fmt.Errorf("release %s is blocked", display)
+	}
+	if s.constraint != nil && !s.constraint.Check(manifest.Version) {
+		return manifest, fmt.Errorf("release %s is outside the allowed versions %s", display, s.constraint)
+	}
+
+	lock, err := s.acquireLock()
+	if err != nil {
+		return manifest, err
+	}
+	defer lock.Release()
+
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return manifest, err
+	}
+	if err := s.checkInstallation(); err != nil {
+		return manifest, err
+	}
+	proceed, err := checkTargetVersion(s.out(), manifest.Version, current, s.config.AllowDowngrade, s.config.ForceSemVerPrefix)
+	if err != nil || !proceed {
+		return manifest, err
+	}
+
+	dir, err := os.MkdirTemp("", "updater-bundle-")
+	if err != nil {
+		return manifest, fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, filepath.Base(filepath.FromSlash(binary.Path)))
+	if err := extractBundleBinary(path, binary, file); err != nil {
+		return manifest, err
+	}
+	url, err := FileURL(file)
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, s.installFrom(manifest.Version, current, url, assetChecksum{})
+}
+
+// ParsePublicKey parses an Ed25519 public key for verifying update bundles,
+// either PEM-encoded as written by "openssl pkey -pubout", or the base64
+// encoding of the raw 32-byte key.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		public, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid public key: not an Ed25519 key")
+		}
+		return public, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: expected PEM or a base64-encoded Ed25519 key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// LoadPublicKey returns the public key in value, as accepted by
+// ParsePublicKey, or else read from the file named by value.
+func LoadPublicKey(value string) (ed25519.PublicKey, error) {
+	if key, err := ParsePublicKey([]byte(value)); err == nil {
+		return key, nil
+	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("expected a public key or a key file: %w", err)
+	}
+	return ParsePublicKey(data)
+}
+
+// ParsePrivateKey parses a PEM-encoded Ed25519 private key for signing update
+// bundles, as written by "openssl genpkey -algorithm ed25519".
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key: expected PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid private key: not an Ed25519 key")
+	}
+	return private, nil
+}
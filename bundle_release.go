@@ -0,0 +1,193 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BundleContents are the binaries of one release, to be packed by
+// CreateBundle.
+type BundleContents struct {
+	// Name is the name of the product.
+	Name string
+	// Version is the release version.
+	Version string
+	// Files are the platform binaries of the release.
+	Files []BundleFile
+}
+
+// bundleOSNames and bundleArchNames are the platforms recognized in the names
+// of release assets. Longer architecture names come first, so that "arm64" is
+// not taken for "arm".
+var (
+	bundleOSNames   = []string{"linux", "darwin", "windows", "freebsd", "openbsd", "netbsd"}
+	bundleArchNames = []string{"amd64", "arm64", "ppc64le", "riscv64", "s390x", "386", "arm"}
+)
+
+// bundleSkippedSuffixes are release assets that are not binaries, such as
+// packages, checksums and signatures. Archives are not skipped; the binary is
+// extracted from them.
+var bundleSkippedSuffixes = []string{
+	".deb", ".rpm", ".apk", ".txt", ".json",
+	".sig", ".pem", ".sbom", ".sha256", ".asc",
+}
+
+// assetPlatform returns the GOOS and GOARCH named in the name of a binary
+// release asset, like GetDownloadURL matches assets for this platform.
+func assetPlatform(name string) (goos, goarch string, ok bool) {
+	lower := strings.ToLower(name)
+	for _, suffix := range bundleSkippedSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return "", "", false
+		}
+	}
+	for _, o := range bundleOSNames {
+		if strings.Contains(lower, o) {
+			goos = o
+			break
+		}
+	}
+	for _, a := range bundleArchNames {
+		if strings.Contains(lower, a) {
+			goarch = a
+			break
+		}
+	}
+	return goos, goarch, goos != "" && goarch != ""
+}
+
+// DownloadBundleContents downloads the platform binaries of the release with
+// the given version into dir, for CreateBundle. Only GitHub, S3 and OCI
+// sources list binaries for every platform. Downloads are verified like
+// updates, and binaries are extracted from release archives such as the
+// .tar.gz and .zip archives of goreleaser.
+func (s *UpdateService) DownloadBundleContents(version, dir string) (*BundleContents, error) {
+	if !s.listsReleases() {
+		return nil, fmt.Errorf("bundles can only be created from GitHub, S3 or OCI releases or a goreleaser dist directory")
+	}
+	releases, err := s.Releases()
+	if err != nil {
+		return nil, err
+	}
+	release := findReleaseByVersion(releases, version)
+	if release == nil {
+		return nil, fmt.Errorf("release %s not found", formatVersionForDisplay(version, s.config.ForceSemVerPrefix))
+	}
+
+	contents := &BundleContents{Name: s.repo, Version: release.TagName}
+	switch {
+	case s.s3 != nil:
+		contents.Name = s.s3.name()
+	case s.oci != nil:
+		contents.Name = s.oci.name()
+	}
+	seen := make(map[string]bool)
+	for _, asset := range release.Assets {
+		goos, goarch, ok := assetPlatform(asset.Name)
+		if !ok || seen[goos+"/"+goarch] {
+			continue
+		}
+		seen[goos+"/"+goarch] = true
+
+		name := strings.ReplaceAll(asset.Name, "/", "_")
+		if suffix := archiveSuffix(name); suffix != "" {
+			name = name[:len(name)-len(suffix)]
+			if goos == "windows" {
+				name += ".exe"
+			}
+		}
+		path := filepath.Join(dir, name)
+		sum := releaseChecksum(release, asset.DownloadURL)
+		if err := downloadVerified(s.downloader(), asset.DownloadURL, path, sum, contents.Name); err != nil {
+			return nil, err
+		}
+		contents.Files = append(contents.Files, BundleFile{OS: goos, Arch: goarch, Path: path})
+	}
+	if len(contents.Files) == 0 {
+		return nil, fmt.Errorf("release %s has no platform binaries", release.TagName)
+	}
+	return contents, nil
+}
+
+// goreleaserMetadata is the part of a goreleaser metadata.json used for
+// bundles.
+type goreleaserMetadata struct {
+	ProjectName string `json:"project_name"`
+	Tag         string `json:"tag"`
+	Version     string `json:"version"`
+}
+
+// goreleaserArtifact is an entry of a goreleaser artifacts.json.
+type goreleaserArtifact struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	Type   string `json:"type"`
+}
+
+// DistBundleContents returns the binaries built by goreleaser into its dist
+// directory, as listed by its metadata.json and artifacts.json.
+func DistBundleContents(dir string) (*BundleContents, error) {
+	var metadata goreleaserMetadata
+	if err := readJSONFile(filepath.Join(dir, "metadata.json"), &metadata); err != nil {
+		return nil, err
+	}
+	var artifacts []goreleaserArtifact
+	if err := readJSONFile(filepath.Join(dir, "artifacts.json"), &artifacts); err != nil {
+		return nil, err
+	}
+
+	contents := &BundleContents{Name: metadata.ProjectName, Version: metadata.Tag}
+	if contents.Version == "" {
+		contents.Version = metadata.Version
+	}
+	for _, artifact := range artifacts {
+		if artifact.Type != "Binary" {
+			continue
+		}
+		path, err := distArtifactPath(dir, artifact.Path)
+		if err != nil {
+			return nil, err
+		}
+		contents.Files = append(contents.Files, BundleFile{OS: artifact.GOOS, Arch: artifact.GOARCH, Path: path})
+	}
+	if len(contents.Files) == 0 {
+		return nil, fmt.Errorf("no binaries listed in %s", filepath.Join(dir, "artifacts.json"))
+	}
+	return contents, nil
+}
+
+// distArtifactPath resolves the path of a goreleaser artifact. Paths in
+// artifacts.json are relative to the project root, which is normally the
+// parent of the dist directory.
+func distArtifactPath(dir, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	candidates := []string{
+		filepath.Join(filepath.Dir(dir), filepath.FromSlash(path)),
+		filepath.Join(dir, filepath.FromSlash(path)),
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("binary %s not found in %s", path, dir)
+}
+
+// readJSONFile decodes the JSON file at path into v.
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
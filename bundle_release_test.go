@@ -0,0 +1,225 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssetPlatform(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"agent_linux_amd64", "linux/amd64"},
+		{"agent-Darwin-ARM64", "darwin/arm64"},
+		{"agent_windows_386.exe", "windows/386"},
+		{"agent_linux_arm", "linux/arm"},
+		{"agent_linux_amd64.tar.gz", "linux/amd64"},
+		{"agent_linux_amd64.deb", ""},
+		{"checksums.txt", ""},
+		{"agent_linux", ""},
+	}
+	for _, tc := range testCases {
+		goos, goarch, ok := assetPlatform(tc.name)
+		got := ""
+		if ok {
+			got = goos + "/" + goarch
+		}
+		if got != tc.expected {
+			t.Errorf("assetPlatform(%q): expected %q, got %q", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestDistBundleContents(t *testing.T) {
+	project := t.TempDir()
+	dist := filepath.Join(project, "dist")
+	binary := filepath.Join(dist, "agent_linux_amd64_v1", "agent")
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatalf("failed to create dist: %v", err)
+	}
+	if err := os.WriteFile(binary, []byte("agent"), 0755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dist, "metadata.json"), []byte(`{"project_name": "agent", "tag": "v1.3.0", "version": "1.3.0"}`), 0644); err != nil {
+		t.Fatalf("failed to write metadata.json: %v", err)
+	}
+	artifacts := `[
+		{"name": "agent", "path": "dist/agent_linux_amd64_v1/agent", "goos": "linux", "goarch": "amd64", "goamd64": "v1", "type": "Binary"},
+		{"name": "agent_1.3.0_linux_amd64.tar.gz", "path": "dist/agent_1.3.0_linux_amd64.tar.gz", "goos": "linux", "goarch": "amd64", "type": "Archive"},
+		{"name": "checksums.txt", "path": "dist/checksums.txt", "type": "Checksum"}
+	]`
+	if err := os.WriteFile(filepath.Join(dist, "artifacts.json"), []byte(artifacts), 0644); err != nil {
+		t.Fatalf("failed to write artifacts.json: %v", err)
+	}
+
+	contents, err := DistBundleContents(dist)
+	if err != nil {
+		t.Fatalf("DistBundleContents failed: %v", err)
+	}
+	if contents.Name != "agent" || contents.Version != "v1.3.0" || len(contents.Files) != 1 {
+		t.Fatalf("unexpected contents %+v", contents)
+	}
+	if file := contents.Files[0]; file.OS != "linux" || file.Arch != "amd64" || file.Path != binary {
+		t.Errorf("unexpected file %+v", file)
+	}
+
+	if err := os.Remove(binary); err != nil {
+		t.Fatalf("failed to remove binary: %v", err)
+	}
+	if _, err := DistBundleContents(dist); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected an error for a missing binary, got %v", err)
+	}
+	if _, err := DistBundleContents(project); err == nil {
+		t.Errorf("expected an error for a directory without metadata.json")
+	}
+}
+
+func TestUpdateService_DownloadBundleContents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "binary "+r.URL.Path)
+	}))
+	defer server.Close()
+	digest := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	linuxDigest := digest("binary /agent_linux_amd64")
+
+	originalNewGithubClient := NewGithubClient
+	defer func() { NewGithubClient = originalNewGithubClient }()
+	var darwinDigest string
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{{
+					TagName: "v1.3.0",
+					Assets: []ReleaseAsset{
+						{Name: "agent_linux_amd64", DownloadURL: server.URL + "/agent_linux_amd64", Digest: linuxDigest},
+						{Name: "agent_darwin_arm64", DownloadURL: server.URL + "/agent_darwin_arm64", Digest: darwinDigest},
+						{Name: "agent_linux_amd64.tar.gz", DownloadURL: server.URL + "/agent_linux_amd64.tar.gz"},
+						{Name: "agent_linux_amd64.sig", DownloadURL: server.URL + "/agent_linux_amd64.sig"},
+					},
+				}}, nil
+			},
+		}
+	}
+
+	service, err := NewUpdateService(UpdateServiceConfig{RepoURL: "https://github.com/owner/agent"})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	dir := t.TempDir()
+	contents, err := service.DownloadBundleContents("1.3.0", dir)
+	if err != nil {
+		t.Fatalf("DownloadBundleContents failed: %v", err)
+	}
+	if contents.Name != "agent" || contents.Version != "v1.3.0" || len(contents.Files) != 2 {
+		t.Fatalf("unexpected contents %+v", contents)
+	}
+	expected := []BundleFile{
+		{OS: "linux", Arch: "amd64", Path: filepath.Join(dir, "agent_linux_amd64")},
+		{OS: "darwin", Arch: "arm64", Path: filepath.Join(dir, "agent_darwin_arm64")},
+	}
+	for i, file := range contents.Files {
+		if file != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], file)
+		}
+	}
+
+	darwinDigest = digest("something else")
+	if _, err := service.DownloadBundleContents("v1.3.0", t.TempDir()); err == nil || !strings.Contains(err.Error(), "does not match digest") {
+		t.Errorf("expected a digest mismatch, got %v", err)
+	}
+	if _, err := service.DownloadBundleContents("v9.9.9", t.TempDir()); err == nil {
+		t.Errorf("expected an error for a missing release")
+	}
+
+	httpService, _ := NewUpdateService(UpdateServiceConfig{RepoURL: server.URL})
+	if _, err := httpService.DownloadBundleContents("v1.3.0", t.TempDir()); err == nil {
+		t.Errorf("expected an error for a generic HTTP source")
+	}
+}
+
+func TestUpdateService_DownloadBundleContentsArchives(t *testing.T) {
+	files := func(binary string) []archiveFile {
+		return []archiveFile{
+			{name: "README.md", mode: 0644, content: "readme"},
+			{name: binary, mode: 0755, content: "binary " + binary},
+		}
+	}
+	archives := map[string][]byte{
+		"/agent_1.3.0_linux_amd64.tar.gz": makeArchive(t, "agent_1.3.0_linux_amd64.tar.gz", files("agent")),
+		"/agent_1.3.0_windows_amd64.zip":  makeArchive(t, "agent_1.3.0_windows_amd64.zip", files("agent.exe")),
+	}
+	var checksums strings.Builder
+	for name, archive := range archives {
+		sum := sha256.Sum256(archive)
+		fmt.Fprintf(&checksums, "%s  %s\n", hex.EncodeToString(sum[:]), strings.TrimPrefix(name, "/"))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/checksums.txt" {
+			fmt.Fprint(w, checksums.String())
+			return
+		}
+		w.Write(archives[r.URL.Path])
+	}))
+	defer server.Close()
+
+	originalNewGithubClient := NewGithubClient
+	defer func() { NewGithubClient = originalNewGithubClient }()
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{{
+					TagName: "v1.3.0",
+					Assets: []ReleaseAsset{
+						{Name: "agent_1.3.0_linux_amd64.tar.gz", DownloadURL: server.URL + "/agent_1.3.0_linux_amd64.tar.gz"},
+						{Name: "agent_1.3.0_windows_amd64.zip", DownloadURL: server.URL + "/agent_1.3.0_windows_amd64.zip"},
+						{Name: "checksums.txt", DownloadURL: server.URL + "/checksums.txt"},
+					},
+				}}, nil
+			},
+		}
+	}
+
+	service, err := NewUpdateService(UpdateServiceConfig{RepoURL: "https://github.com/owner/agent"})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	dir := t.TempDir()
+	contents, err := service.DownloadBundleContents("v1.3.0", dir)
+	if err != nil {
+		t.Fatalf("DownloadBundleContents failed: %v", err)
+	}
+	expected := []BundleFile{
+		{OS: "linux", Arch: "amd64", Path: filepath.Join(dir, "agent_1.3.0_linux_amd64")},
+		{OS: "windows", Arch: "amd64", Path: filepath.Join(dir, "agent_1.3.0_windows_amd64.exe")},
+	}
+	if len(contents.Files) != len(expected) {
+		t.Fatalf("unexpected contents %+v", contents)
+	}
+	for i, file := range contents.Files {
+		if file != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], file)
+		}
+	}
+	for path, content := range map[string]string{expected[0].Path: "binary agent", expected[1].Path: "binary agent.exe"} {
+		if data, _ := os.ReadFile(path); string(data) != content {
+			t.Errorf("expected %s to hold %q, got %q", path, content, data)
+		}
+	}
+
+	archives["/agent_1.3.0_windows_amd64.zip"] = makeArchive(t, "agent_1.3.0_windows_amd64.zip", files("other.exe"))
+	if _, err := service.DownloadBundleContents("v1.3.0", t.TempDir()); err == nil || !strings.Contains(err.Error(), "does not match checksums.txt") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+}
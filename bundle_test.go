@@ -0,0 +1,354 @@
+package updater
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeBundle creates a bundle of version in dir with a binary holding content
+// for this platform and one for another platform, signed with key.
+func writeBundle(t *testing.T, dir, version, content string, key ed25519.PrivateKey) string {
+	t.Helper()
+	native := filepath.Join(dir, "agent")
+	other := filepath.Join(dir, "agent.exe")
+	if err := os.WriteFile(native, []byte(content), 0755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+	if err := os.WriteFile(other, []byte("other platform"), 0755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+	otherOS := "windows"
+	if runtime.GOOS == "windows" {
+		otherOS = "linux"
+	}
+
+	path := filepath.Join(dir, "agent-"+version+".tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create bundle: %v", err)
+	}
+	defer f.Close()
+	files := []BundleFile{
+		{OS: runtime.GOOS, Arch: runtime.GOARCH, Path: native},
+		{OS: otherOS, Arch: "amd64", Path: other},
+	}
+	if _, err := CreateBundle(f, "agent", version, files, key); err != nil {
+		t.Fatalf("CreateBundle failed: %v", err)
+	}
+	return path
+}
+
+// rewriteBundle replaces the contents of the files in the bundle at path with
+// the result of edit.
+func rewriteBundle(t *testing.T, path string, edit func(name string, data []byte) []byte) {
+	t.Helper()
+	var entries []struct {
+		name string
+		data []byte
+	}
+	err := walkBundle(path, func(name string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		entries = append(entries, struct {
+			name string
+			data []byte
+		}{name, edit(name, data)})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to read bundle: %v", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.data))})
+		tw.Write(entry.data)
+	}
+	tw.Close()
+	gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write bundle: %v", err)
+	}
+}
+
+func TestCreateBundle(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	path := writeBundle(t, t.TempDir(), "v1.3.0", "new agent", private)
+
+	manifest, err := VerifyBundle(path, public)
+	if err != nil {
+		t.Fatalf("VerifyBundle failed: %v", err)
+	}
+	if manifest.Format != BundleFormat || manifest.Name != "agent" || manifest.Version != "v1.3.0" || len(manifest.Binaries) != 2 {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+	binary := manifest.Binary(runtime.GOOS, runtime.GOARCH)
+	if binary == nil {
+		t.Fatalf("expected a binary for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	if binary.Path != runtime.GOOS+"_"+runtime.GOARCH+"/agent" || binary.Size != int64(len("new agent")) {
+		t.Errorf("unexpected binary %+v", binary)
+	}
+
+	var checksums string
+	walkBundle(path, func(name string, r io.Reader) error {
+		if name == bundleChecksumsName {
+			data, _ := io.ReadAll(r)
+			checksums = string(data)
+		}
+		return nil
+	})
+	if !strings.Contains(checksums, binary.SHA256+"  "+binary.Path+"\n") {
+		t.Errorf("expected checksums.txt to list %s, got %q", binary.Path, checksums)
+	}
+}
+
+func TestCreateBundle_Invalid(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(nil)
+	binary := filepath.Join(t.TempDir(), "agent")
+	if err := os.WriteFile(binary, []byte("agent"), 0755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		version string
+		files   []BundleFile
+	}{
+		{"no version", "", []BundleFile{{OS: "linux", Arch: "amd64", Path: binary}}},
+		{"no binaries", "v1.0.0", nil},
+		{"unknown platform", "v1.0.0", []BundleFile{{Path: binary}}},
+		{"duplicate platform", "v1.0.0", []BundleFile{{OS: "linux", Arch: "amd64", Path: binary}, {OS: "linux", Arch: "amd64", Path: binary}}},
+		{"missing binary", "v1.0.0", []BundleFile{{OS: "linux", Arch: "amd64", Path: binary + ".missing"}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := CreateBundle(io.Discard, "agent", tc.version, tc.files, private); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestVerifyBundle_Invalid(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	otherPublic, _, _ := ed25519.GenerateKey(nil)
+
+	testCases := []struct {
+		name string
+		key  ed25519.PublicKey
+		edit func(name string, data []byte) []byte
+	}{
+		{name: "wrong key", key: otherPublic},
+		{
+			name: "tampered manifest",
+			key:  public,
+			edit: func(name string, data []byte) []byte {
+				if name == bundleManifestName {
+					return bytes.Replace(data, []byte("v1.3.0"), []byte("v9.9.9"), 1)
+				}
+				return data
+			},
+		},
+		{
+			name: "tampered binary",
+			key:  public,
+			edit: func(name string, data []byte) []byte {
+				if strings.HasSuffix(name, "/agent") {
+					return []byte("evil agent")
+				}
+				return data
+			},
+		},
+		{
+			name: "truncated binary",
+			key:  public,
+			edit: func(name string, data []byte) []byte {
+				if strings.HasSuffix(name, "/agent.exe") {
+					return nil
+				}
+				return data
+			},
+		},
+		{
+			name: "malformed signature",
+			key:  public,
+			edit: func(name string, data []byte) []byte {
+				if name == bundleSignatureName {
+					return []byte("not base64!")
+				}
+				return data
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeBundle(t, t.TempDir(), "v1.3.0", "new agent", private)
+			if tc.edit != nil {
+				rewriteBundle(t, path, tc.edit)
+			}
+			if _, err := VerifyBundle(path, tc.key); !errors.Is(err, ErrInvalidBundle) {
+				t.Errorf("expected ErrInvalidBundle, got %v", err)
+			}
+		})
+	}
+
+	notABundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	os.WriteFile(notABundle, []byte("not gzip"), 0644)
+	if _, err := VerifyBundle(notABundle, public); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("expected ErrInvalidBundle for a file that is not a bundle, got %v", err)
+	}
+
+	path := writeBundle(t, t.TempDir(), "v1.3.0", "new agent", private)
+	if _, err := ReadBundleManifest(path, public[:ed25519.PublicKeySize-1]); err == nil || !strings.Contains(err.Error(), "invalid public key") {
+		t.Errorf("expected an error for a truncated public key, got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	parsedPrivate, err := ParsePrivateKey(privatePEM)
+	if err != nil || !parsedPrivate.Equal(private) {
+		t.Errorf("ParsePrivateKey: unexpected key, error %v", err)
+	}
+	if _, err := ParsePrivateKey(publicPEM); err == nil {
+		t.Errorf("ParsePrivateKey: expected an error for a public key")
+	}
+
+	for name, data := range map[string][]byte{
+		"pem":    publicPEM,
+		"base64": []byte(base64.StdEncoding.EncodeToString(public) + "\n"),
+	} {
+		parsed, err := ParsePublicKey(data)
+		if err != nil || !parsed.Equal(public) {
+			t.Errorf("ParsePublicKey(%s): unexpected key, error %v", name, err)
+		}
+	}
+	if _, err := ParsePublicKey([]byte("c2hvcnQ=")); err == nil {
+		t.Errorf("ParsePublicKey: expected an error for a short key")
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "bundle.pub")
+	if err := os.WriteFile(keyFile, publicPEM, 0644); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	if loaded, err := LoadPublicKey(keyFile); err != nil || !loaded.Equal(public) {
+		t.Errorf("LoadPublicKey(file): unexpected key, error %v", err)
+	}
+	if _, err := LoadPublicKey(keyFile + ".missing"); err == nil {
+		t.Errorf("LoadPublicKey: expected an error for a missing file")
+	}
+}
+
+func TestUpdateService_ApplyBundle(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	otherPublic, _, _ := ed25519.GenerateKey(nil)
+
+	originalDetectVersion := DetectVersion
+	originalApplyUpdateFile := ApplyUpdateFile
+	defer func() {
+		DetectVersion = originalDetectVersion
+		ApplyUpdateFile = originalApplyUpdateFile
+	}()
+	DetectVersion = func(path string) (string, error) { return "1.2.0", nil }
+
+	testCases := []struct {
+		name          string
+		version       string
+		config        UpdateServiceConfig
+		tamper        bool
+		expectApplied string
+		expectErr     error
+		expectErrText string
+	}{
+		{name: "update", version: "v1.3.0", config: UpdateServiceConfig{PublicKey: public}, expectApplied: "agent v1.3.0"},
+		{name: "same version", version: "v1.2.0", config: UpdateServiceConfig{PublicKey: public}},
+		{name: "downgrade", version: "v1.1.0", config: UpdateServiceConfig{PublicKey: public}, expectErr: ErrDowngradeNotAllowed},
+		{name: "allowed downgrade", version: "v1.1.0", config: UpdateServiceConfig{PublicKey: public, AllowDowngrade: true}, expectApplied: "agent v1.1.0"},
+		{name: "dry run", version: "v1.3.0", config: UpdateServiceConfig{PublicKey: public, DryRun: true}},
+		{name: "no public key", version: "v1.3.0", expectErrText: "public key is required"},
+		{name: "wrong key", version: "v1.3.0", config: UpdateServiceConfig{PublicKey: otherPublic}, expectErr: ErrInvalidBundle},
+		{name: "truncated key", version: "v1.3.0", config: UpdateServiceConfig{PublicKey: public[:16]}, expectErrText: "invalid public key"},
+		{name: "tampered binary", version: "v1.3.0", config: UpdateServiceConfig{PublicKey: public}, tamper: true, expectErr: ErrInvalidBundle},
+		{name: "blocked", version: "v1.3.0", config: UpdateServiceConfig{PublicKey: public, BlockedVersions: []string{"1.3.0"}}, expectErrText: "is blocked"},
+		{name: "outside constraint", version: "v2.0.0", config: UpdateServiceConfig{PublicKey: public, VersionConstraint: "<2"}, expectErrText: "outside the allowed versions"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var applied string
+			ApplyUpdateFile = func(path, to string) error {
+				data, err := os.ReadFile(path)
+				applied = string(data)
+				return err
+			}
+			path := writeBundle(t, t.TempDir(), tc.version, "agent "+tc.version, private)
+			if tc.tamper {
+				rewriteBundle(t, path, func(name string, data []byte) []byte {
+					if strings.HasSuffix(name, "/agent") {
+						return []byte("agent v6.6.6")
+					}
+					return data
+				})
+			}
+
+			config := tc.config
+			config.TargetPath = filepath.Join(t.TempDir(), "agent")
+			config.StatePath = filepath.Join(t.TempDir(), "state.json")
+			config.LockPath = filepath.Join(t.TempDir(), "update.lock")
+			service, err := NewUpdateService(config)
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+
+			_, err = service.ApplyBundle(path)
+			switch {
+			case tc.expectErr != nil:
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("expected error %v, got %v", tc.expectErr, err)
+				}
+			case tc.expectErrText != "":
+				if err == nil || !strings.Contains(err.Error(), tc.expectErrText) {
+					t.Errorf("expected error containing %q, got %v", tc.expectErrText, err)
+				}
+			case err != nil:
+				t.Errorf("ApplyBundle failed: %v", err)
+			}
+			if applied != tc.expectApplied {
+				t.Errorf("expected %q to be applied, got %q", tc.expectApplied, applied)
+			}
+			if tc.config.DryRun {
+				report := service.LastDryRun()
+				if report == nil || report.Version != tc.version || report.Size != int64(len("agent "+tc.version)) {
+					t.Errorf("unexpected dry run report %+v", report)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package updater
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StableChannel is the channel of releases without a prerelease version.
+const StableChannel = "stable"
+
+// ChannelRule defines a release channel by the semver prerelease identifiers
+// of the releases it contains. For example, a rule with the identifier "rc"
+// matches v2.0.0-rc.1 and v2.0.0-rc1, but not v2.0.0-rcx.
+type ChannelRule struct {
+	// Name is the channel name, e.g. "beta".
+	Name string
+	// Identifiers are the prerelease identifiers belonging to the channel,
+	// compared case-insensitively. The identifier "*" matches any prerelease.
+	Identifiers []string
+	// Includes lists other channels a subscriber of this channel also
+	// receives, e.g. a beta subscriber may also receive "stable".
+	Includes []string
+}
+
+// ChannelRules is an ordered list of channel rules. A prerelease belongs to
+// the channel of the first rule matching one of its identifiers. Releases
+// without a prerelease version belong to StableChannel.
+type ChannelRules []ChannelRule
+
+// DefaultChannelRules are the channel rules used when none are configured.
+// Prereleases that match no other rule, including GitHub releases flagged as
+// prereleases, are treated as beta. The channels form a hierarchy: alpha
+// subscribers also receive beta and stable releases, and beta subscribers
+// also receive stable releases.
+var DefaultChannelRules = ChannelRules{
+	{Name: "alpha", Identifiers: []string{"alpha", "nightly", "canary", "dev"}, Includes: []string{"beta", StableChannel}},
+	{Name: "beta", Identifiers: []string{"beta", "rc", "*"}, Includes: []string{StableChannel}},
+}
+
+// Channel returns the channel of a release with the given tag. A release
+// flagged as a prerelease is treated as one even if its tag has no prerelease
+// version. Prereleases matching no rule belong to no channel, and an empty
+// string is returned.
+func (rules ChannelRules) Channel(tagName string, isPreRelease bool) string {
+	identifiers := prereleaseIdentifiers(tagName)
+	if len(identifiers) == 0 && !isPreRelease {
+		return StableChannel
+	}
+	for _, rule := range rules {
+		if rule.matches(identifiers) {
+			return rule.Name
+		}
+	}
+	return ""
+}
+
+// ReleaseChannel returns the channel of release: the Channel its source
+// published it to, or else the channel of its tag.
+func (rules ChannelRules) ReleaseChannel(release *Release) string {
+	if release.Channel != "" {
+		return release.Channel
+	}
+	return rules.Channel(release.TagName, release.PreRelease)
+}
+
+// Receives reports whether a subscriber of the subscribed channel receives
+// releases in channel.
+func (rules ChannelRules) Receives(subscribed, channel string) bool {
+	if channel == "" {
+		return false
+	}
+	if subscribed == channel {
+		return true
+	}
+	for _, rule := range rules {
+		if rule.Name != subscribed {
+			continue
+		}
+		for _, included := range rule.Includes {
+			if included == channel {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Has reports whether channel is defined by the rules. StableChannel is always
+// defined.
+func (rules ChannelRules) Has(channel string) bool {
+	if channel == StableChannel {
+		return true
+	}
+	for _, rule := range rules {
+		if rule.Name == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the defined channels, starting with StableChannel.
+func (rules ChannelRules) Names() []string {
+	names := []string{StableChannel}
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+// Validate checks that every rule is named, that names are unique, and that
+// included channels are defined.
+func (rules ChannelRules) Validate() error {
+	seen := map[string]bool{StableChannel: true}
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("channel rule without a name")
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("duplicate channel %q", rule.Name)
+		}
+		seen[rule.Name] = true
+	}
+	for _, rule := range rules {
+		for _, included := range rule.Includes {
+			if !seen[included] {
+				return fmt.Errorf("channel %q includes unknown channel %q", rule.Name, included)
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether any of the prerelease identifiers belongs to the
+// rule. An identifier matches if it equals one of the rule's identifiers,
+// optionally followed by a number, as in "beta2".
+func (rule ChannelRule) matches(identifiers []string) bool {
+	for _, want := range rule.Identifiers {
+		if want == "*" {
+			return true
+		}
+		for _, id := range identifiers {
+			if strings.EqualFold(strings.TrimRight(id, "0123456789"), want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prereleaseIdentifiers returns the dot-separated prerelease identifiers of a
+// version tag, e.g. ["rc", "1"] for v2.0.0-rc.1+build.5.
+func prereleaseIdentifiers(tagName string) []string {
+	version, _, _ := strings.Cut(tagName, "+")
+	_, prerelease, found := strings.Cut(version, "-")
+	if !found || prerelease == "" {
+		return nil
+	}
+	return strings.Split(prerelease, ".")
+}
+
+// determineChannel determines the stability channel of a release based on its
+// tag and PreRelease flag, using DefaultChannelRules.
+func determineChannel(tagName string, isPreRelease bool) string {
+	return DefaultChannelRules.Channel(tagName, isPreRelease)
+}
+
+// CurrentChannel returns the channel this installation subscribes to: the
+// channel saved in the state file of the running executable, or else the
+// channel of the running version. This can be replaced in tests.
+var CurrentChannel = func() (string, error) {
+	path, err := executableStatePath()
+	if err != nil {
+		return "", err
+	}
+	return subscribedChannel(path, DefaultChannelRules, Version)
+}
+
+// SetChannel saves the channel an installation subscribes to in the state file
+// at path. The channel must be one of DefaultChannelRules; an empty channel
+// clears the choice, so that the channel of the running version is used again.
+func SetChannel(path, channel string) error {
+	return saveChannel(path, channel, DefaultChannelRules)
+}
+
+// subscribedChannel returns the channel saved in the state file at path, or the
+// channel of the current version under rules. A prerelease matching no rule
+// is an error, rather than a subscription to StableChannel.
+func subscribedChannel(path string, rules ChannelRules, current string) (string, error) {
+	state, err := LoadState(path)
+	if err != nil {
+		return "", err
+	}
+	if state.Channel != "" {
+		return state.Channel, nil
+	}
+	channel := rules.Channel(current, false)
+	if channel == "" {
+		return "", fmt.Errorf("version %s matches no channel rule; configure a Channel or a rule for it", current)
+	}
+	return channel, nil
+}
+
+// saveChannel saves channel in the state file at path, if it is defined by rules.
+func saveChannel(path, channel string, rules ChannelRules) error {
+	if channel != "" && !rules.Has(channel) {
+		return fmt.Errorf("unknown channel %q, expected one of: %s", channel, strings.Join(rules.Names(), ", "))
+	}
+	state, err := LoadState(path)
+	if err != nil {
+		return err
+	}
+	state.Channel = channel
+	return state.Save(path)
+}
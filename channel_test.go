@@ -0,0 +1,235 @@
+package updater
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetermineChannel(t *testing.T) {
+	testCases := []struct {
+		tag        string
+		preRelease bool
+		expected   string
+	}{
+		{"v1.2.3", false, "stable"},
+		{"v1.2.3+build.7", false, "stable"},
+		{"v1.2.3", true, "beta"},
+		{"v1.2.3-alpha.1", false, "alpha"},
+		{"v1.2.3-alpha.pr.123", true, "alpha"},
+		{"v1.2.3-ALPHA2", false, "alpha"},
+		{"v1.2.3-beta.2", false, "beta"},
+		{"v2.0.0-rc.1", false, "beta"},
+		{"v2.0.0-nightly.20261001", false, "alpha"},
+		{"v2.0.0-canary", false, "alpha"},
+		{"v2.0.0-alphabet.1", false, "beta"},
+		{"v2.0.0-preview", false, "beta"},
+	}
+	for _, tc := range testCases {
+		if got := determineChannel(tc.tag, tc.preRelease); got != tc.expected {
+			t.Errorf("determineChannel(%q, %v): expected %q, got %q", tc.tag, tc.preRelease, tc.expected, got)
+		}
+	}
+}
+
+func TestChannelRules(t *testing.T) {
+	rules := ChannelRules{
+		{Name: "nightly", Identifiers: []string{"nightly"}, Includes: []string{"rc", "stable"}},
+		{Name: "rc", Identifiers: []string{"rc"}, Includes: []string{"stable"}},
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	channels := map[string]string{
+		"v2.0.0":                  "stable",
+		"v2.0.0-rc.1":             "rc",
+		"v2.0.0-nightly.20261001": "nightly",
+		"v2.0.0-beta.1":           "",
+	}
+	for tag, expected := range channels {
+		if got := rules.Channel(tag, false); got != expected {
+			t.Errorf("Channel(%q): expected %q, got %q", tag, expected, got)
+		}
+	}
+
+	receives := []struct {
+		subscribed, channel string
+		expected            bool
+	}{
+		{"nightly", "nightly", true},
+		{"nightly", "rc", true},
+		{"nightly", "stable", true},
+		{"rc", "nightly", false},
+		{"rc", "stable", true},
+		{"stable", "rc", false},
+		{"rc", "", false},
+	}
+	for _, tc := range receives {
+		if got := rules.Receives(tc.subscribed, tc.channel); got != tc.expected {
+			t.Errorf("Receives(%q, %q): expected %v, got %v", tc.subscribed, tc.channel, tc.expected, got)
+		}
+	}
+}
+
+func TestChannelRules_Validate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rules ChannelRules
+	}{
+		{"unnamed", ChannelRules{{Identifiers: []string{"rc"}}}},
+		{"duplicate", ChannelRules{{Name: "rc"}, {Name: "rc"}}},
+		{"stable", ChannelRules{{Name: "stable"}}},
+		{"unknown include", ChannelRules{{Name: "rc", Includes: []string{"beta"}}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.rules.Validate(); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestUpdateService_CustomChannels(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	originalVersion := Version
+	defer func() {
+		NewGithubClient = originalNewGithubClient
+		Version = originalVersion
+	}()
+
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{
+					{TagName: "v2.1.0-nightly.20261002"},
+					{TagName: "v2.0.1"},
+					{TagName: "v2.0.0-rc.1"},
+				}, nil
+			},
+		}
+	}
+	Version = "v2.0.0-rc.1"
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL: "https://github.com/owner/repo",
+		Channels: ChannelRules{
+			{Name: "nightly", Identifiers: []string{"nightly"}, Includes: []string{"rc", "stable"}},
+			{Name: "rc", Identifiers: []string{"rc"}, Includes: []string{"stable"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	// The running rc build subscribes to the rc channel, which also receives stable.
+	release, available, err := service.CheckForNewerVersion()
+	if err != nil {
+		t.Fatalf("CheckForNewerVersion failed: %v", err)
+	}
+	if !available || release.TagName != "v2.0.1" {
+		t.Errorf("expected v2.0.1 to be available, got %v (available %v)", release, available)
+	}
+
+	if _, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:  "https://github.com/owner/repo",
+		Channels: ChannelRules{{Name: "rc", Includes: []string{"beta"}}},
+	}); err == nil {
+		t.Errorf("expected an error for invalid channel rules")
+	}
+}
+
+func TestFilterReleases_Hierarchy(t *testing.T) {
+	releases := []Release{
+		{TagName: "v1.4.0-alpha.1"},
+		{TagName: "v1.3.0"},
+		{TagName: "v1.3.0-beta.2"},
+		{TagName: "v1.2.0"},
+	}
+	testCases := map[string]string{
+		"stable": "v1.3.0",
+		"beta":   "v1.3.0",
+		"alpha":  "v1.4.0-alpha.1",
+	}
+	for channel, expected := range testCases {
+		release := filterReleases(releases, channel)
+		if release == nil || release.TagName != expected {
+			t.Errorf("channel %s: expected %s, got %v", channel, expected, release)
+		}
+	}
+}
+
+func TestSetChannel(t *testing.T) {
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "v1.3.0-beta.2"
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := (&State{InstallID: "abc"}).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	channel, err := subscribedChannel(path, DefaultChannelRules, Version)
+	if err != nil || channel != "beta" {
+		t.Errorf("expected the running version's channel beta, got %q (%v)", channel, err)
+	}
+
+	if err := SetChannel(path, "stable"); err != nil {
+		t.Fatalf("SetChannel failed: %v", err)
+	}
+	channel, err = subscribedChannel(path, DefaultChannelRules, Version)
+	if err != nil || channel != "stable" {
+		t.Errorf("expected the saved channel stable, got %q (%v)", channel, err)
+	}
+	state, _ := LoadState(path)
+	if state.InstallID != "abc" {
+		t.Errorf("expected SetChannel to keep the install ID, got %q", state.InstallID)
+	}
+
+	if err := SetChannel(path, "nightly"); err == nil {
+		t.Errorf("expected an error for an unknown channel")
+	}
+
+	// Under custom rules, a running prerelease matching none of them has no
+	// channel to fall back to.
+	rules := ChannelRules{{Name: "rc", Identifiers: []string{"rc"}, Includes: []string{StableChannel}}}
+	unsaved := filepath.Join(t.TempDir(), "state.json")
+	if channel, err := subscribedChannel(unsaved, rules, "v1.3.0-rc.1"); err != nil || channel != "rc" {
+		t.Errorf("expected the matching channel rc, got %q (%v)", channel, err)
+	}
+	if channel, err := subscribedChannel(unsaved, rules, "v1.3.0-beta.2"); err == nil {
+		t.Errorf("expected an error for a prerelease matching no rule, got %q", channel)
+	}
+}
+
+func TestUpdateService_SavedChannel(t *testing.T) {
+	originalCheckOnly := CheckOnly
+	defer func() { CheckOnly = originalCheckOnly }()
+
+	var checked string
+	CheckOnly = func(owner, repo, channel string, forceSemVerPrefix bool, releaseURLFormat string) error {
+		checked = channel
+		return nil
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        "https://github.com/owner/repo",
+		CheckOnStartup: CheckOnStartup,
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	if err := service.SetChannel("alpha"); err != nil {
+		t.Fatalf("SetChannel failed: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if checked != "alpha" {
+		t.Errorf("expected the saved channel alpha to be checked, got %q", checked)
+	}
+}
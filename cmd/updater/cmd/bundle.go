@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundleKey     string
+	bundleDist    string
+	bundleVersion string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Create and apply signed offline update bundles",
+	Long: `An update bundle is a single archive holding the binaries of one release for
+every platform, their checksums, and an Ed25519 signature over both. Bundles
+carry updates to sites without network access.
+
+Keys are PEM files, e.g. created with:
+
+  openssl genpkey -algorithm ed25519 -out bundle.key
+  openssl pkey -in bundle.key -pubout -out bundle.pub`,
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create FILE",
+	Short: "Build a signed bundle from a release or a goreleaser dist directory",
+	Long: `Builds a signed bundle at FILE from the platform binaries of a release. With
+--dist, the binaries are taken from a local goreleaser dist directory.
+Otherwise they are downloaded from the GitHub release given by --version, or
+the latest release of the tracked channel.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, "creating bundle", func() (*result, error) {
+			data, err := os.ReadFile(bundleKey)
+			if err != nil {
+				return nil, fmt.Errorf("error reading signing key: %w", err)
+			}
+			key, err := updater.ParsePrivateKey(data)
+			if err != nil {
+				return nil, err
+			}
+
+			var contents *updater.BundleContents
+			if bundleDist != "" {
+				contents, err = updater.DistBundleContents(bundleDist)
+			} else {
+				dir, derr := os.MkdirTemp("", "updater-bundle-")
+				if derr != nil {
+					return nil, fmt.Errorf("error creating download directory: %w", derr)
+				}
+				defer os.RemoveAll(dir)
+				contents, err = downloadBundleContents(cmd, dir)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			f, err := os.Create(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("error creating bundle: %w", err)
+			}
+			manifest, err := updater.CreateBundle(f, contents.Name, contents.Version, contents.Files, key)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(args[0])
+				return nil, err
+			}
+
+			res := newResult()
+			res.LatestVersion = manifest.Version
+			res.Bundle = newBundleDocument(args[0], manifest)
+			res.Actions = append(res.Actions, actionCreated)
+			return res, nil
+		}, func(out io.Writer, res *result) { writeBundle(out, "Created", res.Bundle) })
+	},
+}
+
+// downloadBundleContents downloads the binaries of the release given by
+// --version, or else the latest release, into dir.
+func downloadBundleContents(cmd *cobra.Command, dir string) (*updater.BundleContents, error) {
+	service, err := newService(cmd, updater.UpdateServiceConfig{})
+	if err != nil {
+		return nil, err
+	}
+	version := bundleVersion
+	if version == "" {
+		status, err := service.Status()
+		if err != nil {
+			return nil, err
+		}
+		version = status.Latest
+	}
+	return service.DownloadBundleContents(version, dir)
+}
+
+var bundleApplyCmd = &cobra.Command{
+	Use:   "apply FILE",
+	Short: "Verify a bundle and install its binary for this platform",
+	Long: `Verifies the signature of the bundle at FILE with the public key given by
+--public-key or the public_key setting, and installs the binary for this
+platform. Versions older than the installed one require --allow-downgrade.
+
+Exits with status 20 if the update was applied, 10 if --dry-run verified it,
+or 41 if the bundle is invalid.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, "applying bundle", func() (*result, error) {
+			service, err := newService(cmd, updater.UpdateServiceConfig{AllowDowngrade: allowDowngrade})
+			if err != nil {
+				return nil, err
+			}
+			current, err := service.CurrentVersion()
+			if err != nil {
+				return nil, err
+			}
+			res := newResult()
+			res.CurrentVersion = current
+			manifest, err := service.ApplyBundle(args[0])
+			if manifest == nil {
+				return res, err
+			}
+			res.LatestVersion = manifest.Version
+			res.Bundle = newBundleDocument(args[0], manifest)
+			if err != nil {
+				return res, err
+			}
+			if !sameVersion(current, manifest.Version) {
+				res.setDelivered(service.LastDryRun())
+			}
+			return res, nil
+		}, func(io.Writer, *result) {})
+	},
+}
+
+// bundleDocument describes a bundle in machine-readable output.
+type bundleDocument struct {
+	Path     string                 `json:"path" yaml:"path"`
+	Name     string                 `json:"name,omitempty" yaml:"name,omitempty"`
+	Version  string                 `json:"version" yaml:"version"`
+	Binaries []bundleBinaryDocument `json:"binaries" yaml:"binaries"`
+}
+
+// bundleBinaryDocument describes one platform binary of a bundle.
+type bundleBinaryDocument struct {
+	OS     string `json:"os" yaml:"os"`
+	Arch   string `json:"arch" yaml:"arch"`
+	Path   string `json:"path" yaml:"path"`
+	Size   int64  `json:"size" yaml:"size"`
+	SHA256 string `json:"sha256" yaml:"sha256"`
+}
+
+// newBundleDocument describes the bundle at path with manifest.
+func newBundleDocument(path string, manifest *updater.BundleManifest) *bundleDocument {
+	document := &bundleDocument{
+		Path:     path,
+		Name:     manifest.Name,
+		Version:  manifest.Version,
+		Binaries: []bundleBinaryDocument{},
+	}
+	for _, binary := range manifest.Binaries {
+		document.Binaries = append(document.Binaries, bundleBinaryDocument(binary))
+	}
+	return document
+}
+
+// writeBundle prints the release of a bundle and one row per binary.
+func writeBundle(out io.Writer, verb string, bundle *bundleDocument) {
+	fmt.Fprintf(out, "%s %s: %s %s\n\n", verb, bundle.Path, orDash(bundle.Name), bundle.Version)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PLATFORM\tSIZE\tSHA256\tPATH")
+	for _, binary := range bundle.Binaries {
+		fmt.Fprintf(w, "%s/%s\t%d\t%s\t%s\n", binary.OS, binary.Arch, binary.Size, binary.SHA256, binary.Path)
+	}
+	w.Flush()
+}
+
+// addBundleCreateFlags adds the flags selecting the release and key of
+// bundle create.
+func addBundleCreateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&bundleKey, "key", "", "PEM file with the Ed25519 private key signing the bundle")
+	cmd.Flags().StringVar(&bundleDist, "dist", "", "Take the binaries from a goreleaser dist directory instead of a release")
+	cmd.Flags().StringVar(&bundleVersion, "version", "", "Release to bundle, e.g. v1.2.3 (default: the latest release)")
+	cmd.MarkFlagRequired("key")
+	cmd.MarkFlagsMutuallyExclusive("dist", "version")
+}
+
+// addBundleApplyFlags adds the flags of bundle apply. The update source
+// flags do not apply to bundles, except for the binary to update.
+func addBundleApplyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&target, "target", "", "Update the binary at this path instead of the running executable")
+	cmd.Flags().String("public-key", "", "Ed25519 public key verifying the bundle, as a PEM file or base64")
+	cmd.Flags().BoolVar(&allowDowngrade, "allow-downgrade", false, "Allow installing a version older than the current one")
+}
+
+func init() {
+	addSourceFlags(bundleCreateCmd)
+	addBundleCreateFlags(bundleCreateCmd)
+	addOutputFlag(bundleCreateCmd)
+	addApplyFlags(bundleApplyCmd)
+	addBundleApplyFlags(bundleApplyCmd)
+	addOutputFlag(bundleApplyCmd)
+	bundleCmd.AddCommand(bundleCreateCmd, bundleApplyCmd)
+	rootCmd.AddCommand(bundleCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var channelCmd = &cobra.Command{
+	Use:   "channel [stable|beta|alpha]",
+	Short: "Show or change the update channel",
+	Long: `Without arguments, prints the channel this installation tracks. With a channel
+name, saves it as the channel to track when --channel is not given. Alpha
+subscribers also receive beta and stable releases, and beta subscribers also
+receive stable releases. Use "auto" to go back to the channel of the running
+version. The channel is saved for the binary named by --target, or for the
+running executable.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, "reading channel", func() (*result, error) {
+			service, err := newService(cmd, updater.UpdateServiceConfig{})
+			if err != nil {
+				return nil, err
+			}
+
+			if len(args) == 1 {
+				selected := args[0]
+				if selected == "auto" {
+					selected = ""
+				}
+				if err := service.SetChannel(selected); err != nil {
+					return nil, fmt.Errorf("error setting channel: %w", err)
+				}
+			}
+
+			res := newResult()
+			if res.Channel, err = service.Channel(); err != nil {
+				return nil, err
+			}
+			return res, nil
+		}, func(out io.Writer, res *result) { fmt.Fprintln(out, res.Channel) })
+	},
+}
+
+func init() {
+	addSourceFlags(channelCmd)
+	addOutputFlag(channelCmd)
+	rootCmd.AddCommand(channelCmd)
+}
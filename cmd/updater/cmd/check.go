@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check for a newer release without applying it",
+	Long: `Checks for a newer release in the tracked channel without applying it. Exits
+with status 10 if an update is available, or 11 if the installed version is
+below the minimum supported version.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runCheck(cmd)
+	},
+}
+
+// runCheck checks for a newer release with the settings of cmd. It is shared by
+// check and the deprecated --check-update flag of the root command.
+func runCheck(cmd *cobra.Command) {
+	run(cmd, "checking for updates", func() (*result, error) {
+		service, err := newService(cmd, updater.UpdateServiceConfig{})
+		if err != nil {
+			return nil, err
+		}
+		status, err := service.Status()
+		if err != nil {
+			return nil, err
+		}
+		res := newResult()
+		res.setStatus(status)
+		switch {
+		case status.Required:
+			res.ExitCode = exitUpdateRequired
+		case status.UpdateAvailable:
+			res.ExitCode = exitUpdateAvailable
+		}
+		return res, nil
+	}, writeStatus)
+}
+
+// writeStatus prints the update status recorded in res.
+func writeStatus(out io.Writer, res *result) {
+	switch {
+	case res.UpdateRequired:
+		fmt.Fprintf(out, "Update required: %s (current version: %s)\n", res.LatestVersion, res.CurrentVersion)
+	case res.UpdateAvailable != nil && *res.UpdateAvailable:
+		fmt.Fprintf(out, "New release found: %s (current version: %s)\n", res.LatestVersion, res.CurrentVersion)
+	default:
+		fmt.Fprintf(out, "You are running the latest version: %s\n", res.CurrentVersion)
+	}
+	if res.PendingVersion != "" {
+		fmt.Fprintf(out, "Release %s is being rolled out and not yet offered to this installation.\n", res.PendingVersion)
+	}
+}
+
+func init() {
+	addSourceFlags(checkCmd)
+	addOutputFlag(checkCmd)
+	rootCmd.AddCommand(checkCmd)
+}
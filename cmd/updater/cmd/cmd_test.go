@@ -2,8 +2,21 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/snider/updater"
 	"github.com/spf13/cobra"
@@ -23,6 +36,12 @@ func execute(t *testing.T, c *cobra.Command, args ...string) (string, error) {
 }
 
 func TestRootCmd(t *testing.T) {
+	// Keep state, locks and settings out of the real config and cache directories
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
 	// This function creates a new rootCmd for each test case
 	newRootCmd := func() *cobra.Command {
 		// Re-create the command to get a fresh set of flags
@@ -33,24 +52,26 @@ func TestRootCmd(t *testing.T) {
 			Run:   rootCmd.Run, // Use the original Run function
 		}
 		// Initialize flags for this new command
+		addSourceFlags(root)
+		addApplyFlags(root)
 		root.Flags().BoolVar(&checkUpdate, "check-update", false, "Check for new updates")
 		root.Flags().BoolVar(&doUpdate, "do-update", false, "Perform an update")
-		root.Flags().StringVar(&channel, "channel", "", "Set the update channel (stable, beta, alpha). If not set, it's determined from the version tag.")
-		root.Flags().BoolVar(&forceSemVerPrefix, "force-semver-prefix", true, "Force 'v' prefix on semver tags")
-		root.Flags().StringVar(&releaseURLFormat, "release-url-format", "", "A URL format for release assets, with {os}, {arch}, and {tag} as placeholders")
+		root.Flags().IntVar(&pullRequest, "pull-request", 0, "Update to a specific pull request")
+		root.Flags().StringVar(&toVersion, "to-version", "", "Install an exact release version, e.g. v1.2.3")
+		root.Flags().BoolVar(&allowDowngrade, "allow-downgrade", false, "Allow --to-version to install a version older than the current one")
 		root.Version = updater.Version
 		return root
 	}
 
 	testCases := []struct {
-		name            string
-		args            []string
-		checkOnlyCalls  int
-		checkAndDoCalls int
-		checkOnlyByTag  int
-		checkAndDoByTag int
-		expectOutput    string
-		expectError     bool
+		name         string
+		args         []string
+		env          map[string]string
+		configFile   string
+		expectCalls  []string
+		expectOutput string
+		expectExit   int
+		expectError  bool
 	}{
 		{
 			name:         "No flags (prints version)",
@@ -58,24 +79,67 @@ func TestRootCmd(t *testing.T) {
 			expectOutput: "1.2.3", // Default version
 		},
 		{
-			name:           "check-update flag with channel",
-			args:           []string{"--check-update", "--channel=stable"},
-			checkOnlyCalls: 1,
+			name:         "check-update flag with channel",
+			args:         []string{"--check-update", "--channel=beta"},
+			expectOutput: "New release found: v1.3.0-beta.1 (current version: v1.2.0)",
+			expectExit:   exitUpdateAvailable,
+		},
+		{
+			name:         "check-update flag no channel",
+			args:         []string{"--check-update"},
+			expectOutput: "You are running the latest version: v1.2.0",
+		},
+		{
+			name:         "check-update flag with channel from the environment",
+			args:         []string{"--check-update"},
+			env:          map[string]string{"UPDATER_CHANNEL": "beta"},
+			expectOutput: "New release found: v1.3.0-beta.1 (current version: v1.2.0)",
+			expectExit:   exitUpdateAvailable,
+		},
+		{
+			name:         "check-update flag with channel from a config file",
+			args:         []string{"--check-update"},
+			configFile:   "channel: beta\n",
+			expectOutput: "New release found: v1.3.0-beta.1 (current version: v1.2.0)",
+			expectExit:   exitUpdateAvailable,
+		},
+		{
+			name:        "do-update flag with channel",
+			args:        []string{"--do-update", "--channel=beta"},
+			expectCalls: []string{"DownloadUpdate https://example.com/app", "ApplyUpdateFile"},
+			expectExit:  exitUpdateApplied,
+		},
+		{
+			name: "do-update flag no channel",
+			args: []string{"--do-update"},
+		},
+		{
+			name:        "to-version flag",
+			args:        []string{"--to-version=v1.1.0", "--allow-downgrade"},
+			expectCalls: []string{"DownloadUpdate https://example.com/app", "ApplyUpdateFile"},
+			expectExit:  exitUpdateApplied,
+		},
+		{
+			name:         "to-version flag without allow-downgrade",
+			args:         []string{"--to-version=v1.1.0"},
+			expectOutput: "Error performing update: downgrade not allowed",
+			expectExit:   exitDowngradeNotAllowed,
 		},
 		{
-			name:            "do-update flag with channel",
-			args:            []string{"--do-update", "--channel=stable"},
-			checkAndDoCalls: 1,
+			name:        "pull-request flag",
+			args:        []string{"--pull-request=7"},
+			expectCalls: []string{"DownloadUpdate https://example.com/app", "ApplyUpdateFile"},
+			expectExit:  exitUpdateApplied,
 		},
 		{
-			name:           "check-update flag no channel",
-			args:           []string{"--check-update"},
-			checkOnlyByTag: 1,
+			name:         "target flag without action (prints version)",
+			args:         []string{"--target=/opt/agent"},
+			expectOutput: "1.2.3",
 		},
 		{
-			name:            "do-update flag no channel",
-			args:            []string{"--do-update"},
-			checkAndDoByTag: 1,
+			name:         "elevate flag without action (prints version)",
+			args:         []string{"--elevate=sudo"},
+			expectOutput: "1.2.3",
 		},
 		{
 			name:         "Version flag",
@@ -91,38 +155,58 @@ func TestRootCmd(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			var checkOnlyCalls, checkAndDoCalls, checkOnlyByTagCalls, checkAndDoByTagCalls int
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			configFile := filepath.Join(configHome, "updater", "config.yaml")
+			if tc.configFile != "" {
+				if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+					t.Fatalf("failed to create config directory: %v", err)
+				}
+				if err := os.WriteFile(configFile, []byte(tc.configFile), 0644); err != nil {
+					t.Fatalf("failed to write config file: %v", err)
+				}
+				defer os.Remove(configFile)
+			}
+
+			cmd := newRootCmd()
+
+			var calls []string
+			exitCode := exitOK
 
 			// Mock the updater functions
-			originalCheckOnly := updater.CheckOnly
-			updater.CheckOnly = func(owner, repo, channel string, forceSemVerPrefix bool, releaseURLFormat string) error {
-				checkOnlyCalls++
-				return nil
-			}
-			defer func() { updater.CheckOnly = originalCheckOnly }()
+			originalExit := exit
+			originalVersion := updater.Version
+			originalNewGithubClient := updater.NewGithubClient
+			originalDownloadUpdate := updater.DownloadUpdate
+			originalApplyUpdateFile := updater.ApplyUpdateFile
+			defer func() {
+				exit = originalExit
+				updater.Version = originalVersion
+				updater.NewGithubClient = originalNewGithubClient
+				updater.DownloadUpdate = originalDownloadUpdate
+				updater.ApplyUpdateFile = originalApplyUpdateFile
+			}()
 
-			originalCheckForUpdates := updater.CheckForUpdates
-			updater.CheckForUpdates = func(owner, repo, channel string, forceSemVerPrefix bool, releaseURLFormat string) error {
-				checkAndDoCalls++
-				return nil
+			exit = func(code int) { exitCode = code }
+			updater.Version = "v1.2.0"
+			assets := []updater.ReleaseAsset{{Name: fmt.Sprintf("app-%s-%s", runtime.GOOS, runtime.GOARCH), DownloadURL: "https://example.com/app"}}
+			updater.NewGithubClient = func() updater.GithubClient {
+				return &fakeGithubClient{releases: []updater.Release{
+					{TagName: "v1.3.0-beta.1", PreRelease: true, Assets: assets},
+					{TagName: "v1.2.0", Assets: assets},
+					{TagName: "v1.1.0", Assets: assets},
+				}}
 			}
-			defer func() { updater.CheckForUpdates = originalCheckForUpdates }()
-
-			originalCheckOnlyByTag := updater.CheckOnlyByTag
-			updater.CheckOnlyByTag = func(owner, repo string) error {
-				checkOnlyByTagCalls++
-				return nil
+			updater.DownloadUpdate = func(url, path string) error {
+				calls = append(calls, "DownloadUpdate "+url)
+				return os.WriteFile(path, []byte("update"), 0755)
 			}
-			defer func() { updater.CheckOnlyByTag = originalCheckOnlyByTag }()
-
-			originalCheckForUpdatesByTag := updater.CheckForUpdatesByTag
-			updater.CheckForUpdatesByTag = func(owner, repo string) error {
-				checkAndDoByTagCalls++
+			updater.ApplyUpdateFile = func(path, target string) error {
+				calls = append(calls, "ApplyUpdateFile")
 				return nil
 			}
-			defer func() { updater.CheckForUpdatesByTag = originalCheckForUpdatesByTag }()
 
-			cmd := newRootCmd()
 			output, err := execute(t, cmd, tc.args...)
 
 			if (err != nil) != tc.expectError {
@@ -132,18 +216,635 @@ func TestRootCmd(t *testing.T) {
 			if tc.expectOutput != "" && !strings.Contains(output, tc.expectOutput) {
 				t.Errorf("Expected output to contain: %q, got: %q", tc.expectOutput, output)
 			}
+			if strings.Join(calls, ",") != strings.Join(tc.expectCalls, ",") {
+				t.Errorf("Expected calls %v, got %v", tc.expectCalls, calls)
+			}
+			if exitCode != tc.expectExit {
+				t.Errorf("Expected exit code %d, got %d", tc.expectExit, exitCode)
+			}
+		})
+	}
+}
+
+func TestChannelCmd(t *testing.T) {
+	// Keep the saved channel out of the real config directory
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	newChannelCmd := func() *cobra.Command {
+		c := &cobra.Command{
+			Use:  channelCmd.Use,
+			Args: channelCmd.Args,
+			Run:  channelCmd.Run,
+		}
+		addSourceFlags(c)
+		return c
+	}
+
+	steps := []struct {
+		args         []string
+		expectOutput string
+	}{
+		{args: []string{}, expectOutput: "stable"},
+		{args: []string{"beta"}, expectOutput: "beta"},
+		{args: []string{}, expectOutput: "beta"},
+		{args: []string{"auto"}, expectOutput: "stable"},
+	}
+	for _, step := range steps {
+		output, err := execute(t, newChannelCmd(), step.args...)
+		if err != nil {
+			t.Fatalf("channel %v failed: %v", step.args, err)
+		}
+		if output != step.expectOutput {
+			t.Errorf("channel %v: expected output %q, got %q", step.args, step.expectOutput, output)
+		}
+	}
+
+	if _, err := execute(t, newChannelCmd(), "beta", "alpha"); err == nil {
+		t.Errorf("expected an error for too many arguments")
+	}
+
+	// The channel of another binary is saved in its own state file.
+	originalDetectVersion := updater.DetectVersion
+	defer func() { updater.DetectVersion = originalDetectVersion }()
+	updater.DetectVersion = func(path string) (string, error) { return "v1.2.0-beta.1", nil }
+	target := filepath.Join(t.TempDir(), "agent")
+	if err := os.WriteFile(target, []byte("agent"), 0755); err != nil {
+		t.Fatalf("failed to write target: %v", err)
+	}
+	targetSteps := []struct {
+		args         []string
+		expectOutput string
+	}{
+		{args: []string{"--target", target}, expectOutput: "beta"},
+		{args: []string{"--target", target, "alpha"}, expectOutput: "alpha"},
+		{args: []string{}, expectOutput: "stable"},
+		{args: []string{"--target", target}, expectOutput: "alpha"},
+	}
+	for _, step := range targetSteps {
+		output, err := execute(t, newChannelCmd(), step.args...)
+		if err != nil {
+			t.Fatalf("channel %v failed: %v", step.args, err)
+		}
+		if output != step.expectOutput {
+			t.Errorf("channel %v: expected output %q, got %q", step.args, step.expectOutput, output)
+		}
+	}
+}
+
+func TestWriteSyncSummary(t *testing.T) {
+	var out bytes.Buffer
+	writeSyncSummary(&out, []updater.SyncResult{
+		{Product: "agent", Previous: "1.2.0", Current: "1.3.0"},
+		{Product: "helper", Previous: "2.0.0", Current: "2.0.0"},
+		{Product: "monitor", Err: errors.New("not installed")},
+	})
+
+	expected := `PRODUCT  PREVIOUS  CURRENT  STATUS
+agent    1.2.0     1.3.0    updated
+helper   2.0.0     2.0.0    up-to-date
+monitor  -         -        failed: not installed
+`
+	if out.String() != expected {
+		t.Errorf("unexpected summary:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+// fakeGithubClient serves a fixed list of releases.
+type fakeGithubClient struct {
+	releases []updater.Release
+}
+
+func (c *fakeGithubClient) GetPublicRepos(ctx context.Context, userOrOrg string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *fakeGithubClient) GetLatestRelease(ctx context.Context, owner, repo, channel string) (*updater.Release, error) {
+	return &c.releases[0], nil
+}
+
+func (c *fakeGithubClient) GetReleaseByPullRequest(ctx context.Context, owner, repo string, prNumber int) (*updater.Release, error) {
+	return &c.releases[0], nil
+}
+
+func (c *fakeGithubClient) ListReleases(ctx context.Context, owner, repo string) ([]updater.Release, error) {
+	return c.releases, nil
+}
+
+func TestSubcommands(t *testing.T) {
+	// Keep state and locks out of the real config and cache directories
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	newSubCmd := func(base *cobra.Command, addFlags ...func(*cobra.Command)) func() *cobra.Command {
+		return func() *cobra.Command {
+			c := &cobra.Command{Use: base.Use, Args: base.Args, Run: base.Run}
+			for _, add := range addFlags {
+				add(c)
+			}
+			addOutputFlag(c)
+			return c
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		cmd          func() *cobra.Command
+		args         []string
+		expectCalls  []string
+		expectOutput string
+		expectExit   int
+	}{
+		{
+			name:         "check up to date",
+			cmd:          newSubCmd(checkCmd, addSourceFlags),
+			args:         []string{"--repo=https://github.com/owner/repo"},
+			expectOutput: "You are running the latest version: v1.2.0",
+		},
+		{
+			name:         "check update available",
+			cmd:          newSubCmd(checkCmd, addSourceFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--channel=beta"},
+			expectOutput: "New release found: v1.3.0-beta.1 (current version: v1.2.0)",
+			expectExit:   exitUpdateAvailable,
+		},
+		{
+			name:         "check json",
+			cmd:          newSubCmd(checkCmd, addSourceFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--channel=beta", "--output=json"},
+			expectOutput: `"latest_version": "v1.3.0-beta.1"`,
+			expectExit:   exitUpdateAvailable,
+		},
+		{
+			name:         "check yaml",
+			cmd:          newSubCmd(checkCmd, addSourceFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "-o", "yaml"},
+			expectOutput: "update_available: false",
+		},
+		{
+			name:         "invalid output format",
+			cmd:          newSubCmd(checkCmd, addSourceFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--output=xml"},
+			expectOutput: `invalid output format "xml"`,
+			expectExit:   exitUsage,
+		},
+		{
+			name:         "update",
+			cmd:          newSubCmd(updateCmd, addSourceFlags, addApplyFlags, addUpdateFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--channel=beta", "--output=json"},
+			expectCalls:  []string{"DownloadUpdate https://example.com/app", "ApplyUpdateFile"},
+			expectOutput: `"applied"`,
+			expectExit:   exitUpdateApplied,
+		},
+		{
+			name: "update up to date",
+			cmd:  newSubCmd(updateCmd, addSourceFlags, addApplyFlags, addUpdateFlags),
+			args: []string{"--repo=https://github.com/owner/repo"},
+		},
+		{
+			name:        "update to version",
+			cmd:         newSubCmd(updateCmd, addSourceFlags, addApplyFlags, addUpdateFlags),
+			args:        []string{"--repo=https://github.com/owner/repo", "--version=v1.1.0", "--allow-downgrade"},
+			expectCalls: []string{"DownloadUpdate https://example.com/app", "ApplyUpdateFile"},
+			expectExit:  exitUpdateApplied,
+		},
+		{
+			name:         "update to older version",
+			cmd:          newSubCmd(updateCmd, addSourceFlags, addApplyFlags, addUpdateFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--version=v1.1.0", "--output=json"},
+			expectOutput: `"code": "downgrade_not_allowed"`,
+			expectExit:   exitDowngradeNotAllowed,
+		},
+		{
+			name:        "update to pull request",
+			cmd:         newSubCmd(updateCmd, addSourceFlags, addApplyFlags, addUpdateFlags),
+			args:        []string{"--repo=https://github.com/owner/repo", "--pull-request=7"},
+			expectCalls: []string{"DownloadUpdate https://example.com/app", "ApplyUpdateFile"},
+			expectExit:  exitUpdateApplied,
+		},
+		{
+			name:         "rollback",
+			cmd:          newSubCmd(rollbackCmd, addSourceFlags, addApplyFlags),
+			args:         []string{"--repo=https://github.com/owner/repo"},
+			expectCalls:  []string{"DownloadUpdate https://example.com/app", "ApplyUpdateFile"},
+			expectOutput: "Rolled back to v1.1.0 (from v1.2.0).",
+			expectExit:   exitUpdateApplied,
+		},
+		{
+			name:         "rollback json",
+			cmd:          newSubCmd(rollbackCmd, addSourceFlags, addApplyFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--output=json"},
+			expectCalls:  []string{"DownloadUpdate https://example.com/app", "ApplyUpdateFile"},
+			expectOutput: `"latest_version": "v1.1.0"`,
+			expectExit:   exitUpdateApplied,
+		},
+		{
+			name:         "rollback failure",
+			cmd:          newSubCmd(rollbackCmd, addSourceFlags, addApplyFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "v0.9.0", "--output=json"},
+			expectOutput: `"message": "release v0.9.0 not found"`,
+			expectExit:   exitFailure,
+		},
+		{
+			name:         "rollback to newer release",
+			cmd:          newSubCmd(rollbackCmd, addSourceFlags, addApplyFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "v1.3.0-beta.1"},
+			expectOutput: "Error rolling back: cannot roll back to v1.3.0-beta.1: it is not older than the current version v1.2.0",
+			expectExit:   exitFailure,
+		},
+		{
+			name:         "releases",
+			cmd:          newSubCmd(releasesCmd, addSourceFlags, addReleaseFilterFlags),
+			args:         []string{"--repo=https://github.com/owner/repo"},
+			expectOutput: "v1.3.0-beta.1  beta     true        2025-03-01  yes",
+		},
+		{
+			name:         "releases json",
+			cmd:          newSubCmd(releasesCmd, addSourceFlags, addReleaseFilterFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--output=json"},
+			expectOutput: `"tag": "v1.1.0"`,
+		},
+		{
+			name:         "releases by channel",
+			cmd:          newSubCmd(releasesCmd, addSourceFlags, addReleaseFilterFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--channel=stable", "--output=json"},
+			expectOutput: `"tag": "v1.2.0"`,
+		},
+		{
+			name:         "releases by constraint",
+			cmd:          newSubCmd(releasesCmd, addSourceFlags, addReleaseFilterFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "--constraint=<1.2", "--output=yaml"},
+			expectOutput: "releases:\n  - tag: v1.1.0\n    channel: stable",
+		},
+		{
+			name:         "release details",
+			cmd:          newSubCmd(releasesCmd, addSourceFlags, addReleaseFilterFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "1.2.0"},
+			expectOutput: "*         -     https://example.com/app",
+		},
+		{
+			name:         "release notes",
+			cmd:          newSubCmd(releasesCmd, addSourceFlags, addReleaseFilterFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "v1.2.0"},
+			expectOutput: "https://example.com/checksums.txt\n\n- Fixed --target",
+		},
+		{
+			name:         "release author",
+			cmd:          newSubCmd(releasesCmd, addSourceFlags, addReleaseFilterFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "v1.2.0", "--output=json"},
+			expectOutput: `"author": "octocat"`,
+		},
+		{
+			name:         "release not found",
+			cmd:          newSubCmd(releasesCmd, addSourceFlags, addReleaseFilterFlags),
+			args:         []string{"--repo=https://github.com/owner/repo", "v9.9.9"},
+			expectOutput: "Error listing releases: release v9.9.9 not found",
+			expectExit:   exitFailure,
+		},
+		{
+			name:         "too many arguments",
+			cmd:          newSubCmd(checkCmd, addSourceFlags),
+			args:         []string{"extra"},
+			expectOutput: "unknown command",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls []string
+			exitCode := exitOK
+
+			originalExit := exit
+			originalVersion := updater.Version
+			originalNewGithubClient := updater.NewGithubClient
+			originalDownloadUpdate := updater.DownloadUpdate
+			originalApplyUpdateFile := updater.ApplyUpdateFile
+			defer func() {
+				exit = originalExit
+				updater.Version = originalVersion
+				updater.NewGithubClient = originalNewGithubClient
+				updater.DownloadUpdate = originalDownloadUpdate
+				updater.ApplyUpdateFile = originalApplyUpdateFile
+			}()
+
+			exit = func(code int) { exitCode = code }
+			updater.Version = "v1.2.0"
+			digest := sha256.Sum256([]byte("update"))
+			assets := []updater.ReleaseAsset{
+				{Name: fmt.Sprintf("app-%s-%s", runtime.GOOS, runtime.GOARCH), DownloadURL: "https://example.com/app", Digest: "sha256:" + hex.EncodeToString(digest[:])},
+				{Name: "checksums.txt", DownloadURL: "https://example.com/checksums.txt"},
+			}
+			updater.NewGithubClient = func() updater.GithubClient {
+				return &fakeGithubClient{releases: []updater.Release{
+					{TagName: "v1.3.0-beta.1", PreRelease: true, Assets: assets, PublishedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)},
+					{TagName: "v1.2.0", Assets: assets, Author: updater.ReleaseAuthor{Login: "octocat"}, Body: "<!-- updater:min-version=1.0.0 -->\n- Fixed --target"},
+					{TagName: "v1.1.0", Assets: assets},
+				}}
+			}
+			updater.DownloadUpdate = func(url, path string) error {
+				calls = append(calls, "DownloadUpdate "+url)
+				return os.WriteFile(path, []byte("update"), 0755)
+			}
+			updater.ApplyUpdateFile = func(path, target string) error {
+				calls = append(calls, "ApplyUpdateFile")
+				return nil
+			}
 
-			if checkOnlyCalls != tc.checkOnlyCalls {
-				t.Errorf("Expected CheckOnly calls: %d, got: %d", tc.checkOnlyCalls, checkOnlyCalls)
+			output, _ := execute(t, tc.cmd(), tc.args...)
+			if fmt.Sprint(calls) != fmt.Sprint(tc.expectCalls) {
+				t.Errorf("expected calls %v, got %v", tc.expectCalls, calls)
 			}
-			if checkAndDoCalls != tc.checkAndDoCalls {
-				t.Errorf("Expected CheckForUpdates calls: %d, got: %d", tc.checkAndDoCalls, checkAndDoCalls)
+			if tc.expectOutput != "" && !strings.Contains(output, tc.expectOutput) {
+				t.Errorf("expected output to contain %q, got %q", tc.expectOutput, output)
 			}
-			if checkOnlyByTagCalls != tc.checkOnlyByTag {
-				t.Errorf("Expected CheckOnlyByTag calls: %d, got: %d", tc.checkOnlyByTag, checkOnlyByTagCalls)
+			if exitCode != tc.expectExit {
+				t.Errorf("expected exit code %d, got %d", tc.expectExit, exitCode)
+			}
+		})
+	}
+}
+
+func TestWriteVerification(t *testing.T) {
+	testCases := []struct {
+		actual   string
+		expected string
+	}{
+		{actual: "abc", expected: "OK: the installed binary matches the release asset."},
+		{actual: "def", expected: "MISMATCH: the installed binary differs from the release asset."},
+	}
+	for _, tc := range testCases {
+		var out bytes.Buffer
+		writeVerification(&out, &updater.Verification{Path: "/opt/agent", Version: "v1.2.0", Asset: "agent", Expected: "abc", Actual: tc.actual})
+		if !strings.HasSuffix(strings.TrimSpace(out.String()), tc.expected) {
+			t.Errorf("expected output ending with %q, got:\n%s", tc.expected, out.String())
+		}
+	}
+}
+
+func TestProgressOutput(t *testing.T) {
+	defer func() { outputFormat = outputText }()
+	var stdout, stderr bytes.Buffer
+	c := &cobra.Command{}
+	c.SetOut(&stdout)
+	c.SetErr(&stderr)
+
+	for _, tc := range []struct {
+		format   string
+		expected *bytes.Buffer
+	}{
+		{outputText, &stdout},
+		{outputJSON, &stderr},
+		{outputYAML, &stderr},
+	} {
+		outputFormat = tc.format
+		if progressOutput(c) != tc.expected {
+			t.Errorf("unexpected progress output for --output=%s", tc.format)
+		}
+	}
+}
+
+func TestConfigShow(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("UPDATER_CHANNEL", "beta")
+	defer func() { outputFormat = outputText }()
+	configPath := filepath.Join(configHome, "updater", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatalf("failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("repo_url: https://updates.example.com/agent\nchannel: stable\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	newConfigShowCmd := func() *cobra.Command {
+		c := &cobra.Command{Use: configShowCmd.Use, Args: configShowCmd.Args, Run: configShowCmd.Run}
+		addSourceFlags(c)
+		addOutputFlag(c)
+		return c
+	}
+
+	output, err := execute(t, newConfigShowCmd(), "--target=/opt/agent")
+	if err != nil {
+		t.Fatalf("config show failed: %v", err)
+	}
+	expected := []string{
+		"repo_url             https://updates.example.com/agent  " + configPath,
+		"channel              beta                               env UPDATER_CHANNEL",
+		"target_path          /opt/agent                         flag --target",
+		"force_semver_prefix  true                               default",
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+
+	output, err = execute(t, newConfigShowCmd(), "--output=json")
+	if err != nil {
+		t.Fatalf("config show failed: %v", err)
+	}
+	if !strings.Contains(output, `"source": "env UPDATER_CHANNEL"`) {
+		t.Errorf("expected the source of the channel in %s", output)
+	}
+
+	t.Setenv("UPDATER_OCI_PASSWORD", "hunter2")
+	output, err = execute(t, newConfigShowCmd())
+	if err != nil {
+		t.Fatalf("config show failed: %v", err)
+	}
+	if strings.Contains(output, "hunter2") || !strings.Contains(output, "********") {
+		t.Errorf("expected the registry password to be masked, got:\n%s", output)
+	}
+}
+
+func TestUpdateFromFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	originalExit := exit
+	originalVersion := updater.Version
+	defer func() {
+		exit = originalExit
+		updater.Version = originalVersion
+	}()
+	updater.Version = "v1.2.0"
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "latest.json"), []byte(`{"version": "1.2.0", "url": "agent"}`), 0644); err != nil {
+		t.Fatalf("failed to write latest.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("offline release"), 0644); err != nil {
+		t.Fatalf("failed to write README: %v", err)
+	}
+
+	newUpdateCmd := func() *cobra.Command {
+		c := &cobra.Command{Use: updateCmd.Use, Args: updateCmd.Args, Run: updateCmd.Run}
+		addSourceFlags(c)
+		addApplyFlags(c)
+		addUpdateFlags(c)
+		addOutputFlag(c)
+		return c
+	}
+
+	testCases := []struct {
+		name         string
+		args         []string
+		expectOutput string
+		expectExit   int
+		expectErr    bool
+	}{
+		{
+			name:         "release directory",
+			args:         []string{"--from-file=" + dir},
+			expectOutput: "You are running the latest version: v1.2.0",
+		},
+		{
+			name:         "latest.json",
+			args:         []string{"--from-file", filepath.Join(dir, "latest.json")},
+			expectOutput: "You are running the latest version: v1.2.0",
+		},
+		{
+			name:         "not a release",
+			args:         []string{"--from-file", filepath.Join(dir, "README")},
+			expectOutput: "is not a release directory or latest.json",
+			expectExit:   exitFailure,
+		},
+		{
+			name:         "missing directory",
+			args:         []string{"--from-file", filepath.Join(dir, "missing")},
+			expectOutput: "error reading update source",
+			expectExit:   exitFailure,
+		},
+		{
+			name:      "with --repo",
+			args:      []string{"--from-file=" + dir, "--repo=https://github.com/owner/repo"},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exitCode := exitOK
+			exit = func(code int) { exitCode = code }
+
+			output, err := execute(t, newUpdateCmd(), tc.args...)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+			if !strings.Contains(output, tc.expectOutput) {
+				t.Errorf("expected output to contain %q, got %q", tc.expectOutput, output)
+			}
+			if exitCode != tc.expectExit {
+				t.Errorf("expected exit code %d, got %d", tc.expectExit, exitCode)
+			}
+		})
+	}
+}
+
+func TestBundleCmd(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	originalExit := exit
+	originalVersion := updater.Version
+	defer func() {
+		exit = originalExit
+		updater.Version = originalVersion
+		outputFormat = outputText
+	}()
+	updater.Version = "v1.2.0"
+
+	public, private, _ := ed25519.GenerateKey(nil)
+	otherPublic, _, _ := ed25519.GenerateKey(nil)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "bundle.key")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	dist := filepath.Join(dir, "dist")
+	binary := filepath.Join(dist, "agent_"+runtime.GOOS+"_"+runtime.GOARCH, "agent")
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatalf("failed to create dist: %v", err)
+	}
+	if err := os.WriteFile(binary, []byte("agent v1.3.0"), 0755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dist, "metadata.json"), []byte(`{"project_name": "agent", "tag": "v1.3.0"}`), 0644); err != nil {
+		t.Fatalf("failed to write metadata.json: %v", err)
+	}
+	artifacts := fmt.Sprintf(`[{"path": "dist/agent_%[1]s_%[2]s/agent", "goos": "%[1]s", "goarch": "%[2]s", "type": "Binary"}]`, runtime.GOOS, runtime.GOARCH)
+	if err := os.WriteFile(filepath.Join(dist, "artifacts.json"), []byte(artifacts), 0644); err != nil {
+		t.Fatalf("failed to write artifacts.json: %v", err)
+	}
+
+	newBundleCmd := func(base *cobra.Command, addFlags ...func(*cobra.Command)) func() *cobra.Command {
+		return func() *cobra.Command {
+			c := &cobra.Command{Use: base.Use, Args: base.Args, Run: base.Run}
+			for _, add := range addFlags {
+				add(c)
+			}
+			addOutputFlag(c)
+			return c
+		}
+	}
+	newCreateCmd := newBundleCmd(bundleCreateCmd, addSourceFlags, addBundleCreateFlags)
+	newApplyCmd := newBundleCmd(bundleApplyCmd, addApplyFlags, addBundleApplyFlags)
+	bundle := filepath.Join(dir, "agent.bundle.tar.gz")
+
+	testCases := []struct {
+		name         string
+		cmd          func() *cobra.Command
+		args         []string
+		expectOutput string
+		expectExit   int
+	}{
+		{
+			name:         "create from dist",
+			cmd:          newCreateCmd,
+			args:         []string{bundle, "--dist=" + dist, "--key=" + keyPath},
+			expectOutput: fmt.Sprintf("Created %s: agent v1.3.0", bundle),
+		},
+		{
+			name:         "create without key file",
+			cmd:          newCreateCmd,
+			args:         []string{filepath.Join(dir, "other.tar.gz"), "--dist=" + dist, "--key=" + keyPath + ".missing"},
+			expectOutput: "error reading signing key",
+			expectExit:   exitFailure,
+		},
+		{
+			name:         "apply dry run",
+			cmd:          newApplyCmd,
+			args:         []string{bundle, "--public-key=" + base64.StdEncoding.EncodeToString(public), "--dry-run", "--output=json"},
+			expectOutput: `"version": "v1.3.0"`,
+			expectExit:   exitUpdateAvailable,
+		},
+		{
+			name:         "apply with another key",
+			cmd:          newApplyCmd,
+			args:         []string{bundle, "--public-key=" + base64.StdEncoding.EncodeToString(otherPublic), "--output=json"},
+			expectOutput: `"code": "invalid_bundle"`,
+			expectExit:   exitInvalidBundle,
+		},
+		{
+			name:         "apply without key",
+			cmd:          newApplyCmd,
+			args:         []string{bundle},
+			expectOutput: "public key is required",
+			expectExit:   exitFailure,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exitCode := exitOK
+			exit = func(code int) { exitCode = code }
+
+			output, _ := execute(t, tc.cmd(), tc.args...)
+			if !strings.Contains(output, tc.expectOutput) {
+				t.Errorf("expected output to contain %q, got %q", tc.expectOutput, output)
 			}
-			if checkAndDoByTagCalls != tc.checkAndDoByTag {
-				t.Errorf("Expected CheckForUpdatesByTag calls: %d, got: %d", tc.checkAndDoByTag, checkAndDoByTagCalls)
+			if exitCode != tc.expectExit {
+				t.Errorf("expected exit code %d, got %d", tc.expectExit, exitCode)
 			}
 		})
 	}
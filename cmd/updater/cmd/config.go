@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the updater configuration",
+	Long: `Settings are read from config.yaml, config.toml or config.json in
+$XDG_CONFIG_HOME/updater (or the user configuration directory of the platform)
+and next to the updater executable, in that order of precedence. UPDATER_*
+environment variables, such as UPDATER_CHANNEL, override the files, and flags
+override both.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration and where each value came from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, "reading configuration", func() (*result, error) {
+			settings, err := loadSettings(cmd)
+			if err != nil {
+				return nil, err
+			}
+			var config updater.UpdateServiceConfig
+			if err := settings.Apply(&config); err != nil {
+				return nil, err
+			}
+			res := newResult()
+			res.Settings = []settingDocument{}
+			for _, setting := range settings.All() {
+				if setting.Key == updater.SettingOCIPassword {
+					setting.Value = "********"
+				}
+				res.Settings = append(res.Settings, settingDocument(setting))
+			}
+			return res, nil
+		}, func(out io.Writer, res *result) { writeSettings(out, res.Settings) })
+	},
+}
+
+// settingDocument describes a configured value in the output of config show.
+type settingDocument struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+// writeSettings prints one row per configured value with its source.
+func writeSettings(out io.Writer, settings []settingDocument) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, setting := range settings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, orDash(setting.Value), setting.Source)
+	}
+	w.Flush()
+}
+
+func init() {
+	addSourceFlags(configShowCmd)
+	addOutputFlag(configShowCmd)
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the installed version, update source and update status",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, "collecting information", func() (*result, error) {
+			config, err := serviceConfig(cmd, updater.UpdateServiceConfig{})
+			if err != nil {
+				return nil, err
+			}
+			info, err := collectInfo(config)
+			if err != nil {
+				return nil, err
+			}
+			res := newResult()
+			res.CurrentVersion = info.Version
+			res.Channel = info.Channel
+			res.Info = info
+			return res, nil
+		}, func(out io.Writer, res *result) { writeInfo(out, res.Info) })
+	},
+}
+
+// installInfo describes an installation and its update source.
+type installInfo struct {
+	Version      string `json:"version" yaml:"version"`
+	Binary       string `json:"binary" yaml:"binary"`
+	Source       string `json:"source" yaml:"source"`
+	Channel      string `json:"channel" yaml:"channel"`
+	Installation string `json:"installation" yaml:"installation"`
+	Staged       string `json:"staged" yaml:"staged"`
+}
+
+// collectInfo gathers the installInfo of the binary updated with config.
+func collectInfo(config updater.UpdateServiceConfig) (*installInfo, error) {
+	service, err := updater.NewUpdateService(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating update service: %w", err)
+	}
+	info := &installInfo{Source: config.RepoURL, Installation: "updatable", Staged: "none"}
+
+	if info.Binary, err = service.TargetPath(); err != nil {
+		return nil, err
+	}
+	if info.Version, err = service.CurrentVersion(); err != nil {
+		return nil, err
+	}
+	if info.Channel, err = service.Channel(); err != nil {
+		return nil, err
+	}
+	if err := updater.CheckInstallation(config.TargetPath); err != nil {
+		info.Installation = err.Error()
+	}
+	staged, err := service.StagedUpdate()
+	if err != nil {
+		return nil, err
+	}
+	if staged != nil {
+		info.Staged = fmt.Sprintf("%s (staged %s)", staged.Version, staged.StagedAt.Format("2006-01-02 15:04"))
+	}
+	return info, nil
+}
+
+// writeInfo prints info as aligned key-value pairs.
+func writeInfo(out io.Writer, info *installInfo) {
+	fmt.Fprintf(out, "Version:      %s\n", info.Version)
+	fmt.Fprintf(out, "Binary:       %s\n", info.Binary)
+	fmt.Fprintf(out, "Source:       %s\n", info.Source)
+	fmt.Fprintf(out, "Channel:      %s\n", orDash(info.Channel))
+	fmt.Fprintf(out, "Installation: %s\n", info.Installation)
+	fmt.Fprintf(out, "Staged:       %s\n", info.Staged)
+}
+
+func init() {
+	addSourceFlags(infoCmd)
+	addOutputFlag(infoCmd)
+	rootCmd.AddCommand(infoCmd)
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes of the subcommands. Scripts can rely on these across releases.
+const (
+	exitOK                  = 0
+	exitFailure             = 1
+	exitUsage               = 2
+	exitUpdateAvailable     = 10
+	exitUpdateRequired      = 11
+	exitUpdateApplied       = 20
+	exitManagedInstall      = 30
+	exitNotWritable         = 31
+	exitDowngradeNotAllowed = 32
+	exitUpdateLocked        = 33
+	exitInvalidStagedUpdate = 34
+	exitVerifyMismatch      = 40
+	exitInvalidBundle       = 41
+)
+
+// Output formats accepted by --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+var outputFormat = outputText
+
+// exit terminates the process with code. This can be replaced in tests.
+var exit = os.Exit
+
+// addOutputFlag adds the --output flag selecting the output format.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text, json or yaml")
+}
+
+// result is the machine-readable document printed by the subcommands with
+// --output json or yaml. Fields are only ever added, never renamed or removed.
+type result struct {
+	Command         string            `json:"command" yaml:"command"`
+	CurrentVersion  string            `json:"current_version,omitempty" yaml:"current_version,omitempty"`
+	LatestVersion   string            `json:"latest_version,omitempty" yaml:"latest_version,omitempty"`
+	Channel         string            `json:"channel,omitempty" yaml:"channel,omitempty"`
+	UpdateAvailable *bool             `json:"update_available,omitempty" yaml:"update_available,omitempty"`
+	UpdateRequired  bool              `json:"update_required,omitempty" yaml:"update_required,omitempty"`
+	PendingVersion  string            `json:"pending_version,omitempty" yaml:"pending_version,omitempty"`
+	AssetURL        string            `json:"asset_url,omitempty" yaml:"asset_url,omitempty"`
+	Size            int64             `json:"size,omitempty" yaml:"size,omitempty"`
+	SHA256          string            `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	InstallError    string            `json:"install_error,omitempty" yaml:"install_error,omitempty"`
+	Actions         []string          `json:"actions" yaml:"actions"`
+	Releases        []releaseDocument `json:"releases,omitempty" yaml:"releases,omitempty"`
+	Verification    *verifyDocument   `json:"verification,omitempty" yaml:"verification,omitempty"`
+	Info            *installInfo      `json:"info,omitempty" yaml:"info,omitempty"`
+	Products        []productDocument `json:"products,omitempty" yaml:"products,omitempty"`
+	Settings        []settingDocument `json:"settings,omitempty" yaml:"settings,omitempty"`
+	Bundle          *bundleDocument   `json:"bundle,omitempty" yaml:"bundle,omitempty"`
+	Error           *errorDocument    `json:"error,omitempty" yaml:"error,omitempty"`
+	ExitCode        int               `json:"exit_code" yaml:"exit_code"`
+}
+
+// errorDocument describes a failure with a stable code, such as
+// "not_writable", and a human-readable message.
+type errorDocument struct {
+	Code    string `json:"code" yaml:"code"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// Actions reported in result.Actions.
+const (
+	actionDownloaded = "downloaded"
+	actionVerified   = "verified"
+	actionApplied    = "applied"
+	actionCreated    = "created"
+)
+
+// newResult returns an empty result for a successful command.
+func newResult() *result {
+	return &result{Actions: []string{}}
+}
+
+// setStatus records the update status determined by the service.
+func (r *result) setStatus(status *updater.UpdateStatus) {
+	r.CurrentVersion = status.Current
+	r.LatestVersion = status.Latest
+	r.Channel = status.Channel
+	r.UpdateAvailable = &status.UpdateAvailable
+	r.UpdateRequired = status.Required
+	r.PendingVersion = status.Pending
+	r.AssetURL = status.AssetURL
+}
+
+// setCheck records what a check of the service did. report describes the dry
+// run, if any.
+func (r *result) setCheck(check *updater.CheckResult, report *updater.DryRunReport) {
+	r.CurrentVersion = check.Current
+	available := check.Action != updater.UpdateNone && check.Action != updater.UpdateHeldBack
+	r.UpdateAvailable = &available
+	if check.Action == updater.UpdateHeldBack {
+		r.PendingVersion = check.Version
+	} else {
+		r.LatestVersion = check.Version
+	}
+
+	switch check.Action {
+	case updater.UpdateApplied, updater.UpdateDryRun:
+		r.setDelivered(report)
+	case updater.UpdateStaged:
+		r.Actions = append(r.Actions, actionDownloaded, actionVerified)
+		r.ExitCode = exitUpdateAvailable
+	case updater.UpdateDeferred:
+		r.Actions = append(r.Actions, actionDownloaded)
+		r.ExitCode = exitUpdateAvailable
+	case updater.UpdateAvailable:
+		r.ExitCode = exitUpdateAvailable
+	}
+}
+
+// setDelivered records that an update was delivered: applied, or only
+// downloaded and verified in a dry run. report describes the dry run, if known.
+func (r *result) setDelivered(report *updater.DryRunReport) {
+	if dryRun {
+		if report != nil {
+			r.AssetURL = report.URL
+			r.Size = report.Size
+			r.SHA256 = report.SHA256
+			r.InstallError = report.InstallError
+		}
+		r.Actions = append(r.Actions, actionDownloaded, actionVerified)
+		r.ExitCode = exitUpdateAvailable
+		return
+	}
+	r.Actions = append(r.Actions, actionApplied)
+	r.ExitCode = exitUpdateApplied
+}
+
+// classifyError returns the stable error code and exit code for err.
+func classifyError(err error) (string, int) {
+	switch {
+	case errors.Is(err, updater.ErrUpdateRequired):
+		return "update_required", exitUpdateRequired
+	case errors.Is(err, updater.ErrManagedInstall):
+		return "managed_install", exitManagedInstall
+	case errors.Is(err, updater.ErrNotWritable):
+		return "not_writable", exitNotWritable
+	case errors.Is(err, updater.ErrDowngradeNotAllowed):
+		return "downgrade_not_allowed", exitDowngradeNotAllowed
+	case errors.Is(err, updater.ErrUpdateLocked):
+		return "update_locked", exitUpdateLocked
+	case errors.Is(err, updater.ErrInvalidStagedUpdate):
+		return "invalid_staged_update", exitInvalidStagedUpdate
+	case errors.Is(err, updater.ErrInvalidBundle):
+		return "invalid_bundle", exitInvalidBundle
+	default:
+		return "error", exitFailure
+	}
+}
+
+// validateOutput checks the --output flag.
+func validateOutput() error {
+	switch outputFormat {
+	case outputText, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q, expected one of: %s", outputFormat,
+			strings.Join([]string{outputText, outputJSON, outputYAML}, ", "))
+	}
+}
+
+// progressOutput returns the writer for the progress messages of the updater
+// library: stdout in text mode, or stderr while a machine-readable document is
+// written to stdout.
+func progressOutput(cmd *cobra.Command) io.Writer {
+	if outputFormat == outputText {
+		return cmd.OutOrStdout()
+	}
+	return cmd.ErrOrStderr()
+}
+
+// run executes a subcommand: it validates --output, runs fn, prints the result,
+// and exits with the result's exit code. fn sends the library's progress
+// messages to progressOutput, keeping them off stdout in document mode. In
+// text mode, text prints the result, and failures are reported as
+// "Error <action>: <err>".
+func run(cmd *cobra.Command, action string, fn func() (*result, error), text func(io.Writer, *result)) {
+	if err := validateOutput(); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		exit(exitUsage)
+		return
+	}
+
+	res, err := fn()
+
+	if res == nil {
+		res = newResult()
+	}
+	res.Command = cmd.Name()
+	if err != nil {
+		var code string
+		code, res.ExitCode = classifyError(err)
+		res.Error = &errorDocument{Code: code, Message: err.Error()}
+	}
+
+	out := cmd.OutOrStdout()
+	switch outputFormat {
+	case outputJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		enc.Encode(res)
+	case outputYAML:
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		enc.Encode(res)
+		enc.Close()
+	default:
+		if err != nil {
+			fmt.Fprintf(out, "Error %s: %v\n", action, err)
+		} else if text != nil {
+			text(out, res)
+		}
+	}
+
+	if res.ExitCode != exitOK {
+		exit(res.ExitCode)
+	}
+}
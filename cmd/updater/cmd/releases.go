@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var releaseConstraint string
+
+var releasesCmd = &cobra.Command{
+	Use:   "releases [tag]",
+	Short: "List the releases offered by the update source, or show one in detail",
+	Long: `Lists the releases offered by the update source with their channel, publish
+date and whether they have an asset for this platform. --channel shows only the
+releases of one channel, and --constraint only the versions matching a semver
+constraint such as ">=1.2 <2". With a tag, shows that release and all of its
+assets.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, "listing releases", func() (*result, error) {
+			var constraint *updater.VersionConstraint
+			if releaseConstraint != "" {
+				var err error
+				if constraint, err = updater.ParseVersionConstraint(releaseConstraint); err != nil {
+					return nil, err
+				}
+			}
+			service, err := newService(cmd, updater.UpdateServiceConfig{})
+			if err != nil {
+				return nil, err
+			}
+			releases, err := service.Releases()
+			if err != nil {
+				return nil, err
+			}
+
+			res := newResult()
+			if len(args) == 1 {
+				release := findRelease(releases, args[0])
+				if release == nil {
+					return nil, fmt.Errorf("release %s not found", args[0])
+				}
+				document := releaseDetails(service, release)
+				res.Releases = []releaseDocument{document}
+				return res, nil
+			}
+
+			res.Releases = []releaseDocument{}
+			for i := range releases {
+				document := describeRelease(service, &releases[i])
+				if channel != "" && document.Channel != channel {
+					continue
+				}
+				if constraint != nil && !constraint.Check(document.Tag) {
+					continue
+				}
+				res.Releases = append(res.Releases, document)
+			}
+			return res, nil
+		}, func(out io.Writer, res *result) {
+			if len(args) == 1 {
+				writeReleaseDetails(out, res.Releases[0])
+			} else {
+				writeReleases(out, res.Releases)
+			}
+		})
+	},
+}
+
+// releaseDocument describes a release in the output of the releases command.
+type releaseDocument struct {
+	Tag           string          `json:"tag" yaml:"tag"`
+	Name          string          `json:"name,omitempty" yaml:"name,omitempty"`
+	Channel       string          `json:"channel" yaml:"channel"`
+	PreRelease    bool            `json:"prerelease" yaml:"prerelease"`
+	PublishedAt   string          `json:"published_at,omitempty" yaml:"published_at,omitempty"`
+	Author        string          `json:"author,omitempty" yaml:"author,omitempty"`
+	URL           string          `json:"url,omitempty" yaml:"url,omitempty"`
+	PlatformAsset bool            `json:"platform_asset" yaml:"platform_asset"`
+	Assets        []assetDocument `json:"assets,omitempty" yaml:"assets,omitempty"`
+	Notes         string          `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
+
+// assetDocument describes a release asset in the detail view of the releases
+// command.
+type assetDocument struct {
+	Name        string `json:"name" yaml:"name"`
+	URL         string `json:"url" yaml:"url"`
+	Size        int64  `json:"size,omitempty" yaml:"size,omitempty"`
+	ContentType string `json:"content_type,omitempty" yaml:"content_type,omitempty"`
+	Digest      string `json:"digest,omitempty" yaml:"digest,omitempty"`
+	Platform    bool   `json:"platform" yaml:"platform"`
+}
+
+// describeRelease summarizes release for the list of the releases command.
+func describeRelease(service *updater.UpdateService, release *updater.Release) releaseDocument {
+	document := releaseDocument{
+		Tag:        release.TagName,
+		Name:       release.Name,
+		Channel:    updater.DefaultChannelRules.ReleaseChannel(release),
+		PreRelease: release.PreRelease,
+		Author:     release.Author.Login,
+		URL:        release.HTMLURL,
+	}
+	if !release.PublishedAt.IsZero() {
+		document.PublishedAt = release.PublishedAt.UTC().Format(time.RFC3339)
+	}
+	_, err := service.DownloadURL(release)
+	document.PlatformAsset = err == nil
+	return document
+}
+
+// releaseDetails describes release with its notes and all of its assets.
+func releaseDetails(service *updater.UpdateService, release *updater.Release) releaseDocument {
+	document := describeRelease(service, release)
+	platformURL, _ := service.DownloadURL(release)
+	document.Assets = make([]assetDocument, 0, len(release.Assets))
+	for _, asset := range release.Assets {
+		document.Assets = append(document.Assets, assetDocument{
+			Name:        asset.Name,
+			URL:         asset.DownloadURL,
+			Size:        asset.Size,
+			ContentType: asset.ContentType,
+			Digest:      asset.Digest,
+			Platform:    asset.DownloadURL == platformURL,
+		})
+	}
+	document.Notes = release.Notes()
+	return document
+}
+
+// findRelease returns the release tagged tag, with or without a "v" prefix, or
+// nil if there is none.
+func findRelease(releases []updater.Release, tag string) *updater.Release {
+	for i := range releases {
+		if releases[i].TagName == tag || sameVersion(releases[i].TagName, tag) {
+			return &releases[i]
+		}
+	}
+	return nil
+}
+
+// writeReleases prints one row per release with its channel.
+func writeReleases(out io.Writer, releases []releaseDocument) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tCHANNEL\tPRERELEASE\tPUBLISHED\tPLATFORM ASSET")
+	for _, release := range releases {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n", release.Tag, orDash(release.Channel), release.PreRelease,
+			orDash(publishedDate(release.PublishedAt)), yesNo(release.PlatformAsset))
+	}
+	w.Flush()
+}
+
+// writeReleaseDetails prints a release, its assets and its notes, marking the
+// asset for this platform.
+func writeReleaseDetails(out io.Writer, release releaseDocument) {
+	fmt.Fprintf(out, "Tag:        %s\n", release.Tag)
+	fmt.Fprintf(out, "Name:       %s\n", orDash(release.Name))
+	fmt.Fprintf(out, "Channel:    %s\n", orDash(release.Channel))
+	fmt.Fprintf(out, "Prerelease: %t\n", release.PreRelease)
+	fmt.Fprintf(out, "Published:  %s\n", orDash(release.PublishedAt))
+	fmt.Fprintf(out, "Author:     %s\n", orDash(release.Author))
+	fmt.Fprintf(out, "URL:        %s\n", orDash(release.URL))
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ASSET\tPLATFORM\tSIZE\tURL")
+	for _, asset := range release.Assets {
+		platform := ""
+		if asset.Platform {
+			platform = "*"
+		}
+		size := "-"
+		if asset.Size > 0 {
+			size = fmt.Sprint(asset.Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", asset.Name, platform, size, asset.URL)
+	}
+	w.Flush()
+
+	if release.Notes != "" {
+		fmt.Fprintf(out, "\n%s\n", release.Notes)
+	}
+}
+
+// publishedDate returns the date of an RFC 3339 timestamp.
+func publishedDate(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.Format("2006-01-02")
+}
+
+// yesNo returns "yes" or "no".
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// addReleaseFilterFlags adds the flags filtering the list of releases.
+func addReleaseFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&releaseConstraint, "constraint", "", `Only list versions matching a semver constraint, e.g. ">=1.2 <2"`)
+}
+
+func init() {
+	addSourceFlags(releasesCmd)
+	addReleaseFilterFlags(releasesCmd)
+	addOutputFlag(releasesCmd)
+	rootCmd.AddCommand(releasesCmd)
+}
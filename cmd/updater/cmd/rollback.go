@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [version]",
+	Short: "Go back to an older release",
+	Long: `Installs the given older release, or without arguments the newest release
+older than the current version in the tracked channel, and reports the
+version it went back to. Releases that are not older than the current version
+and blocked releases are never installed. Exits with status 20 once the
+release is installed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		run(cmd, "rolling back", func() (*result, error) {
+			res := newResult()
+			if len(args) == 1 {
+				res.LatestVersion = args[0]
+			}
+			service, err := newService(cmd, updater.UpdateServiceConfig{})
+			if err != nil {
+				return nil, err
+			}
+			current, err := service.CurrentVersion()
+			if err != nil {
+				return nil, err
+			}
+			res.CurrentVersion = current
+			if res.LatestVersion == "" {
+				previous, err := service.PreviousRelease()
+				if err != nil {
+					return res, err
+				}
+				res.LatestVersion = previous.TagName
+			}
+			if err := service.Rollback(res.LatestVersion); err != nil {
+				return res, err
+			}
+			res.setDelivered(service.LastDryRun())
+			return res, nil
+		}, writeRollback)
+	},
+}
+
+// writeRollback prints the version rollback went back to. Progress of the
+// installation is printed by the updater itself.
+func writeRollback(out io.Writer, res *result) {
+	if res.ExitCode == exitUpdateApplied {
+		fmt.Fprintf(out, "Rolled back to %s (from %s).\n", res.LatestVersion, res.CurrentVersion)
+	}
+}
+
+func init() {
+	addSourceFlags(rollbackCmd)
+	addApplyFlags(rollbackCmd)
+	addOutputFlag(rollbackCmd)
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// defaultRepoURL is the update source used when --repo is not given: the
+// updater's own releases.
+const defaultRepoURL = "https://github.com/snider/updater"
+
+var repoURL string
+
+// addSourceFlags adds the flags selecting the update source and the binary to
+// update.
+func addSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&repoURL, "repo", defaultRepoURL, "GitHub repository URL, s3:// bucket, oci:// repository, generic HTTP update server, or file:// release directory")
+	cmd.Flags().StringVar(&channel, "channel", "", "Set the update channel (stable, beta, alpha). If not set, it's determined from the version tag.")
+	cmd.Flags().BoolVar(&forceSemVerPrefix, "force-semver-prefix", true, "Force 'v' prefix on semver tags")
+	cmd.Flags().StringVar(&releaseURLFormat, "release-url-format", "", "A URL format for release assets, with {os}, {arch}, and {tag} as placeholders")
+	cmd.Flags().StringVar(&target, "target", "", "Update the binary at this path instead of the running executable")
+}
+
+// addApplyFlags adds the flags controlling how updates are applied.
+func addApplyFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Download and verify updates, and report what would be installed, without applying them")
+	cmd.Flags().StringVar(&elevate, "elevate", "", "Apply updates to binaries you cannot write through this command, e.g. sudo or pkexec")
+}
+
+// sourceFlagSettings maps the source flags, and the flags of other commands
+// naming settings, to the settings they override.
+var sourceFlagSettings = map[string]string{
+	"repo":                updater.SettingRepoURL,
+	"channel":             updater.SettingChannel,
+	"force-semver-prefix": updater.SettingForceSemVerPrefix,
+	"release-url-format":  updater.SettingReleaseURLFormat,
+	"target":              updater.SettingTargetPath,
+	"public-key":          updater.SettingPublicKey,
+}
+
+// loadSettings merges the configuration files, UPDATER_* environment variables
+// and the source flags set on cmd. Flags take precedence.
+func loadSettings(cmd *cobra.Command) (*updater.Settings, error) {
+	settings, err := updater.LoadSettings(updater.FindSettingsFiles(updater.SettingsSearchDirs()))
+	if err != nil {
+		return nil, err
+	}
+	var fromFile string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if key, ok := sourceFlagSettings[flag.Name]; ok {
+			settings.Set(key, flag.Value.String(), "flag --"+flag.Name)
+		}
+		if flag.Name == "from-file" {
+			fromFile = flag.Value.String()
+		}
+	})
+	if fromFile != "" {
+		source, err := localSource(fromFile)
+		if err != nil {
+			return nil, err
+		}
+		settings.Set(updater.SettingRepoURL, source, "flag --from-file")
+	}
+	settings.SetDefault(updater.SettingRepoURL, defaultRepoURL)
+	settings.SetDefault(updater.SettingForceSemVerPrefix, "true")
+	return settings, nil
+}
+
+// serviceConfig returns config completed with the settings of cmd and the
+// apply flags.
+func serviceConfig(cmd *cobra.Command, config updater.UpdateServiceConfig) (updater.UpdateServiceConfig, error) {
+	settings, err := loadSettings(cmd)
+	if err != nil {
+		return config, err
+	}
+	if err := settings.Apply(&config); err != nil {
+		return config, err
+	}
+	config.DryRun = dryRun
+	config.Elevate = elevator()
+	config.Output = progressOutput(cmd)
+	return config, nil
+}
+
+// newService creates an update service for the settings of cmd, with further
+// settings from config.
+func newService(cmd *cobra.Command, config updater.UpdateServiceConfig) (*updater.UpdateService, error) {
+	config, err := serviceConfig(cmd, config)
+	if err != nil {
+		return nil, err
+	}
+	service, err := updater.NewUpdateService(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating update service: %w", err)
+	}
+	return service, nil
+}
+
+// localSource returns the file:// update source for --from-file, which names a
+// release directory or the latest.json inside it.
+func localSource(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading update source: %w", err)
+	}
+	if !info.IsDir() {
+		if filepath.Base(path) != "latest.json" {
+			return "", fmt.Errorf("%s is not a release directory or latest.json", path)
+		}
+		path = filepath.Dir(path)
+	}
+	return updater.FileURL(path)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fleetConfigPath  string
+	fleetParallelism int
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Update every product listed in the fleet configuration",
+	Long: `Checks every product in the fleet configuration for updates, applies them, and
+prints a summary table. Products are updated concurrently, a few at a time.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var results []updater.SyncResult
+		run(cmd, "syncing fleet", func() (*result, error) {
+			path := fleetConfigPath
+			if path == "" {
+				var err error
+				if path, err = updater.DefaultFleetConfigPath(); err != nil {
+					return nil, fmt.Errorf("error locating fleet config: %w", err)
+				}
+			}
+
+			config, err := updater.LoadFleetConfig(path)
+			if err != nil {
+				return nil, fmt.Errorf("error loading fleet config: %w", err)
+			}
+			if fleetParallelism > 0 {
+				config.Parallelism = fleetParallelism
+			}
+			config.Output = progressOutput(cmd)
+
+			results = updater.SyncFleet(context.Background(), config)
+			return syncResult(results), nil
+		}, func(out io.Writer, res *result) { writeSyncSummary(out, results) })
+	},
+}
+
+// productDocument describes the sync of one product in machine-readable
+// output.
+type productDocument struct {
+	Name     string `json:"name" yaml:"name"`
+	Previous string `json:"previous_version" yaml:"previous_version"`
+	Current  string `json:"current_version" yaml:"current_version"`
+	Updated  bool   `json:"updated" yaml:"updated"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// syncResult summarizes results. Its exit code reports a failure if any
+// product failed, and otherwise whether any product was updated.
+func syncResult(results []updater.SyncResult) *result {
+	res := newResult()
+	res.Products = make([]productDocument, 0, len(results))
+	for _, r := range results {
+		product := productDocument{Name: r.Product, Previous: r.Previous, Current: r.Current}
+		switch {
+		case r.Err != nil:
+			product.Error = r.Err.Error()
+			res.ExitCode = exitFailure
+		case r.Updated():
+			product.Updated = true
+			if res.ExitCode == exitOK {
+				res.ExitCode = exitUpdateApplied
+			}
+		}
+		res.Products = append(res.Products, product)
+	}
+	if res.ExitCode == exitFailure {
+		res.Error = &errorDocument{Code: "error", Message: "not every product could be synced"}
+	}
+	return res
+}
+
+// writeSyncSummary prints one row per product with the versions before and
+// after the sync.
+func writeSyncSummary(out io.Writer, results []updater.SyncResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRODUCT\tPREVIOUS\tCURRENT\tSTATUS")
+	for _, result := range results {
+		status := "up-to-date"
+		switch {
+		case result.Err != nil:
+			status = "failed: " + result.Err.Error()
+		case result.Updated():
+			status = "updated"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Product, orDash(result.Previous), orDash(result.Current), status)
+	}
+	w.Flush()
+}
+
+// orDash returns value, or "-" if it is empty.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func init() {
+	syncCmd.Flags().StringVar(&fleetConfigPath, "config", "", "Path to the fleet configuration (default: fleet.json in the user config directory)")
+	syncCmd.Flags().IntVar(&fleetParallelism, "parallel", 0, "Maximum number of products to update at once")
+	addOutputFlag(syncCmd)
+	rootCmd.AddCommand(syncCmd)
+}
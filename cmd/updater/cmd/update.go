@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"io"
+	"strings"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+)
+
+var fromFile string
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update to the latest release, an exact version, or a pull request build",
+	Long: `Checks for a newer release in the tracked channel and applies it. With
+--version, installs that exact release instead, even from another channel. With
+--pull-request, installs the release built for a GitHub pull request. With
+--from-file, installs from a local release directory holding latest.json and
+the release assets, without network access.
+
+Exits with status 20 if an update was applied, or 10 if one was found but not
+applied, e.g. because it was staged, deferred to a maintenance window or only
+downloaded by --dry-run.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runUpdate(cmd)
+	},
+}
+
+// runUpdate applies the update selected by the settings and flags of cmd. It is
+// shared by update and the deprecated --do-update, --to-version and
+// --pull-request flags of the root command.
+func runUpdate(cmd *cobra.Command) {
+	run(cmd, "performing update", func() (*result, error) {
+		if pullRequest > 0 {
+			return updatePullRequest(cmd)
+		}
+
+		service, err := newService(cmd, updater.UpdateServiceConfig{
+			CheckOnStartup: updater.CheckAndUpdateOnStartup,
+			AllowDowngrade: allowDowngrade,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if toVersion != "" {
+			return updateToVersion(service)
+		}
+
+		check, err := service.Check()
+		res := newResult()
+		res.setCheck(check, service.LastDryRun())
+		return res, err
+	}, writeUpdate)
+}
+
+// updateToVersion installs the exact release given by --version.
+func updateToVersion(service *updater.UpdateService) (*result, error) {
+	current, err := service.CurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+	res := newResult()
+	res.CurrentVersion = current
+	res.LatestVersion = toVersion
+	if err := service.UpdateTo(toVersion); err != nil {
+		return res, err
+	}
+	if !sameVersion(current, toVersion) {
+		res.setDelivered(service.LastDryRun())
+	}
+	return res, nil
+}
+
+// updatePullRequest installs the release built for the pull request given by
+// --pull-request, honouring --target, --elevate and --dry-run.
+func updatePullRequest(cmd *cobra.Command) (*result, error) {
+	service, err := newService(cmd, updater.UpdateServiceConfig{})
+	if err != nil {
+		return nil, err
+	}
+	current, err := service.CurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+	res := newResult()
+	res.CurrentVersion = current
+	release, err := service.UpdateToPullRequest(pullRequest)
+	if release == nil {
+		return res, err
+	}
+	res.LatestVersion = release.TagName
+	if err != nil {
+		return res, err
+	}
+	res.setDelivered(service.LastDryRun())
+	return res, nil
+}
+
+// writeUpdate prints the outcome of update. Progress of an applied update is
+// printed by the updater itself, so only the up-to-date case is reported here.
+func writeUpdate(out io.Writer, res *result) {
+	if len(res.Actions) == 0 && res.UpdateAvailable != nil {
+		writeStatus(out, res)
+	}
+}
+
+// sameVersion reports whether a and b denote the same semantic version,
+// regardless of a "v" prefix.
+func sameVersion(a, b string) bool {
+	return semver.Compare("v"+strings.TrimPrefix(a, "v"), "v"+strings.TrimPrefix(b, "v")) == 0
+}
+
+// addUpdateFlags adds the flags selecting what the update command installs.
+func addUpdateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&toVersion, "version", "", "Install an exact release version, e.g. v1.2.3")
+	cmd.Flags().BoolVar(&allowDowngrade, "allow-downgrade", false, "Allow --version to install a version older than the current one")
+	cmd.Flags().IntVar(&pullRequest, "pull-request", 0, "Install the release of a GitHub pull request")
+	cmd.Flags().StringVar(&fromFile, "from-file", "", "Install from a local release directory, or the latest.json in it, instead of --repo")
+	cmd.MarkFlagsMutuallyExclusive("from-file", "repo")
+	cmd.MarkFlagsMutuallyExclusive("from-file", "pull-request")
+}
+
+func init() {
+	addSourceFlags(updateCmd)
+	addApplyFlags(updateCmd)
+	addUpdateFlags(updateCmd)
+	addOutputFlag(updateCmd)
+	rootCmd.AddCommand(updateCmd)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/snider/updater"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that the installed binary matches its release asset",
+	Long: `Downloads the release asset of the installed version and compares its SHA-256
+checksum with the installed binary. Exits with status 40 if they differ.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var verification *updater.Verification
+		run(cmd, "verifying installation", func() (*result, error) {
+			service, err := newService(cmd, updater.UpdateServiceConfig{})
+			if err != nil {
+				return nil, err
+			}
+			if verification, err = service.Verify(); err != nil {
+				return nil, err
+			}
+			res := newResult()
+			res.CurrentVersion = verification.Version
+			res.Verification = &verifyDocument{
+				Path:     verification.Path,
+				Asset:    verification.Asset,
+				URL:      verification.URL,
+				Expected: verification.Expected,
+				Actual:   verification.Actual,
+				OK:       verification.OK(),
+			}
+			if !verification.OK() {
+				res.Error = &errorDocument{Code: "verify_mismatch", Message: "the installed binary differs from the release asset"}
+				res.ExitCode = exitVerifyMismatch
+			}
+			return res, nil
+		}, func(out io.Writer, res *result) { writeVerification(out, verification) })
+	},
+}
+
+// verifyDocument describes the outcome of verify in machine-readable output.
+type verifyDocument struct {
+	Path     string `json:"path" yaml:"path"`
+	Asset    string `json:"asset" yaml:"asset"`
+	URL      string `json:"url" yaml:"url"`
+	Expected string `json:"expected_sha256" yaml:"expected_sha256"`
+	Actual   string `json:"actual_sha256" yaml:"actual_sha256"`
+	OK       bool   `json:"ok" yaml:"ok"`
+}
+
+// writeVerification prints the checksums compared by verify and the outcome.
+func writeVerification(out io.Writer, v *updater.Verification) {
+	fmt.Fprintf(out, "Binary:   %s\n", v.Path)
+	fmt.Fprintf(out, "Version:  %s\n", v.Version)
+	fmt.Fprintf(out, "Asset:    %s\n", v.Asset)
+	fmt.Fprintf(out, "Expected: %s\n", v.Expected)
+	fmt.Fprintf(out, "Actual:   %s\n", v.Actual)
+	if v.OK() {
+		fmt.Fprintln(out, "OK: the installed binary matches the release asset.")
+	} else {
+		fmt.Fprintln(out, "MISMATCH: the installed binary differs from the release asset.")
+	}
+}
+
+func init() {
+	addSourceFlags(verifyCmd)
+	addOutputFlag(verifyCmd)
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,268 @@
+package updater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// VersionConstraint restricts which versions an update may move to.
+//
+// A constraint is one or more comparator sets separated by "||"; a version
+// satisfies the constraint if it satisfies every comparator of any set.
+// Comparators within a set are separated by spaces or commas. The supported
+// forms are:
+//
+//	=1.4.2, 1.4.2      exactly 1.4.2
+//	!=1.4.2            anything but 1.4.2
+//	>1.4, >=1.4        greater than (or equal to) 1.4.0
+//	<2.0, <=1.4        less than 2.0.0 (or anything up to 1.4.x)
+//	~1.6, ~1.6.3       patch updates only: >=1.6.0 <1.7.0
+//	^1.2, ^0.3         no breaking changes: >=1.2.0 <2.0.0, >=0.3.0 <0.4.0
+//	1.x, 1.2.*, 1      any version with the given prefix
+//	*                  any version
+//
+// A pre-release of an exclusive upper bound does not satisfy it, so "<2.0"
+// rejects 2.0.0-beta.1 even though it sorts before 2.0.0.
+type VersionConstraint struct {
+	raw  string
+	sets [][]comparator
+}
+
+// comparator is a single operator and canonical semver version, e.g. ">=" "v1.4.0".
+type comparator struct {
+	op      string
+	version string
+}
+
+// ParseVersionConstraint parses a constraint expression such as ">=1.4 <2.0",
+// "~1.6" or "^1.2". See VersionConstraint for the supported syntax.
+//
+// Example:
+//
+//	c, err := updater.ParseVersionConstraint(">=1.4 <2.0")
+//	if err != nil {
+//		// handle error
+//	}
+//	fmt.Println(c.Check("v1.6.0")) // true
+//	fmt.Println(c.Check("v2.0.0")) // false
+func ParseVersionConstraint(expr string) (*VersionConstraint, error) {
+	c := &VersionConstraint{raw: strings.TrimSpace(expr)}
+	for _, group := range strings.Split(expr, "||") {
+		set, err := parseComparatorSet(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %w", expr, err)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+// exactVersionConstraint returns a constraint matching only the given version.
+func exactVersionConstraint(version string) (*VersionConstraint, error) {
+	parts, pre, err := parsePartialVersion(version)
+	if err != nil || len(parts) != 3 {
+		return nil, fmt.Errorf("invalid pinned version %q: must be a full semantic version", version)
+	}
+	v := fullVersion(parts, pre)
+	return &VersionConstraint{raw: "=" + v, sets: [][]comparator{{{op: "=", version: v}}}}, nil
+}
+
+// String returns the constraint expression as it was written.
+func (c *VersionConstraint) String() string {
+	return c.raw
+}
+
+// Check reports whether version satisfies the constraint. Versions that are not
+// valid semantic versions never satisfy it.
+func (c *VersionConstraint) Check(version string) bool {
+	v := formatVersionForComparison(version)
+	if !semver.IsValid(v) {
+		return false
+	}
+	for _, set := range c.sets {
+		if setAllows(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func setAllows(set []comparator, v string) bool {
+	for _, cmp := range set {
+		if !cmp.allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c comparator) allows(v string) bool {
+	r := semver.Compare(v, c.version)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		if semver.Prerelease(v) != "" && semver.Prerelease(c.version) == "" &&
+			versionCore(v) == versionCore(c.version) {
+			return false
+		}
+		return r < 0
+	case "<=":
+		return r <= 0
+	}
+	return false
+}
+
+// versionCore strips the pre-release and build suffixes from a canonical version.
+func versionCore(v string) string {
+	v = strings.TrimSuffix(v, semver.Build(v))
+	return strings.TrimSuffix(v, semver.Prerelease(v))
+}
+
+func parseComparatorSet(group string) ([]comparator, error) {
+	tokens := strings.FieldsFunc(group, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t'
+	})
+
+	var set []comparator
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		// Allow a space between an operator and its version, e.g. ">= 1.4".
+		if strings.Trim(tok, "=!<>~^") == "" && i+1 < len(tokens) {
+			i++
+			tok += tokens[i]
+		}
+		cmps, err := parseComparator(tok)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cmps...)
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("empty comparator set")
+	}
+	return set, nil
+}
+
+// parseComparator expands a single token into one or two primitive comparators.
+func parseComparator(tok string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(tok, prefix) {
+			op = prefix
+			break
+		}
+	}
+
+	parts, pre, err := parsePartialVersion(strings.TrimPrefix(tok, op))
+	if err != nil {
+		return nil, err
+	}
+	n := len(parts)
+	lower := fullVersion(parts, pre)
+
+	if n == 0 {
+		if op != "" {
+			return nil, fmt.Errorf("%q requires a version", op)
+		}
+		return []comparator{{op: ">=", version: "v0.0.0-0"}}, nil
+	}
+
+	switch op {
+	case "~":
+		if n == 1 {
+			return rangeOf(lower, bump(parts, 0)), nil
+		}
+		return rangeOf(lower, bump(parts, 1)), nil
+	case "^":
+		// The upper bound bumps the left-most non-zero component that was specified.
+		idx := 0
+		for idx < n-1 && parts[idx] == 0 {
+			idx++
+		}
+		return rangeOf(lower, bump(parts, idx)), nil
+	case "", "=":
+		if n == 3 {
+			return []comparator{{op: "=", version: lower}}, nil
+		}
+		return rangeOf(lower, bump(parts, n-1)), nil
+	case ">":
+		if n < 3 {
+			return []comparator{{op: ">=", version: bump(parts, n-1)}}, nil
+		}
+	case "<=":
+		if n < 3 {
+			return []comparator{{op: "<", version: bump(parts, n-1)}}, nil
+		}
+	case "!=":
+		if n < 3 {
+			return nil, fmt.Errorf("%q requires a full version", tok)
+		}
+	}
+	return []comparator{{op: op, version: lower}}, nil
+}
+
+func rangeOf(lower, upper string) []comparator {
+	return []comparator{{op: ">=", version: lower}, {op: "<", version: upper}}
+}
+
+// parsePartialVersion parses versions such as "1", "v1.4", "1.4.x" or
+// "1.4.2-rc.1", returning the numeric components that were given and any
+// pre-release suffix. Wildcard components end the version.
+func parsePartialVersion(s string) ([]int, string, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" || s == "*" || s == "x" || s == "X" {
+		return nil, "", nil
+	}
+
+	core, pre := s, ""
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		core, pre = s[:i], s[i:]
+	}
+
+	var parts []int
+	for _, field := range strings.Split(core, ".") {
+		if field == "x" || field == "X" || field == "*" {
+			break
+		}
+		if len(parts) == 3 {
+			return nil, "", fmt.Errorf("invalid version %q", s)
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, "", fmt.Errorf("invalid version %q", s)
+		}
+		parts = append(parts, n)
+	}
+	if pre != "" && len(parts) != 3 {
+		return nil, "", fmt.Errorf("pre-release requires a full version: %q", s)
+	}
+	if v := fullVersion(parts, pre); !semver.IsValid(v) {
+		return nil, "", fmt.Errorf("invalid version %q", s)
+	}
+	return parts, pre, nil
+}
+
+// fullVersion zero-fills parts to a canonical "vX.Y.Z" with an optional suffix.
+func fullVersion(parts []int, pre string) string {
+	full := [3]int{}
+	copy(full[:], parts)
+	return fmt.Sprintf("v%d.%d.%d%s", full[0], full[1], full[2], pre)
+}
+
+// bump returns the smallest version that increments component idx of parts.
+func bump(parts []int, idx int) string {
+	next := make([]int, idx+1)
+	copy(next, parts[:idx+1])
+	next[idx]++
+	return fullVersion(next, "")
+}
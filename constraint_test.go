@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"testing"
+)
+
+func TestVersionConstraint_Check(t *testing.T) {
+	testCases := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{">=1.4 <2.0", "v1.4.0", true},
+		{">=1.4 <2.0", "1.9.9", true},
+		{">=1.4 <2.0", "v1.3.9", false},
+		{">=1.4 <2.0", "v2.0.0", false},
+		{">=1.4 <2.0", "v2.0.0-beta.1", false},
+		{">=1.4 <2.0", "v1.5.0-beta.1", true},
+		{">=1.4, <2.0", "v1.6.0", true},
+		{">= 1.4 < 2.0", "v1.6.0", true},
+		{"~1.6", "v1.6.9", true},
+		{"~1.6", "v1.7.0", false},
+		{"~1.6.3", "v1.6.2", false},
+		{"~1", "v1.9.0", true},
+		{"^1.2", "v1.9.0", true},
+		{"^1.2", "v2.0.0", false},
+		{"^1.2", "v1.1.0", false},
+		{"^0.3", "v0.3.5", true},
+		{"^0.3", "v0.4.0", false},
+		{"^0.0.3", "v0.0.4", false},
+		{"1.x", "v1.99.0", true},
+		{"1.x", "v2.0.0", false},
+		{"1.2.*", "v1.2.7", true},
+		{"1", "v1.0.1", true},
+		{"=1.4.2", "v1.4.2", true},
+		{"1.4.2", "v1.4.3", false},
+		{"!=1.4.2", "v1.4.3", true},
+		{"!=1.4.2", "v1.4.2", false},
+		{">1.4", "v1.4.9", false},
+		{">1.4", "v1.5.0", true},
+		{"<=1.4", "v1.4.9", true},
+		{"<=1.4", "v1.5.0", false},
+		{"*", "v0.0.1", true},
+		{"<1.0 || >=2.0", "v0.9.0", true},
+		{"<1.0 || >=2.0", "v1.5.0", false},
+		{"<1.0 || >=2.0", "v2.1.0", true},
+		{">=1.0", "not-a-version", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.constraint+"/"+tc.version, func(t *testing.T) {
+			c, err := ParseVersionConstraint(tc.constraint)
+			if err != nil {
+				t.Fatalf("ParseVersionConstraint(%q) failed: %v", tc.constraint, err)
+			}
+			if got := c.Check(tc.version); got != tc.expected {
+				t.Errorf("Check(%q) against %q: expected %v, got %v", tc.version, tc.constraint, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseVersionConstraint_Invalid(t *testing.T) {
+	for _, expr := range []string{"", ">=", ">=1.a", "1.2.3.4", "~", "!=1.2", "1.2-beta", ">=1.0 ||"} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseVersionConstraint(expr); err == nil {
+				t.Errorf("expected an error for %q", expr)
+			}
+		})
+	}
+}
+
+func TestExactVersionConstraint(t *testing.T) {
+	c, err := exactVersionConstraint("1.6.2")
+	if err != nil {
+		t.Fatalf("exactVersionConstraint failed: %v", err)
+	}
+	if !c.Check("v1.6.2") || c.Check("v1.6.3") {
+		t.Errorf("expected pin to match only v1.6.2")
+	}
+
+	if _, err := exactVersionConstraint("1.6"); err == nil {
+		t.Errorf("expected an error for a partial pinned version")
+	}
+}
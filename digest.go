@@ -0,0 +1,263 @@
+package updater
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// errDigestMismatch reports content that does not match its digest.
+var errDigestMismatch = errors.New("content does not match digest")
+
+// digestHash returns a hash for the algorithm of digest, e.g. "sha256:<hex>",
+// and its expected hex-encoded value.
+func digestHash(digest string) (hash.Hash, string, error) {
+	algorithm, encoded, ok := strings.Cut(digest, ":")
+	if !ok {
+		return nil, "", fmt.Errorf("invalid digest %q", digest)
+	}
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), strings.ToLower(encoded), nil
+	case "sha512":
+		return sha512.New(), strings.ToLower(encoded), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+}
+
+// verifyDigest checks data against digest.
+func verifyDigest(digest string, data []byte) error {
+	h, expected, err := digestHash(digest)
+	if err != nil {
+		return err
+	}
+	h.Write(data)
+	if hex.EncodeToString(h.Sum(nil)) != expected {
+		return fmt.Errorf("%w %s", errDigestMismatch, digest)
+	}
+	return nil
+}
+
+// verifyFileDigest checks the file at path against digest.
+func verifyFileDigest(path, digest string) error {
+	h, expected, err := digestHash(digest)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != expected {
+		return fmt.Errorf("%w %s", errDigestMismatch, digest)
+	}
+	return nil
+}
+
+// digestReader hashes content as it is read and fails at its end if the
+// content does not match the digest.
+type digestReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+	digest   string
+}
+
+// newDigestReader returns body wrapped in a digestReader for digest.
+func newDigestReader(body io.ReadCloser, digest string) (io.ReadCloser, error) {
+	h, expected, err := digestHash(digest)
+	if err != nil {
+		return nil, err
+	}
+	return &digestReader{ReadCloser: body, hash: h, expected: expected, digest: digest}, nil
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(r.hash.Sum(nil)) != r.expected {
+		return n, fmt.Errorf("%w %s", errDigestMismatch, r.digest)
+	}
+	return n, err
+}
+
+// assetChecksum is what the download of a release asset is verified against.
+// The zero value verifies nothing.
+type assetChecksum struct {
+	// digest is the Digest of the release asset, e.g. "sha256:<hex>".
+	digest string
+	// checksumsURL is the location of the checksums asset published with the
+	// release, such as the checksums.txt of a goreleaser release.
+	checksumsURL string
+	// name is the path of the asset relative to the checksums asset, e.g.
+	// "linux_amd64/agent" for the assets of an S3 release.
+	name string
+}
+
+// releaseChecksum returns what the asset of release downloaded from url is
+// verified against. Nothing is verified for a url that is not the download
+// URL of one of its assets, such as one built from a ReleaseURLFormat.
+//
+// The checksums asset is the one closest to the asset: in its directory, or
+// else in the nearest directory above it.
+func releaseChecksum(release *Release, url string) assetChecksum {
+	if release == nil {
+		return assetChecksum{}
+	}
+	var asset *ReleaseAsset
+	for i := range release.Assets {
+		if release.Assets[i].DownloadURL == url {
+			asset = &release.Assets[i]
+			break
+		}
+	}
+	if asset == nil {
+		return assetChecksum{}
+	}
+
+	sum := assetChecksum{digest: asset.Digest}
+	dir := path.Dir(asset.Name)
+	closest := ""
+	for _, candidate := range release.Assets {
+		if !isChecksumsAsset(path.Base(candidate.Name)) {
+			continue
+		}
+		checksumsDir := path.Dir(candidate.Name)
+		prefix := ""
+		if checksumsDir != "." {
+			prefix = checksumsDir + "/"
+		}
+		if !strings.HasPrefix(asset.Name, prefix) || (sum.checksumsURL != "" && len(prefix) <= len(closest)) {
+			continue
+		}
+		closest = prefix
+		sum.checksumsURL = candidate.DownloadURL
+		sum.name = strings.TrimPrefix(asset.Name, prefix)
+		if checksumsDir == dir {
+			break
+		}
+	}
+	return sum
+}
+
+// verifyDownload checks the update downloaded from url to the file at path
+// against sum, and returns what it was verified against: "digest" for the
+// digest of the release asset, the name of the checksums asset of the release,
+// or "" if there was nothing to verify it against.
+func verifyDownload(path, url string, sum assetChecksum) (string, error) {
+	switch {
+	case sum.digest != "":
+		if err := verifyFileDigest(path, sum.digest); err != nil {
+			return "", fmt.Errorf("failed to verify download: %w", err)
+		}
+		return "digest", nil
+	case sum.checksumsURL != "":
+		name := sum.name
+		if name == "" {
+			name = assetName(url)
+		}
+		expected, err := fetchChecksum(sum.checksumsURL, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to verify download: %w", err)
+		}
+		actual, err := fileSHA256(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to verify download: %w", err)
+		}
+		if actual != expected {
+			return "", fmt.Errorf("failed to verify download: checksum of %s does not match %s", name, assetName(sum.checksumsURL))
+		}
+		return assetName(sum.checksumsURL), nil
+	}
+	return "", nil
+}
+
+// downloadVerified downloads the update from url to the file at path with
+// download and verifies it against sum. If the update is a release
+// archive, such as the .tar.gz and .zip archives of goreleaser, the archive is
+// verified and the binary named binary is extracted from it to path.
+func downloadVerified(download downloadFunc, url, path string, sum assetChecksum, binary string) error {
+	name := assetName(url)
+	if archiveSuffix(name) == "" {
+		if err := download(url, path); err != nil {
+			return err
+		}
+		_, err := verifyDownload(path, url, sum)
+		return err
+	}
+
+	archive := path + ".archive"
+	defer os.Remove(archive)
+	if err := download(url, archive); err != nil {
+		return err
+	}
+	if _, err := verifyDownload(archive, url, sum); err != nil {
+		return err
+	}
+	return extractArchiveBinary(archive, name, binary, path)
+}
+
+// isChecksumsAsset reports whether name is a SHA-256 checksums file in the
+// format of sha256sum, such as checksums.txt or SHA256SUMS.
+func isChecksumsAsset(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, "checksums.txt") || name == "sha256sums" || strings.HasSuffix(name, "sha256sums.txt")
+}
+
+// fetchChecksum returns the hex-encoded SHA-256 checksum listed for the file
+// name, a path relative to the checksums file at checksumsURL. A line for a
+// file in another directory is accepted only if it is the one line for a
+// file of that base name.
+func fetchChecksum(checksumsURL, name string) (string, error) {
+	resp, err := clientFor(checksumsURL).Get(checksumsURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksums: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch checksums: status code %d", resp.StatusCode)
+	}
+
+	var checksum string
+	matches := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		file := strings.TrimPrefix(strings.TrimPrefix(fields[1], "*"), "./")
+		if file == name {
+			return strings.ToLower(fields[0]), nil
+		}
+		if path.Base(file) == path.Base(name) {
+			checksum = strings.ToLower(fields[0])
+			matches++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read checksums: %w", err)
+	}
+	switch matches {
+	case 0:
+		return "", fmt.Errorf("no checksum for %s in %s", name, assetName(checksumsURL))
+	case 1:
+		return checksum, nil
+	default:
+		return "", fmt.Errorf("ambiguous checksum for %s in %s", name, assetName(checksumsURL))
+	}
+}
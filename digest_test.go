@@ -0,0 +1,280 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVerifyDigest(t *testing.T) {
+	testCases := []struct {
+		name        string
+		digest      string
+		expectErr   bool
+		expectMatch bool
+	}{
+		{name: "sha256", digest: "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", expectMatch: true},
+		{name: "uppercase hex", digest: "sha256:2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824", expectMatch: true},
+		{name: "mismatch", digest: "sha256:0000000000000000000000000000000000000000000000000000000000000000", expectErr: true},
+		{name: "unsupported algorithm", digest: "md5:5d41402abc4b2a76b9719d911017c592", expectErr: true},
+		{name: "no algorithm", digest: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyDigest(tc.digest, []byte("hello"))
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error: %v, got %v", tc.expectErr, err)
+			}
+			if tc.expectMatch && err != nil {
+				t.Errorf("expected a match, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadVerified(t *testing.T) {
+	content := "agent 1.2.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+	testCases := []struct {
+		name      string
+		digest    string
+		expectErr bool
+	}{
+		{name: "matching digest", digest: ociDigest(content)},
+		{name: "mismatching digest", digest: ociDigest("something else"), expectErr: true},
+		{name: "no digest", digest: ""},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := &Release{TagName: "v1.2.0", Assets: []ReleaseAsset{{
+				Name:        "agent_" + platform,
+				DownloadURL: fmt.Sprintf("%s/%d/agent_%s", server.URL, i, platform),
+				Digest:      tc.digest,
+			}}}
+			url, err := GetDownloadURL(release, "")
+			if err != nil {
+				t.Fatalf("GetDownloadURL failed: %v", err)
+			}
+
+			path := filepath.Join(t.TempDir(), "agent")
+			err = downloadVerified(DownloadUpdate, url, path, releaseChecksum(release, url), "agent")
+			if tc.expectErr {
+				if !errors.Is(err, errDigestMismatch) {
+					t.Errorf("expected a digest mismatch, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadVerified failed: %v", err)
+			}
+			if data, _ := os.ReadFile(path); string(data) != content {
+				t.Errorf("unexpected content %q", data)
+			}
+		})
+	}
+}
+
+func TestReleaseChecksum(t *testing.T) {
+	release := &Release{TagName: "v1.2.0", Assets: []ReleaseAsset{
+		{Name: "agent_linux_amd64", DownloadURL: "https://example.com/agent_linux_amd64", Digest: "sha256:abc"},
+		{Name: "agent_darwin_arm64", DownloadURL: "https://example.com/agent_darwin_arm64"},
+		{Name: "checksums.txt", DownloadURL: "https://example.com/checksums.txt"},
+	}}
+	nested := &Release{TagName: "v1.2.0", Assets: []ReleaseAsset{
+		{Name: "linux_amd64/agent", DownloadURL: "https://example.com/linux_amd64/agent"},
+		{Name: "darwin_arm64/checksums.txt", DownloadURL: "https://example.com/darwin_arm64/checksums.txt"},
+		{Name: "checksums.txt", DownloadURL: "https://example.com/checksums.txt"},
+		{Name: "darwin_arm64/agent", DownloadURL: "https://example.com/darwin_arm64/agent"},
+		{Name: "windows_amd64/checksums.txt", DownloadURL: "https://example.com/windows_amd64/checksums.txt"},
+	}}
+
+	testCases := []struct {
+		name    string
+		release *Release
+		url     string
+		expect  assetChecksum
+	}{
+		{
+			name: "asset with digest", release: release, url: "https://example.com/agent_linux_amd64",
+			expect: assetChecksum{digest: "sha256:abc", checksumsURL: "https://example.com/checksums.txt", name: "agent_linux_amd64"},
+		},
+		{
+			name: "asset without digest", release: release, url: "https://example.com/agent_darwin_arm64",
+			expect: assetChecksum{checksumsURL: "https://example.com/checksums.txt", name: "agent_darwin_arm64"},
+		},
+		{
+			name: "checksums in the asset's directory", release: nested, url: "https://example.com/darwin_arm64/agent",
+			expect: assetChecksum{checksumsURL: "https://example.com/darwin_arm64/checksums.txt", name: "agent"},
+		},
+		{
+			name: "checksums above the asset's directory", release: nested, url: "https://example.com/linux_amd64/agent",
+			expect: assetChecksum{checksumsURL: "https://example.com/checksums.txt", name: "linux_amd64/agent"},
+		},
+		{name: "url outside the release", release: release, url: "https://example.com/v1.2.0/linux/amd64"},
+		{name: "no release", url: "https://example.com/agent_linux_amd64"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if sum := releaseChecksum(tc.release, tc.url); sum != tc.expect {
+				t.Errorf("expected %+v, got %+v", tc.expect, sum)
+			}
+		})
+	}
+}
+
+func TestInstallUpdate(t *testing.T) {
+	content := "agent 1.2.0"
+	archive := makeArchive(t, "agent_linux_amd64.tar.gz", []archiveFile{
+		{name: "README.md", mode: 0644, content: "readme"},
+		{name: "agent", mode: 0755, content: content},
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/agent_linux_amd64.tar.gz":
+			w.Write(archive)
+		case "/checksums.txt":
+			fmt.Fprintf(w, "%s  agent\n", strings.TrimPrefix(ociDigest(content), "sha256:"))
+		case "/bad_checksums.txt":
+			fmt.Fprintf(w, "%s  agent\n", strings.Repeat("0", 64))
+		case "/platform_checksums.txt":
+			fmt.Fprintf(w, "%s  linux_amd64/agent\n", strings.TrimPrefix(ociDigest(content), "sha256:"))
+			fmt.Fprintf(w, "%s  darwin_arm64/agent\n", strings.Repeat("0", 64))
+		default:
+			fmt.Fprint(w, content)
+		}
+	}))
+	defer server.Close()
+
+	originalDoUpdate := DoUpdate
+	originalApplyUpdateFile := ApplyUpdateFile
+	defer func() {
+		DoUpdate = originalDoUpdate
+		ApplyUpdateFile = originalApplyUpdateFile
+	}()
+
+	testCases := []struct {
+		name        string
+		asset       string
+		sum         assetChecksum
+		expectCalls []string
+		expectErr   bool
+	}{
+		{name: "no checksum", expectCalls: []string{"DoUpdate"}},
+		{name: "matching digest", sum: assetChecksum{digest: ociDigest(content)}, expectCalls: []string{"DoUpdate " + content}},
+		{name: "mismatching digest", sum: assetChecksum{digest: ociDigest("something else")}, expectErr: true},
+		{name: "matching checksums", sum: assetChecksum{checksumsURL: server.URL + "/checksums.txt"}, expectCalls: []string{"DoUpdate " + content}},
+		{name: "mismatching checksums", sum: assetChecksum{checksumsURL: server.URL + "/bad_checksums.txt"}, expectErr: true},
+		{name: "checksums by path", sum: assetChecksum{checksumsURL: server.URL + "/platform_checksums.txt", name: "linux_amd64/agent"}, expectCalls: []string{"DoUpdate " + content}},
+		{name: "checksums of another path", sum: assetChecksum{checksumsURL: server.URL + "/platform_checksums.txt", name: "darwin_arm64/agent"}, expectErr: true},
+		{name: "ambiguous base name", sum: assetChecksum{checksumsURL: server.URL + "/platform_checksums.txt"}, expectErr: true},
+		{name: "archive", asset: "agent_linux_amd64.tar.gz", expectCalls: []string{"DoUpdate " + content}},
+		{name: "archive with digest", asset: "agent_linux_amd64.tar.gz", sum: assetChecksum{digest: ociDigest(string(archive))}, expectCalls: []string{"DoUpdate " + content}},
+		{name: "archive with mismatching digest", asset: "agent_linux_amd64.tar.gz", sum: assetChecksum{digest: ociDigest(content)}, expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls []string
+			DoUpdate = func(url string) error {
+				if !isFileURL(url) {
+					calls = append(calls, "DoUpdate")
+					return nil
+				}
+				resp, err := localClient.Get(url)
+				if err != nil {
+					return err
+				}
+				defer resp.Body.Close()
+				data, _ := io.ReadAll(resp.Body)
+				calls = append(calls, "DoUpdate "+string(data))
+				return nil
+			}
+			ApplyUpdateFile = func(path, target string) error {
+				data, _ := os.ReadFile(path)
+				calls = append(calls, "ApplyUpdateFile "+string(data))
+				return nil
+			}
+
+			asset := tc.asset
+			if asset == "" {
+				asset = "agent"
+			}
+			err := installUpdate(server.URL+"/"+asset, tc.sum)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+			if strings.Join(calls, ",") != strings.Join(tc.expectCalls, ",") {
+				t.Errorf("expected calls %v, got %v", tc.expectCalls, calls)
+			}
+		})
+	}
+}
+
+func TestUpdateService_VerifiedUpdateThroughDoUpdate(t *testing.T) {
+	content := "agent 1.3.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest.json" {
+			fmt.Fprintf(w, `{"version": "1.3.0", "url": "http://%s/agent", "sha256": "%s"}`,
+				r.Host, strings.TrimPrefix(ociDigest(content), "sha256:"))
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	originalDoUpdate := DoUpdate
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalVersion := Version
+	defer func() {
+		DoUpdate = originalDoUpdate
+		ApplyUpdateFile = originalApplyUpdateFile
+		Version = originalVersion
+	}()
+
+	var applied string
+	DoUpdate = func(url string) error {
+		resp, err := clientFor(url).Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		applied = string(data)
+		return err
+	}
+	ApplyUpdateFile = func(path, target string) error {
+		t.Errorf("expected the update to be applied through DoUpdate")
+		return nil
+	}
+	Version = "1.2.0"
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        server.URL,
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		LockPath:       filepath.Join(t.TempDir(), "update.lock"),
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if applied != content {
+		t.Errorf("expected the verified update to be applied, got %q", applied)
+	}
+}
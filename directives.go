@@ -0,0 +1,53 @@
+package updater
+
+import (
+	"regexp"
+	"strings"
+)
+
+// directivePrefix marks an updater directive in a release body. Directives are
+// written as "updater:name" or "updater:name=value" at the start of a line of
+// their own, or at the start of an HTML comment so that they do not show up in
+// the rendered release notes:
+//
+//	<!-- updater:blocked -->
+const directivePrefix = "updater:"
+
+// directiveCommentPattern matches an HTML comment starting with an updater
+// directive, capturing its content.
+var directiveCommentPattern = regexp.MustCompile(`(?is)<!--\s*(` + directivePrefix + `.*?)-->`)
+
+// parseReleaseDirectives extracts the updater directives from a release body,
+// keyed by lower-cased name. Directives without a value map to an empty string.
+// Mentions of a directive in the middle of the prose are not directives.
+func parseReleaseDirectives(body string) map[string]string {
+	var blocks []string
+	for _, m := range directiveCommentPattern.FindAllStringSubmatch(body, -1) {
+		blocks = append(blocks, m[1])
+	}
+	for _, line := range strings.Split(directiveCommentPattern.ReplaceAllString(body, "\n"), "\n") {
+		if isDirectiveLine(line) {
+			blocks = append(blocks, line)
+		}
+	}
+
+	directives := make(map[string]string)
+	for _, block := range blocks {
+		for _, field := range strings.Fields(block) {
+			if !strings.HasPrefix(strings.ToLower(field), directivePrefix) {
+				continue
+			}
+			name, value, _ := strings.Cut(field[len(directivePrefix):], "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = value
+		}
+	}
+	return directives
+}
+
+// isDirectiveLine reports whether line of a release body holds directives.
+func isDirectiveLine(line string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), directivePrefix)
+}
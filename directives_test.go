@@ -0,0 +1,47 @@
+package updater
+
+import (
+	"testing"
+)
+
+func TestParseReleaseDirectives(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected map[string]string
+	}{
+		{
+			name: "comments and own lines",
+			body: "## Changes\n\n* Fixes\n\n<!-- updater:blocked updater:Min-Version=1.2.0 -->\n  updater:rollout=25\nnot-updater:ignored updater:",
+			expected: map[string]string{
+				"blocked":     "",
+				"min-version": "1.2.0",
+				"rollout":     "25",
+			},
+		},
+		{
+			name:     "multi-line comment",
+			body:     "Notes\r\n<!--\r\nupdater:rollout=10\r\n-->",
+			expected: map[string]string{"rollout": "10"},
+		},
+		{
+			name:     "prose",
+			body:     "Add updater:blocked to a release body to block it.\n* Documented updater:rollout=25\n<!-- TODO: updater:min-version=2.0.0 -->\n`updater:blocked`",
+			expected: map[string]string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			directives := parseReleaseDirectives(tc.body)
+			if len(directives) != len(tc.expected) {
+				t.Fatalf("Expected %d directives, got %v", len(tc.expected), directives)
+			}
+			for name, value := range tc.expected {
+				got, ok := directives[name]
+				if !ok || got != value {
+					t.Errorf("Expected directive %s=%q, got %q (present: %v)", name, value, got, ok)
+				}
+			}
+		})
+	}
+}
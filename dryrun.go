@@ -0,0 +1,148 @@
+package updater
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// DryRunReport describes the update a dry run would have installed.
+type DryRunReport struct {
+	// Version is the version that would have been installed, if known.
+	Version string
+	// Current is the version that would have been replaced, if known.
+	Current string
+	// Asset is the file name of the selected release asset.
+	Asset string
+	// URL is the location the asset was downloaded from.
+	URL string
+	// Size is the size of the binary in bytes. For a release archive, it is the
+	// binary extracted from the archive.
+	Size int64
+	// SHA256 is the hex-encoded SHA-256 checksum of the binary. For a release
+	// archive, it is the binary extracted from the archive.
+	SHA256 string
+	// VerifiedBy is what the download was verified against: "digest" for the
+	// Digest of the release asset, or the name of the checksums asset of the
+	// release. It is empty if the release publishes no checksum.
+	VerifiedBy string
+	// InstallError describes why the update could not have been installed,
+	// such as a target binary managed by a package manager. It is empty if
+	// nothing would have prevented the installation.
+	InstallError string
+}
+
+// dryRunDownload downloads the update at url with download into a temporary
+// location that is removed again, verifies it against sum, extracts the binary named binary if
+// it is a release archive, and returns the size and checksum of the binary.
+func dryRunDownload(download downloadFunc, url string, sum assetChecksum, binary string) (*DryRunReport, error) {
+	dir, err := os.MkdirTemp("", "updater-dry-run-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "update")
+	if err := download(url, file); err != nil {
+		return nil, err
+	}
+	verifiedBy, err := verifyDownload(file, url, sum)
+	if err != nil {
+		return nil, err
+	}
+	if name := assetName(url); archiveSuffix(name) != "" {
+		extracted := filepath.Join(dir, "binary")
+		if err := extractArchiveBinary(file, name, binary, extracted); err != nil {
+			return nil, err
+		}
+		file = extracted
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify download: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("failed to verify download: %s is empty", url)
+	}
+	checksum, err := fileSHA256(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify download: %w", err)
+	}
+	return &DryRunReport{Asset: assetName(url), URL: url, Size: info.Size(), SHA256: checksum, VerifiedBy: verifiedBy}, nil
+}
+
+// assetName returns the file name in the path of url.
+func assetName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return path.Base(rawURL)
+}
+
+// printDryRunReport prints the outcome of a dry run to w.
+func printDryRunReport(w io.Writer, report *DryRunReport, forceSemVerPrefix bool) {
+	if report.Version != "" {
+		fmt.Fprintf(w, "Dry run: would install %s (current: %s).\n",
+			formatVersionForDisplay(report.Version, forceSemVerPrefix),
+			formatVersionForDisplay(report.Current, forceSemVerPrefix))
+	} else {
+		fmt.Fprintln(w, "Dry run: update downloaded and verified, not applied.")
+	}
+	fmt.Fprintf(w, "  Asset:   %s\n", report.Asset)
+	fmt.Fprintf(w, "  URL:     %s\n", report.URL)
+	fmt.Fprintf(w, "  Size:    %d bytes\n", report.Size)
+	fmt.Fprintf(w, "  SHA-256: %s\n", report.SHA256)
+	switch report.VerifiedBy {
+	case "":
+		fmt.Fprintln(w, "  Checked: no published checksum")
+	case "digest":
+		fmt.Fprintln(w, "  Checked: matches the release asset digest")
+	default:
+		fmt.Fprintf(w, "  Checked: matches %s\n", report.VerifiedBy)
+	}
+	if report.InstallError != "" {
+		fmt.Fprintf(w, "  Install: would fail: %s\n", report.InstallError)
+	} else {
+		fmt.Fprintln(w, "  Install: ok")
+	}
+}
+
+// LastDryRun returns the report of the most recent dry run, or nil if the
+// service has not performed one.
+func (s *UpdateService) LastDryRun() *DryRunReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dryRun == nil {
+		return nil
+	}
+	report := *s.dryRun
+	return &report
+}
+
+// runDry downloads the update from current to version from url, verifies it
+// against sum, checks the installation, and reports what would have been
+// installed without applying it.
+func (s *UpdateService) runDry(version, current, url string, sum assetChecksum) error {
+	s.announce(version, current, "Dry run, not applying.")
+
+	report, err := dryRunDownload(s.downloader(), url, sum, s.binaryName())
+	if err != nil {
+		return err
+	}
+	report.Version = version
+	report.Current = current
+	if err := s.installationError(); err != nil {
+		report.InstallError = err.Error()
+	}
+
+	s.mu.Lock()
+	s.dryRun = report
+	s.mu.Unlock()
+	s.record(UpdateDryRun, version, current)
+
+	printDryRunReport(s.out(), report, s.config.ForceSemVerPrefix)
+	return nil
+}
@@ -0,0 +1,198 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDryRunDownload(t *testing.T) {
+	archive := makeArchive(t, "agent_linux_amd64.tar.gz", []archiveFile{
+		{name: "README.md", mode: 0644, content: "readme"},
+		{name: "agent", mode: 0755, content: "new binary"},
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download/agent_linux_amd64":
+			fmt.Fprint(w, "new binary")
+		case "/download/agent_linux_amd64.tar.gz":
+			w.Write(archive)
+		case "/download/empty":
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte("new binary"))
+
+	testCases := []struct {
+		name        string
+		path        string
+		expectAsset string
+		expectError string
+	}{
+		{name: "downloaded", path: "/download/agent_linux_amd64?token=abc", expectAsset: "agent_linux_amd64"},
+		{name: "archive", path: "/download/agent_linux_amd64.tar.gz", expectAsset: "agent_linux_amd64.tar.gz"},
+		{name: "not found", path: "/download/missing", expectError: "status code 404"},
+		{name: "empty", path: "/download/empty", expectError: "is empty"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report, err := dryRunDownload(DownloadUpdate, server.URL+tc.path, assetChecksum{}, "agent")
+			if tc.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+					t.Errorf("expected error containing %q, got %v", tc.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dryRunDownload failed: %v", err)
+			}
+			if report.Asset != tc.expectAsset || report.Size != int64(len("new binary")) || report.SHA256 != hex.EncodeToString(sum[:]) {
+				t.Errorf("unexpected report %+v", report)
+			}
+		})
+	}
+}
+
+func TestDryRunDownload_Verification(t *testing.T) {
+	sum := sha256.Sum256([]byte("new binary"))
+	checksums := hex.EncodeToString(sum[:]) + "  agent_linux_amd64\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download/checksums.txt":
+			fmt.Fprint(w, "0000  other_file\n"+checksums)
+		case "/download/bad_checksums.txt":
+			fmt.Fprint(w, strings.Repeat("0", 64)+" *agent_linux_amd64\n")
+		default:
+			fmt.Fprint(w, "new binary")
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name           string
+		assets         []ReleaseAsset
+		expectVerified string
+		expectError    string
+	}{
+		{name: "digest", assets: []ReleaseAsset{{Digest: "sha256:" + hex.EncodeToString(sum[:])}}, expectVerified: "digest"},
+		{name: "wrong digest", assets: []ReleaseAsset{{Digest: ociDigest("old binary")}}, expectError: "does not match digest"},
+		{name: "checksums", assets: []ReleaseAsset{{}, {Name: "checksums.txt", DownloadURL: server.URL + "/download/checksums.txt"}}, expectVerified: "checksums.txt"},
+		{name: "wrong checksum", assets: []ReleaseAsset{{}, {Name: "bad_checksums.txt", DownloadURL: server.URL + "/download/bad_checksums.txt"}}, expectError: "does not match bad_checksums.txt"},
+		{name: "no checksum", assets: []ReleaseAsset{{}}},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.assets[0].Name = "agent_" + runtime.GOOS + "_" + runtime.GOARCH
+			tc.assets[0].DownloadURL = fmt.Sprintf("%s/%d/agent_linux_amd64", server.URL, i)
+			release := &Release{TagName: "v1.1.0", Assets: tc.assets}
+			url, err := GetDownloadURL(release, "")
+			if err != nil {
+				t.Fatalf("GetDownloadURL failed: %v", err)
+			}
+
+			report, err := dryRunDownload(DownloadUpdate, url, releaseChecksum(release, url), "agent")
+			if tc.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+					t.Errorf("expected error containing %q, got %v", tc.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dryRunDownload failed: %v", err)
+			}
+			if report.VerifiedBy != tc.expectVerified {
+				t.Errorf("expected verification by %q, got %q", tc.expectVerified, report.VerifiedBy)
+			}
+		})
+	}
+}
+
+func TestUpdateService_DryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprintf(w, `{"version": "1.1.0", "url": "http://%s/agent_linux_amd64"}`, r.Host)
+		case "/agent_linux_amd64":
+			fmt.Fprint(w, "new binary")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalDoUpdate := DoUpdate
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalVersion := Version
+	defer func() {
+		DoUpdate = originalDoUpdate
+		ApplyUpdateFile = originalApplyUpdateFile
+		Version = originalVersion
+	}()
+	DoUpdate = func(url string) error {
+		t.Errorf("expected DoUpdate not to be called in a dry run")
+		return nil
+	}
+	ApplyUpdateFile = func(path, target string) error {
+		t.Errorf("expected ApplyUpdateFile not to be called in a dry run")
+		return nil
+	}
+	Version = "1.0.0"
+
+	stagingDir := t.TempDir()
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        server.URL,
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		DryRun:         true,
+		StageUpdates:   true,
+		StagingDir:     stagingDir,
+		StatePath:      filepath.Join(t.TempDir(), "state.json"),
+		LockPath:       filepath.Join(t.TempDir(), "update.lock"),
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	if service.LastDryRun() != nil {
+		t.Errorf("expected no dry run report before Start")
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	report := service.LastDryRun()
+	if report == nil {
+		t.Fatalf("expected a dry run report")
+	}
+	sum := sha256.Sum256([]byte("new binary"))
+	if report.Version != "1.1.0" || report.Current != "1.0.0" || report.Asset != "agent_linux_amd64" ||
+		report.Size != int64(len("new binary")) || report.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if staged, err := LoadStagedUpdate(stagingDir); staged != nil || err != nil {
+		t.Errorf("expected nothing to be staged in a dry run, got %+v (%v)", staged, err)
+	}
+	if report.InstallError != "" {
+		t.Errorf("expected no installation problem, got %q", report.InstallError)
+	}
+
+	// An installation that cannot be updated is reported, not an error.
+	originalCheckInstallation := CheckInstallation
+	defer func() { CheckInstallation = originalCheckInstallation }()
+	CheckInstallation = func(target string) error {
+		return &ManagedInstallError{Path: "/usr/bin/agent", Manager: "dpkg", UpgradeCommand: "sudo apt upgrade agent"}
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("expected the dry run to report the installation check, got %v", err)
+	}
+	if report := service.LastDryRun(); report == nil || !strings.Contains(report.InstallError, "dpkg") {
+		t.Errorf("expected the managed installation in the report, got %+v", report)
+	}
+}
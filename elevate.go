@@ -0,0 +1,123 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Elevator replaces the binary at target with the downloaded update at path
+// using elevated privileges. It is used when the current user cannot write the
+// target, such as a binary in /usr/local/bin owned by root.
+type Elevator func(path, target string) error
+
+// CommandElevator returns an Elevator that installs the update through a
+// privilege escalation command, such as "sudo" or "pkexec". The command runs a
+// POSIX sh(1) script that copies the update next to the target with cp(1),
+// sets its mode with chmod(1) and moves it over the target with mv(1), so a
+// running binary is replaced rather than overwritten. The command is
+// connected to the terminal, so the user can enter a password. The file mode
+// of the replaced binary is kept.
+//
+// It requires a Unix-like system; on Windows the Elevator returns an error,
+// and a custom Elevator must be used instead.
+//
+// Example:
+//
+//	service, _ := updater.NewUpdateService(updater.UpdateServiceConfig{
+//		RepoURL:        "https://github.com/owner/repo",
+//		CheckOnStartup: updater.CheckAndUpdateOnStartup,
+//		Elevate:        updater.CommandElevator("sudo"),
+//	})
+func CommandElevator(command ...string) Elevator {
+	return func(path, target string) error {
+		if len(command) == 0 {
+			return errors.New("no elevation command configured")
+		}
+		if runtime.GOOS == "windows" {
+			return errors.New("CommandElevator is not supported on Windows; configure a custom Elevator")
+		}
+		mode := os.FileMode(0755)
+		if info, err := os.Stat(target); err == nil {
+			mode = info.Mode().Perm()
+		}
+
+		script := fmt.Sprintf(`cp -- "$1" "$3" && chmod %o "$3" && mv -f -- "$3" "$2" || { rm -f -- "$3"; exit 1; }`, mode)
+		args := append(command[1:len(command):len(command)], "sh", "-c", script, "sh", path, target, target+".update")
+		cmd := runElevated(command[0], args...)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to apply update with %s: %w", command[0], err)
+		}
+		return nil
+	}
+}
+
+// runElevated returns the command running name with args, attached to the
+// terminal. This can be replaced in tests.
+var runElevated = func(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// checkInstallation verifies the target binary can be updated before anything
+// is downloaded. A dry run downloads the update regardless and reports the
+// outcome of the check instead.
+func (s *UpdateService) checkInstallation() error {
+	if s.config.DryRun {
+		return nil
+	}
+	return s.installationError()
+}
+
+// installationError returns why the target binary cannot be updated, or nil.
+// A target that is not writable is accepted if the service can elevate
+// privileges to replace it.
+func (s *UpdateService) installationError() error {
+	err := CheckInstallation(s.config.TargetPath)
+	if s.config.Elevate != nil && errors.Is(err, ErrNotWritable) {
+		return nil
+	}
+	return err
+}
+
+// needsElevation reports whether the target binary can only be replaced
+// through the configured Elevate hook.
+func (s *UpdateService) needsElevation() (bool, error) {
+	if s.config.Elevate == nil {
+		return false, nil
+	}
+	target, err := s.TargetPath()
+	if err != nil {
+		return false, err
+	}
+	return checkWritable(target) != nil, nil
+}
+
+// applyFile replaces the target binary with the downloaded update at path,
+// elevating privileges if the target is not writable.
+func (s *UpdateService) applyFile(path string) error {
+	elevate, err := s.needsElevation()
+	if err != nil {
+		return err
+	}
+	if elevate {
+		target, err := s.TargetPath()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out(), "%s is not writable; elevating privileges to apply the update.\n", target)
+		err = s.config.Elevate(path, target)
+	} else {
+		err = ApplyUpdateFile(path, s.config.TargetPath)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out(), "Update applied successfully.")
+	return nil
+}
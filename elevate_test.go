@@ -0,0 +1,155 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCommandElevator(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		if err := CommandElevator("runas")("update", "agent"); err == nil {
+			t.Errorf("expected CommandElevator to be refused on Windows")
+		}
+		return
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh(1) is not available")
+	}
+
+	originalRunElevated := runElevated
+	defer func() { runElevated = originalRunElevated }()
+
+	var elevatedWith []string
+	runElevated = func(name string, args ...string) *exec.Cmd {
+		elevatedWith = append([]string{name}, args[:2]...)
+		// Run the script without escalating privileges.
+		return exec.Command(args[2], args[3:]...)
+	}
+
+	dir := t.TempDir()
+	update := filepath.Join(dir, "update")
+	target := filepath.Join(dir, "agent")
+	if err := os.WriteFile(update, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("old"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CommandElevator("sudo", "-p", "password: ")(update, target); err != nil {
+		t.Fatalf("elevator failed: %v", err)
+	}
+	if fmt.Sprint(elevatedWith) != "[sudo -p password: ]" {
+		t.Errorf("unexpected elevation command %v", elevatedWith)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil || string(data) != "new" {
+		t.Errorf("expected target to be replaced, got %q (%v)", data, err)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("expected mode 0750 to be kept, got %v", info.Mode().Perm())
+	}
+	if _, err := os.Stat(target + ".update"); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary copy to be moved, got %v", err)
+	}
+
+	if err := CommandElevator("sudo")(filepath.Join(dir, "missing"), target); err == nil {
+		t.Errorf("expected an error for a missing update")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("expected the target to be kept after a failed copy, got %q", data)
+	}
+
+	if err := CommandElevator()(update, target); err == nil {
+		t.Errorf("expected an error without an elevation command")
+	}
+}
+
+func TestUpdateService_Elevate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest.json" {
+			fmt.Fprintln(w, `{"version": "1.1.0", "url": "http://example.com/update"}`)
+		}
+	}))
+	defer server.Close()
+
+	originalCheckInstallation := CheckInstallation
+	originalCheckWritable := checkWritable
+	originalDownloadUpdate := DownloadUpdate
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalDetectVersion := DetectVersion
+	defer func() {
+		CheckInstallation = originalCheckInstallation
+		checkWritable = originalCheckWritable
+		DownloadUpdate = originalDownloadUpdate
+		ApplyUpdateFile = originalApplyUpdateFile
+		DetectVersion = originalDetectVersion
+	}()
+
+	target := filepath.Join(t.TempDir(), "agent")
+	errDenied := errors.New("permission denied")
+	CheckInstallation = func(path string) error {
+		return &NotWritableError{Path: path, Err: errDenied}
+	}
+	checkWritable = func(path string) error { return errDenied }
+	DetectVersion = func(path string) (string, error) { return "1.0.0", nil }
+	ApplyUpdateFile = func(path, target string) error {
+		t.Errorf("expected the update not to be applied without elevation")
+		return nil
+	}
+
+	testCases := []struct {
+		name           string
+		elevate        bool
+		expectError    error
+		expectDownload bool
+	}{
+		{name: "without elevator", expectError: ErrNotWritable},
+		{name: "with elevator", elevate: true, expectDownload: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			downloaded := false
+			DownloadUpdate = func(url, path string) error {
+				downloaded = true
+				return os.WriteFile(path, []byte("new"), 0644)
+			}
+			var elevated string
+			config := UpdateServiceConfig{
+				RepoURL:        server.URL,
+				CheckOnStartup: CheckAndUpdateOnStartup,
+				TargetPath:     target,
+				StatePath:      filepath.Join(t.TempDir(), "state.json"),
+				LockPath:       filepath.Join(t.TempDir(), "update.lock"),
+			}
+			if tc.elevate {
+				config.Elevate = func(path, target string) error {
+					elevated = target
+					return nil
+				}
+			}
+
+			service, err := NewUpdateService(config)
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+			err = service.Start()
+			if !errors.Is(err, tc.expectError) {
+				t.Fatalf("expected error %v, got %v", tc.expectError, err)
+			}
+			if downloaded != tc.expectDownload {
+				t.Errorf("expected download %v, got %v", tc.expectDownload, downloaded)
+			}
+			if tc.elevate && elevated != target {
+				t.Errorf("expected %s to be replaced through the elevator, got %q", target, elevated)
+			}
+		})
+	}
+}
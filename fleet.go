@@ -0,0 +1,208 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultFleetParallelism is how many products SyncFleet updates at once when
+// the fleet configuration does not say otherwise.
+const DefaultFleetParallelism = 4
+
+// Product is a binary managed by a fleet configuration.
+type Product struct {
+	// Name identifies the product in the sync summary.
+	Name string `json:"name"`
+	// RepoURL is the GitHub repository or generic HTTP update server of the
+	// product, as in UpdateServiceConfig.
+	RepoURL string `json:"repo_url"`
+	// Channel is the release channel to track. If empty, it is determined from
+	// the installed version.
+	Channel string `json:"channel,omitempty"`
+	// TargetPath is the location of the product's binary.
+	TargetPath string `json:"target_path"`
+	// VersionConstraint restricts updates to matching versions, e.g. "~1.6".
+	VersionConstraint string `json:"version_constraint,omitempty"`
+	// PinnedVersion restricts updates to a single exact version.
+	PinnedVersion string `json:"pinned_version,omitempty"`
+	// ReleaseURLFormat is the template for release asset URLs, as in
+	// UpdateServiceConfig.
+	ReleaseURLFormat string `json:"release_url_format,omitempty"`
+}
+
+// FleetConfig lists the products kept up to date by SyncFleet.
+//
+// Example of fleet.json:
+//
+//	{
+//	  "parallelism": 2,
+//	  "products": [
+//	    {"name": "agent", "repo_url": "https://github.com/owner/agent", "target_path": "/opt/tools/agent"},
+//	    {"name": "helper", "repo_url": "https://updates.example.com/helper", "target_path": "/opt/tools/helper", "version_constraint": "^2"}
+//	  ]
+//	}
+type FleetConfig struct {
+	// Parallelism is how many products are updated at once. Defaults to
+	// DefaultFleetParallelism.
+	Parallelism int `json:"parallelism,omitempty"`
+	// Products are the products to keep up to date.
+	Products []Product `json:"products"`
+	// Output receives the messages of the products, each line prefixed with
+	// the product's name. If nil, they are printed to os.Stdout.
+	Output io.Writer `json:"-"`
+}
+
+// DefaultFleetConfigPath returns the default location of the fleet
+// configuration, fleet.json in the user configuration directory.
+func DefaultFleetConfigPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine config directory: %w", err)
+	}
+	return filepath.Join(configDir, "updater", "fleet.json"), nil
+}
+
+// LoadFleetConfig reads and validates the fleet configuration at path. Every
+// product needs a unique name, a repository URL and a target path.
+func LoadFleetConfig(path string) (*FleetConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fleet config: %w", err)
+	}
+
+	var config FleetConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse fleet config %s: %w", path, err)
+	}
+
+	names := make(map[string]bool)
+	for i, product := range config.Products {
+		switch {
+		case product.Name == "":
+			return nil, fmt.Errorf("product %d in %s has no name", i+1, path)
+		case names[product.Name]:
+			return nil, fmt.Errorf("duplicate product %q in %s", product.Name, path)
+		case product.RepoURL == "":
+			return nil, fmt.Errorf("product %q in %s has no repo_url", product.Name, path)
+		case product.TargetPath == "":
+			return nil, fmt.Errorf("product %q in %s has no target_path", product.Name, path)
+		}
+		names[product.Name] = true
+	}
+	return &config, nil
+}
+
+// SyncResult is the outcome of syncing one product.
+type SyncResult struct {
+	// Product is the name of the product.
+	Product string
+	// Previous is the version installed before the sync, if it was detected.
+	Previous string
+	// Current is the version installed after the sync, if it was detected.
+	Current string
+	// Err is set if the product could not be checked or updated.
+	Err error
+}
+
+// Updated reports whether the sync installed a different version.
+func (r SyncResult) Updated() bool {
+	return r.Err == nil && canonicalVersion(r.Previous) != canonicalVersion(r.Current)
+}
+
+// SyncFleet checks every product of the fleet for updates and applies them,
+// running up to the configured parallelism at a time. Each product is updated
+// by its own UpdateService, holding the update lock of its target. Results are
+// returned in the order of the configuration. Products not yet started when
+// ctx is done are reported with the context's error.
+//
+// The messages of each product are buffered and printed once it is done, in
+// the order of the configuration and prefixed with the product's name, so
+// that the output of products updated at once does not interleave.
+func SyncFleet(ctx context.Context, config *FleetConfig) []SyncResult {
+	var w io.Writer = os.Stdout
+	if config.Output != nil {
+		w = config.Output
+	}
+	parallelism := config.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultFleetParallelism
+	}
+
+	results := make([]SyncResult, len(config.Products))
+	outputs := make([]bytes.Buffer, len(config.Products))
+	done := make([]chan struct{}, len(config.Products))
+	sem := make(chan struct{}, parallelism)
+	for i, product := range config.Products {
+		done[i] = make(chan struct{})
+		go func() {
+			defer close(done[i])
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			if err := ctx.Err(); err != nil {
+				results[i] = SyncResult{Product: product.Name, Err: err}
+				return
+			}
+			results[i] = syncProduct(product, &outputs[i])
+		}()
+	}
+	for i, product := range config.Products {
+		<-done[i]
+		writePrefixed(w, "["+product.Name+"] ", outputs[i].String())
+	}
+	return results
+}
+
+// writePrefixed writes every line of output to w, preceded by prefix.
+func writePrefixed(w io.Writer, prefix, output string) {
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		fmt.Fprint(w, prefix+line)
+	}
+}
+
+// syncProduct updates a single product, printing its messages to out, and
+// records the versions installed before and after.
+func syncProduct(product Product, out io.Writer) SyncResult {
+	result := SyncResult{Product: product.Name}
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:           product.RepoURL,
+		Channel:           product.Channel,
+		CheckOnStartup:    CheckAndUpdateOnStartup,
+		ForceSemVerPrefix: true,
+		ReleaseURLFormat:  product.ReleaseURLFormat,
+		VersionConstraint: product.VersionConstraint,
+		PinnedVersion:     product.PinnedVersion,
+		TargetPath:        product.TargetPath,
+		Output:            out,
+	})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if result.Previous, err = service.CurrentVersion(); err != nil {
+		result.Err = err
+		return result
+	}
+	if err := service.Start(); err != nil {
+		result.Err = err
+		return result
+	}
+	result.Current, result.Err = service.CurrentVersion()
+	return result
+}
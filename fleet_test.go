@@ -0,0 +1,179 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadFleetConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		content     string
+		expectError string
+	}{
+		{
+			name:    "valid",
+			content: `{"parallelism": 2, "products": [{"name": "agent", "repo_url": "https://github.com/owner/agent", "target_path": "/opt/agent", "version_constraint": "~1.6"}]}`,
+		},
+		{name: "invalid JSON", content: `{`, expectError: "failed to parse"},
+		{name: "no name", content: `{"products": [{"repo_url": "u", "target_path": "t"}]}`, expectError: "has no name"},
+		{name: "duplicate", content: `{"products": [{"name": "a", "repo_url": "u", "target_path": "t"}, {"name": "a", "repo_url": "u", "target_path": "t"}]}`, expectError: "duplicate product"},
+		{name: "no repo", content: `{"products": [{"name": "a", "target_path": "t"}]}`, expectError: "has no repo_url"},
+		{name: "no target", content: `{"products": [{"name": "a", "repo_url": "u"}]}`, expectError: "has no target_path"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fleet.json")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadFleetConfig(path)
+			if tc.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+					t.Errorf("expected error containing %q, got %v", tc.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFleetConfig failed: %v", err)
+			}
+			if config.Parallelism != 2 || len(config.Products) != 1 || config.Products[0].VersionConstraint != "~1.6" {
+				t.Errorf("unexpected config %+v", config)
+			}
+		})
+	}
+}
+
+func TestSyncFleet(t *testing.T) {
+	latest := map[string]string{"agent": "1.3.0", "helper": "2.0.0", "monitor": "0.9.0", "cli": "3.1.0"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		product, file := filepath.Split(r.URL.Path)
+		product = strings.Trim(product, "/")
+		switch file {
+		case "latest.json":
+			fmt.Fprintf(w, `{"version": %q, "url": "http://%s/%s/binary"}`, latest[product], r.Host, product)
+		case "binary":
+			fmt.Fprint(w, latest[product])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalDetectVersion := DetectVersion
+	originalApplyUpdateFile := ApplyUpdateFile
+	defer func() {
+		DetectVersion = originalDetectVersion
+		ApplyUpdateFile = originalApplyUpdateFile
+	}()
+
+	dir := t.TempDir()
+	installed := map[string]string{
+		filepath.Join(dir, "agent"):   "1.2.0",
+		filepath.Join(dir, "helper"):  "2.0.0",
+		filepath.Join(dir, "monitor"): "0.8.0",
+		filepath.Join(dir, "cli"):     "3.0.0",
+	}
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	DetectVersion = func(path string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		version, ok := installed[path]
+		if !ok {
+			return "", errors.New("not installed")
+		}
+		return version, nil
+	}
+	ApplyUpdateFile = func(path, target string) error {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		data, err := os.ReadFile(path)
+
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight--
+		installed[target] = string(data)
+		return err
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	config := &FleetConfig{Parallelism: 2}
+	for _, name := range []string{"agent", "helper", "monitor", "cli", "missing"} {
+		config.Products = append(config.Products, Product{
+			Name:       name,
+			RepoURL:    server.URL + "/" + name,
+			TargetPath: filepath.Join(dir, name),
+		})
+	}
+	config.Products[3].VersionConstraint = "~3.0"
+
+	var out strings.Builder
+	config.Output = &out
+	results := SyncFleet(context.Background(), config)
+
+	expected := []struct {
+		previous, current string
+		updated, failed   bool
+	}{
+		{"1.2.0", "1.3.0", true, false},
+		{"2.0.0", "2.0.0", false, false},
+		{"0.8.0", "0.9.0", true, false},
+		{"3.0.0", "3.0.0", false, false}, // 3.1.0 is outside ~3.0
+		{"", "", false, true},
+	}
+	for i, want := range expected {
+		got := results[i]
+		if got.Product != config.Products[i].Name || got.Previous != want.previous || got.Current != want.current ||
+			got.Updated() != want.updated || (got.Err != nil) != want.failed {
+			t.Errorf("product %s: unexpected result %+v", config.Products[i].Name, got)
+		}
+	}
+	if maxInFlight > 2 {
+		t.Errorf("expected at most 2 products to be updated at once, got %d", maxInFlight)
+	}
+	// Each product's messages are printed together, in configuration order.
+	var products []string
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		name, _, ok := strings.Cut(strings.TrimPrefix(line, "["), "] ")
+		if !ok || !strings.HasPrefix(line, "[") {
+			t.Fatalf("expected every line to be prefixed with its product, got %q", line)
+		}
+		if len(products) == 0 || products[len(products)-1] != name {
+			products = append(products, name)
+		}
+	}
+	if got := strings.Join(products, ","); got != "agent,helper,monitor,cli" {
+		t.Errorf("expected the output of each product in configuration order, got %s\n%s", got, out.String())
+	}
+	if !strings.Contains(out.String(), "[agent] Update applied successfully.\n") {
+		t.Errorf("expected the agent update to be reported, got\n%s", out.String())
+	}
+}
+
+func TestSyncFleet_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := &FleetConfig{Products: []Product{{Name: "agent", RepoURL: "https://example.com", TargetPath: "/opt/agent"}}}
+	results := SyncFleet(ctx, config)
+	if len(results) != 1 || !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("expected the product to be canceled, got %+v", results)
+	}
+}
@@ -10,8 +10,19 @@ import (
 // GenericUpdateInfo holds the information from a latest.json file.
 // This file is expected to be at the root of a generic HTTP update server.
 type GenericUpdateInfo struct {
-	Version string `json:"version"` // The version number of the update.
-	URL     string `json:"url"`     // The URL to download the update from.
+	Version    string `json:"version"`               // The version number of the update.
+	URL        string `json:"url"`                   // The URL to download the update from.
+	MinVersion string `json:"min_version,omitempty"` // The minimum supported version; older clients must update.
+	Rollout    *int   `json:"rollout,omitempty"`     // The percentage of installations offered the update; all if omitted.
+	SHA256     string `json:"sha256,omitempty"`      // The hex-encoded SHA-256 checksum of the update; downloads are verified against it.
+}
+
+// checksum returns what the download of the update is verified against.
+func (info *GenericUpdateInfo) checksum() assetChecksum {
+	if info.SHA256 == "" {
+		return assetChecksum{}
+	}
+	return assetChecksum{digest: "sha256:" + info.SHA256}
 }
 
 // GetLatestUpdateFromURL fetches and parses a latest.json file from a base URL.
@@ -22,8 +33,16 @@ type GenericUpdateInfo struct {
 //
 //	{
 //	  "version": "1.2.3",
-//	  "url": "https://your-server.com/path/to/release-asset"
+//	  "url": "https://your-server.com/path/to/release-asset",
+//	  "min_version": "1.1.0",
+//	  "rollout": 25,
+//	  "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
 //	}
+//
+// The optional min_version field marks updates as mandatory for clients running
+// an older version, the optional rollout field offers the update to only a
+// percentage of installations, and the optional sha256 field is the checksum
+// the download is verified against.
 func GetLatestUpdateFromURL(baseURL string) (*GenericUpdateInfo, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
@@ -32,7 +51,7 @@ func GetLatestUpdateFromURL(baseURL string) (*GenericUpdateInfo, error) {
 	// Append latest.json to the path
 	u.Path += "/latest.json"
 
-	resp, err := http.Get(u.String())
+	resp, err := clientFor(baseURL).Get(u.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch latest.json: %w", err)
 	}
@@ -51,5 +70,16 @@ func GetLatestUpdateFromURL(baseURL string) (*GenericUpdateInfo, error) {
 		return nil, fmt.Errorf("invalid latest.json content: version or url is missing")
 	}
 
+	// A relative url is resolved against latest.json, so a release directory
+	// works wherever it is served or mounted.
+	ref, err := url.Parse(info.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latest.json content: %w", err)
+	}
+	info.URL = u.ResolveReference(ref).String()
+	if err := checkSourceURL(baseURL, info.URL); err != nil {
+		return nil, fmt.Errorf("invalid latest.json content: %w", err)
+	}
+
 	return &info, nil
 }
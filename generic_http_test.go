@@ -2,8 +2,12 @@ package updater
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -14,6 +18,7 @@ func TestGetLatestUpdateFromURL(t *testing.T) {
 		expectError     bool
 		expectedVersion string
 		expectedURL     string
+		expectedMinVer  string
 	}{
 		{
 			name: "Valid latest.json",
@@ -23,6 +28,15 @@ func TestGetLatestUpdateFromURL(t *testing.T) {
 			expectedVersion: "v1.1.0",
 			expectedURL:     "http://example.com/release.zip",
 		},
+		{
+			name: "With min_version",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"version": "v1.1.0", "url": "http://example.com/release.zip", "min_version": "v1.0.5"}`)
+			},
+			expectedVersion: "v1.1.0",
+			expectedURL:     "http://example.com/release.zip",
+			expectedMinVer:  "v1.0.5",
+		},
 		{
 			name: "Invalid JSON",
 			handler: func(w http.ResponseWriter, r *http.Request) {
@@ -71,6 +85,74 @@ func TestGetLatestUpdateFromURL(t *testing.T) {
 				if info.URL != tc.expectedURL {
 					t.Errorf("Expected URL: %s, got: %s", tc.expectedURL, info.URL)
 				}
+				if info.MinVersion != tc.expectedMinVer {
+					t.Errorf("Expected min version: %s, got: %s", tc.expectedMinVer, info.MinVersion)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateService_HTTPChecksum(t *testing.T) {
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalVersion := Version
+	defer func() {
+		ApplyUpdateFile = originalApplyUpdateFile
+		Version = originalVersion
+	}()
+	Version = "1.0.0"
+
+	testCases := []struct {
+		name        string
+		sha256      string
+		expectApply bool
+		expectError string
+	}{
+		{name: "matching checksum", sha256: strings.TrimPrefix(ociDigest("new binary"), "sha256:"), expectApply: true},
+		{name: "mismatching checksum", sha256: strings.Repeat("0", 64), expectError: "does not match digest"},
+		{name: "no checksum", expectApply: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/latest.json":
+					fmt.Fprintf(w, `{"version": "1.1.0", "url": "http://%s/binary", "sha256": %q}`, r.Host, tc.sha256)
+				case "/binary":
+					fmt.Fprint(w, "new binary")
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			var applied []string
+			ApplyUpdateFile = func(path, target string) error {
+				data, _ := os.ReadFile(path)
+				applied = append(applied, string(data))
+				return nil
+			}
+
+			service, err := NewUpdateService(UpdateServiceConfig{
+				RepoURL:        server.URL,
+				CheckOnStartup: CheckAndUpdateOnStartup,
+				LockPath:       filepath.Join(t.TempDir(), "update.lock"),
+				Output:         io.Discard,
+			})
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+
+			err = service.Start()
+			if tc.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+					t.Errorf("expected error containing %q, got %v", tc.expectError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			if (len(applied) == 1) != tc.expectApply {
+				t.Errorf("expected applied %v, got %v", tc.expectApply, applied)
 			}
 		})
 	}
@@ -8,7 +8,9 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
+	"golang.org/x/mod/semver"
 	"golang.org/x/oauth2"
 )
 
@@ -19,15 +21,30 @@ type Repo struct {
 
 // ReleaseAsset represents a single asset from a GitHub release.
 type ReleaseAsset struct {
-	Name        string `json:"name"`                  // The name of the asset.
+	Name        string `json:"name"`                 // The name of the asset.
 	DownloadURL string `json:"browser_download_url"` // The URL to download the asset.
+	Size        int64  `json:"size"`                 // The size of the asset in bytes, or 0 if unknown.
+	ContentType string `json:"content_type"`         // The media type of the asset, e.g. "application/octet-stream".
+	Digest      string `json:"digest"`               // The digest of the asset, e.g. "sha256:<hex>", if known. Downloads of the asset are verified against it.
+}
+
+// ReleaseAuthor is the GitHub user who published a release.
+type ReleaseAuthor struct {
+	Login   string `json:"login"`    // The user name.
+	HTMLURL string `json:"html_url"` // The user's profile page.
 }
 
 // Release represents a GitHub release.
 type Release struct {
-	TagName    string         `json:"tag_name"`    // The name of the tag for the release.
-	PreRelease bool           `json:"prerelease"`  // Indicates if the release is a pre-release.
-	Assets     []ReleaseAsset `json:"assets"`      // A list of assets associated with the release.
+	TagName     string         `json:"tag_name"`     // The name of the tag for the release.
+	Name        string         `json:"name"`         // The title of the release, which may be empty.
+	PreRelease  bool           `json:"prerelease"`   // Indicates if the release is a pre-release.
+	Assets      []ReleaseAsset `json:"assets"`       // A list of assets associated with the release.
+	Body        string         `json:"body"`         // The release notes, which may carry updater directives.
+	PublishedAt time.Time      `json:"published_at"` // When the release was published, or the zero time if unknown.
+	Author      ReleaseAuthor  `json:"author"`       // The user who published the release.
+	HTMLURL     string         `json:"html_url"`     // The release page on GitHub.
+	Channel     string         `json:"-"`            // The channel the source published the release to, if it records one, such as the channel directory of an S3 release.
 }
 
 // GithubClient defines the interface for interacting with the GitHub API.
@@ -41,6 +58,37 @@ type GithubClient interface {
 	GetReleaseByPullRequest(ctx context.Context, owner, repo string, prNumber int) (*Release, error)
 }
 
+// ReleaseLister is implemented by GithubClients that can list every release of
+// a repository, as the default client does. Installing an exact version and
+// the release policies of UpdateService use it when the client implements it.
+type ReleaseLister interface {
+	// ListReleases fetches all published releases for a given repository.
+	ListReleases(ctx context.Context, owner, repo string) ([]Release, error)
+}
+
+// listGithubReleases lists the releases of a repository with client. Clients
+// that do not implement ReleaseLister only offer the latest release of each
+// default channel.
+func listGithubReleases(ctx context.Context, client GithubClient, owner, repo string) ([]Release, error) {
+	if lister, ok := client.(ReleaseLister); ok {
+		return lister.ListReleases(ctx, owner, repo)
+	}
+	var releases []Release
+	seen := make(map[string]bool)
+	for _, channel := range DefaultChannelRules.Names() {
+		release, err := client.GetLatestRelease(ctx, owner, repo, channel)
+		if err != nil {
+			return nil, err
+		}
+		if release != nil && !seen[release.TagName] {
+			seen[release.TagName] = true
+			releases = append(releases, *release)
+		}
+	}
+	sortReleases(releases)
+	return releases, nil
+}
+
 type githubClient struct{}
 
 // NewAuthenticatedClient creates a new HTTP client that authenticates with the GitHub API.
@@ -137,86 +185,119 @@ func (g *githubClient) findNextURL(linkHeader string) string {
 }
 
 // GetLatestRelease fetches the latest release for a given repository and channel.
-// The channel can be "stable", "beta", or "alpha".
+// The channel can be "stable", "beta", or "alpha"; see DefaultChannelRules.
+// GitHub lists the newest releases first, so no further pages are fetched once
+// a release in the channel newer than the running version is found.
 func (g *githubClient) GetLatestRelease(ctx context.Context, owner, repo, channel string) (*Release, error) {
-	client := NewAuthenticatedClient(ctx)
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	releases, err := g.fetchReleases(ctx, fmt.Sprintf("https://api.github.com/repos/%s/%s/releases?per_page=100", owner, repo), func(page []Release) bool {
+		for i := range page {
+			release := &page[i]
+			if !isReleaseBlocked(release) && DefaultChannelRules.Receives(channel, DefaultChannelRules.ReleaseChannel(release)) &&
+				semver.Compare(canonicalVersion(release.TagName), canonicalVersion(Version)) > 0 {
+				return true
+			}
+		}
+		return false
+	})
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", "Borg-Data-Collector")
+	return filterReleases(releases, channel), nil
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// ListReleases fetches all releases for a given repository, newest first.
+func (g *githubClient) ListReleases(ctx context.Context, owner, repo string) ([]Release, error) {
+	return g.fetchReleases(ctx, fmt.Sprintf("https://api.github.com/repos/%s/%s/releases?per_page=100", owner, repo), nil)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch releases: %s", resp.Status)
-	}
+// fetchReleases fetches and decodes a list of releases from the given API URL,
+// following the pagination links of the Link header until done, if not nil,
+// reports that a page holds what the caller is looking for.
+func (g *githubClient) fetchReleases(ctx context.Context, url string, done func(page []Release) bool) ([]Release, error) {
+	client := NewAuthenticatedClient(ctx)
+	var allReleases []Release
 
-	var releases []Release
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return nil, err
-	}
+	for url != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", "Borg-Data-Collector")
 
-	return filterReleases(releases, channel), nil
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to fetch releases: %s", resp.Status)
+		}
+
+		var releases []Release
+		if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+		allReleases = append(allReleases, releases...)
+		if done != nil && done(releases) {
+			break
+		}
+
+		url = g.findNextURL(resp.Header.Get("Link"))
+	}
+	return allReleases, nil
 }
 
-// filterReleases filters releases based on the specified channel.
+// filterReleases returns the newest release received by subscribers of the
+// specified channel, so that a beta subscriber is also offered stable releases.
+// Releases marked as blocked are skipped. If no release has a valid semantic
+// version, the first matching release is returned.
 func filterReleases(releases []Release, channel string) *Release {
+	if release := latestMatchingRelease(releases, DefaultChannelRules, channel, func(*Release) bool { return true }); release != nil {
+		return release
+	}
 	for _, release := range releases {
+		if isReleaseBlocked(&release) {
+			continue
+		}
 		releaseChannel := determineChannel(release.TagName, release.PreRelease)
-		if releaseChannel == channel {
+		if DefaultChannelRules.Receives(channel, releaseChannel) {
 			return &release
 		}
 	}
 	return nil
 }
 
-// determineChannel determines the stability channel of a release based on its tag and PreRelease flag.
-func determineChannel(tagName string, isPreRelease bool) string {
-	tagLower := strings.ToLower(tagName)
-	if strings.Contains(tagLower, "alpha") {
-		return "alpha"
-	}
-	if strings.Contains(tagLower, "beta") {
-		return "beta"
-	}
-	if isPreRelease { // A pre-release without alpha/beta is treated as beta
-		return "beta"
+// latestMatchingRelease returns the release with the highest version received by
+// subscribers of channel under rules that is accepted by allow. Releases whose
+// tags are not valid semantic versions, or that are marked as blocked, are
+// ignored.
+func latestMatchingRelease(releases []Release, rules ChannelRules, channel string, allow func(*Release) bool) *Release {
+	var best *Release
+	for i := range releases {
+		release := &releases[i]
+		if isReleaseBlocked(release) || !rules.Receives(channel, rules.ReleaseChannel(release)) {
+			continue
+		}
+		v := formatVersionForComparison(release.TagName)
+		if !semver.IsValid(v) || !allow(release) {
+			continue
+		}
+		if best == nil || semver.Compare(v, formatVersionForComparison(best.TagName)) > 0 {
+			best = release
+		}
 	}
-	return "stable"
+	return best
 }
 
 // GetReleaseByPullRequest fetches a release associated with a specific pull request number.
 func (g *githubClient) GetReleaseByPullRequest(ctx context.Context, owner, repo string, prNumber int) (*Release, error) {
-	client := NewAuthenticatedClient(ctx)
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	releases, err := g.fetchReleases(ctx, fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo), nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", "Borg-Data-Collector")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch releases: %s", resp.Status)
-	}
-
-	var releases []Release
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return nil, err
-	}
 
 	// The pr number is included in the tag name with the format `vX.Y.Z-alpha.pr.123` or `vX.Y.Z-beta.pr.123`
 	prTagSuffix := fmt.Sprintf(".pr.%d", prNumber)
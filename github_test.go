@@ -3,9 +3,11 @@ package updater
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/Snider/Borg/pkg/mocks"
@@ -93,6 +95,38 @@ func TestGetPublicRepos_Error(t *testing.T) {
 	}
 }
 
+func TestListReleases_Pagination(t *testing.T) {
+	mockClient := mocks.NewMockClient(map[string]*http.Response{
+		"https://api.github.com/repos/owner/repo/releases?per_page=100": {
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}, "Link": []string{`<https://api.github.com/repositories/123/releases?per_page=100&page=2>; rel="next", <https://api.github.com/repositories/123/releases?per_page=100&page=2>; rel="last"`}},
+			Body:       io.NopCloser(bytes.NewBufferString(`[{"tag_name": "v1.2.0"}, {"tag_name": "v1.1.0"}]`)),
+		},
+		"https://api.github.com/repositories/123/releases?per_page=100&page=2": {
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}, "Link": []string{`<https://api.github.com/repositories/123/releases?per_page=100&page=1>; rel="prev"`}},
+			Body:       io.NopCloser(bytes.NewBufferString(`[{"tag_name": "v1.0.0"}]`)),
+		},
+	})
+
+	client := &githubClient{}
+	oldClient := NewAuthenticatedClient
+	NewAuthenticatedClient = func(ctx context.Context) *http.Client {
+		return mockClient
+	}
+	defer func() {
+		NewAuthenticatedClient = oldClient
+	}()
+
+	releases, err := client.ListReleases(context.Background(), "owner", "repo")
+	if err != nil {
+		t.Fatalf("ListReleases failed: %v", err)
+	}
+	if len(releases) != 3 || releases[0].TagName != "v1.2.0" || releases[2].TagName != "v1.0.0" {
+		t.Errorf("expected the releases of both pages, got %+v", releases)
+	}
+}
+
 func TestFindNextURL(t *testing.T) {
 	client := &githubClient{}
 	linkHeader := `<https://api.github.com/organizations/123/repos?page=2>; rel="next", <https://api.github.com/organizations/123/repos?page=1>; rel="prev"`
@@ -122,3 +156,104 @@ func TestNewAuthenticatedClient(t *testing.T) {
 		t.Errorf("expected an authenticated client, but got http.DefaultClient")
 	}
 }
+
+// latestOnlyClient is a GithubClient that does not implement ReleaseLister.
+type latestOnlyClient struct {
+	latest map[string]*Release
+}
+
+func (c *latestOnlyClient) GetPublicRepos(ctx context.Context, userOrOrg string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *latestOnlyClient) GetLatestRelease(ctx context.Context, owner, repo, channel string) (*Release, error) {
+	return c.latest[channel], nil
+}
+
+func (c *latestOnlyClient) GetReleaseByPullRequest(ctx context.Context, owner, repo string, prNumber int) (*Release, error) {
+	return nil, nil
+}
+
+func TestListGithubReleases_WithoutLister(t *testing.T) {
+	stable := &Release{TagName: "v1.2.0"}
+	client := &latestOnlyClient{latest: map[string]*Release{
+		"stable": stable,
+		"beta":   stable,
+		"alpha":  {TagName: "v1.3.0-alpha.1", PreRelease: true},
+	}}
+
+	releases, err := listGithubReleases(context.Background(), client, "owner", "repo")
+	if err != nil {
+		t.Fatalf("listGithubReleases failed: %v", err)
+	}
+	if len(releases) != 2 || releases[0].TagName != "v1.3.0-alpha.1" || releases[1].TagName != "v1.2.0" {
+		t.Errorf("expected the latest release of each channel, got %+v", releases)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestGetLatestRelease_StopsPaging(t *testing.T) {
+	pages := map[string]string{
+		"page=1": `[{"tag_name": "v1.3.0-beta.1", "prerelease": true}, {"tag_name": "v1.1.0"}]`,
+		"page=2": `[{"tag_name": "v1.2.0"}, {"tag_name": "v1.0.0"}]`,
+		"page=3": `[{"tag_name": "v0.9.0"}]`,
+	}
+	var fetched []string
+	mockClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		page := req.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		fetched = append(fetched, page)
+		header := http.Header{"Content-Type": []string{"application/json"}}
+		if page != "3" {
+			next := fmt.Sprintf("https://api.github.com/repositories/123/releases?per_page=100&page=%c", page[0]+1)
+			header.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next))
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewBufferString(pages["page="+page]))}, nil
+	})}
+
+	oldClient := NewAuthenticatedClient
+	originalVersion := Version
+	defer func() {
+		NewAuthenticatedClient = oldClient
+		Version = originalVersion
+	}()
+	NewAuthenticatedClient = func(ctx context.Context) *http.Client {
+		return mockClient
+	}
+
+	testCases := []struct {
+		name          string
+		channel       string
+		version       string
+		expectRelease string
+		expectPages   string
+	}{
+		{name: "newer release on the first page", channel: "beta", version: "1.1.0", expectRelease: "v1.3.0-beta.1", expectPages: "1"},
+		{name: "newer release on a later page", channel: "stable", version: "1.1.0", expectRelease: "v1.2.0", expectPages: "1,2"},
+		{name: "up to date", channel: "stable", version: "1.2.0", expectRelease: "v1.2.0", expectPages: "1,2,3"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fetched = nil
+			Version = tc.version
+			release, err := (&githubClient{}).GetLatestRelease(context.Background(), "owner", "repo", tc.channel)
+			if err != nil {
+				t.Fatalf("GetLatestRelease failed: %v", err)
+			}
+			if release == nil || release.TagName != tc.expectRelease {
+				t.Errorf("expected %s, got %+v", tc.expectRelease, release)
+			}
+			if got := strings.Join(fetched, ","); got != tc.expectPages {
+				t.Errorf("expected pages %s to be fetched, got %s", tc.expectPages, got)
+			}
+		})
+	}
+}
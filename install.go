@@ -0,0 +1,305 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Package managers reported by ManagedInstallError.
+const (
+	PackageManagerHomebrew = "homebrew"
+	PackageManagerDpkg     = "dpkg"
+	PackageManagerRPM      = "rpm"
+	// PackageManagerSystem is reported for binaries in system directories such
+	// as /usr/bin whose owning package could not be determined.
+	PackageManagerSystem = "system"
+)
+
+// ErrManagedInstall is matched by errors.Is for every ManagedInstallError.
+var ErrManagedInstall = errors.New("installation is managed by a package manager")
+
+// ErrNotWritable is matched by errors.Is for every NotWritableError.
+var ErrNotWritable = errors.New("installation is not writable")
+
+// ManagedInstallError is returned instead of applying an update when the
+// binary was installed by a package manager. Replacing it would break the
+// package manager's bookkeeping, so the update should be installed with
+// UpgradeCommand instead.
+type ManagedInstallError struct {
+	// Path is the binary that would have been updated.
+	Path string
+	// Manager is the package manager owning the binary, e.g.
+	// PackageManagerHomebrew.
+	Manager string
+	// Package is the name of the package owning the binary, if known.
+	Package string
+	// UpgradeCommand is the suggested command to upgrade the package, if known.
+	UpgradeCommand string
+}
+
+func (e *ManagedInstallError) Error() string {
+	if e.Manager == PackageManagerSystem {
+		return fmt.Sprintf("%s is in a system directory; upgrade it with the system package manager", e.Path)
+	}
+	msg := fmt.Sprintf("%s is managed by %s", e.Path, e.Manager)
+	if e.Package != "" {
+		msg = fmt.Sprintf("%s is managed by %s package %s", e.Path, e.Manager, e.Package)
+	}
+	if e.UpgradeCommand != "" {
+		msg += "; upgrade with: " + e.UpgradeCommand
+	}
+	return msg
+}
+
+// Is reports whether target is ErrManagedInstall.
+func (e *ManagedInstallError) Is(target error) bool {
+	return target == ErrManagedInstall
+}
+
+// NotWritableError is returned instead of downloading an update when the
+// binary or its directory cannot be written by the current user, such as a
+// binary in a read-only file system or one owned by root.
+type NotWritableError struct {
+	// Path is the binary that would have been updated.
+	Path string
+	// UpgradeCommand is the suggested command to run the update with elevated
+	// privileges, if any.
+	UpgradeCommand string
+	// Err is the reason the binary is not writable.
+	Err error
+}
+
+func (e *NotWritableError) Error() string {
+	msg := fmt.Sprintf("%s is not writable: %v", e.Path, e.Err)
+	if e.UpgradeCommand != "" {
+		msg += "; try: " + e.UpgradeCommand
+	}
+	return msg
+}
+
+// Is reports whether target is ErrNotWritable.
+func (e *NotWritableError) Is(target error) bool {
+	return target == ErrNotWritable
+}
+
+// Unwrap returns the reason the binary is not writable.
+func (e *NotWritableError) Unwrap() error {
+	return e.Err
+}
+
+// systemBinaryDirs are directories whose binaries belong to the operating
+// system and are never replaced in place.
+var systemBinaryDirs = []string{"/bin", "/sbin", "/usr/bin", "/usr/sbin", "/usr/libexec"}
+
+// packagePrefixes are the directories below which Linux package managers
+// install binaries. The package databases are only queried for binaries below
+// them.
+var packagePrefixes = []string{"/bin", "/sbin", "/lib", "/opt", "/usr"}
+
+// dpkgPackageOwner returns the dpkg package owning path, or an empty string if
+// no package owns it. This can be replaced in tests.
+var dpkgPackageOwner = func(path string) (string, error) {
+	if _, err := exec.LookPath("dpkg-query"); err != nil {
+		return "", nil
+	}
+	out, err := exec.Command("dpkg-query", "-S", path).Output()
+	if err != nil {
+		// dpkg-query exits with an error for files not owned by any package.
+		return "", nil
+	}
+	return parseDpkgQuery(string(out)), nil
+}
+
+// rpmDatabaseDir is the location of the local rpm database.
+var rpmDatabaseDir = "/var/lib/rpm"
+
+// rpmPackageOwner returns the rpm package owning path, or an empty string if
+// no package owns it. This can be replaced in tests.
+var rpmPackageOwner = func(path string) (string, error) {
+	if _, err := os.Stat(rpmDatabaseDir); err != nil {
+		return "", nil
+	}
+	if _, err := exec.LookPath("rpm"); err != nil {
+		return "", nil
+	}
+	out, err := exec.Command("rpm", "-qf", "--queryformat", "%{NAME}", path).Output()
+	if err != nil {
+		// rpm exits with an error for files not owned by any package.
+		return "", nil
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CheckInstallation is a variable that holds the function to verify that the
+// binary at target can be replaced in place, or the running executable if
+// target is empty. It returns a *ManagedInstallError if a package manager owns
+// the binary, or a *NotWritableError if the binary or its directory cannot be
+// written. Updates are not downloaded or applied when it fails. This can be
+// replaced in tests, or to allow updating managed installations.
+var CheckInstallation = func(target string) error {
+	path := target
+	if path == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate executable: %w", err)
+		}
+		path = exe
+	}
+
+	if err := detectPackageManager(path); err != nil {
+		return err
+	}
+	if err := checkWritable(path); err != nil {
+		return &NotWritableError{Path: path, UpgradeCommand: elevatedCommand(), Err: err}
+	}
+	return nil
+}
+
+// detectPackageManager returns a *ManagedInstallError if the binary at path,
+// or the file it links to, was installed by a package manager.
+func detectPackageManager(path string) error {
+	paths := []string{filepath.ToSlash(path)}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved != path {
+		paths = append(paths, filepath.ToSlash(resolved))
+	}
+
+	for _, p := range paths {
+		if formula, cask := homebrewPackage(p); formula != "" {
+			command := "brew upgrade " + formula
+			if cask {
+				command = "brew upgrade --cask " + formula
+			}
+			return &ManagedInstallError{Path: path, Manager: PackageManagerHomebrew, Package: formula, UpgradeCommand: command}
+		}
+	}
+
+	if runtime.GOOS == "linux" {
+		var packaged []string
+		for _, p := range paths {
+			if isPackagePath(p) {
+				packaged = append(packaged, p)
+			}
+		}
+		for _, p := range dpkgPaths(packaged) {
+			pkg, err := dpkgPackageOwner(p)
+			if err != nil {
+				return err
+			}
+			if pkg != "" {
+				return &ManagedInstallError{Path: path, Manager: PackageManagerDpkg, Package: pkg,
+					UpgradeCommand: "sudo apt-get install --only-upgrade " + pkg}
+			}
+		}
+		for _, p := range packaged {
+			pkg, err := rpmPackageOwner(p)
+			if err != nil {
+				return err
+			}
+			if pkg != "" {
+				return &ManagedInstallError{Path: path, Manager: PackageManagerRPM, Package: pkg,
+					UpgradeCommand: "sudo dnf upgrade " + pkg}
+			}
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		for _, p := range paths {
+			for _, dir := range systemBinaryDirs {
+				if filepath.ToSlash(filepath.Dir(p)) == dir {
+					return &ManagedInstallError{Path: path, Manager: PackageManagerSystem}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// homebrewPackage returns the formula or cask a path in a Homebrew Cellar or
+// Caskroom belongs to, e.g. "mytool" for
+// /opt/homebrew/Cellar/mytool/1.2.3/bin/mytool.
+func homebrewPackage(path string) (name string, cask bool) {
+	parts := strings.Split(path, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		switch parts[i] {
+		case "Cellar":
+			return parts[i+1], false
+		case "Caskroom":
+			return parts[i+1], true
+		}
+	}
+	return "", false
+}
+
+// dpkgPaths returns paths followed by the paths in /usr without the prefix, as
+// packages predating the merged /usr list their binaries in /bin and /sbin.
+func dpkgPaths(paths []string) []string {
+	result := append([]string(nil), paths...)
+	for _, p := range paths {
+		if rest, ok := strings.CutPrefix(p, "/usr/"); ok {
+			result = append(result, "/"+rest)
+		}
+	}
+	return result
+}
+
+// parseDpkgQuery returns the package in the output of dpkg-query -S, e.g.
+// "mytool" for "mytool:amd64: /usr/bin/mytool". Diversions are skipped, and
+// the first package is returned for a path shared by several packages.
+func parseDpkgQuery(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "diversion ") {
+			continue
+		}
+		packages, _, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		pkg, _, _ := strings.Cut(packages, ", ")
+		pkg, _, _ = strings.Cut(pkg, ":") // drop the architecture, e.g. "mytool:amd64"
+		return strings.TrimSpace(pkg)
+	}
+	return ""
+}
+
+// isPackagePath reports whether path is below one of packagePrefixes.
+func isPackagePath(path string) bool {
+	for _, prefix := range packagePrefixes {
+		if strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWritable returns an error if the binary at path cannot be replaced by
+// the current user. Replacing a binary renames it within its directory, so
+// both the directory and the binary, if it exists, must be writable. This can
+// be replaced in tests.
+var checkWritable = func(path string) error {
+	probe, err := os.CreateTemp(filepath.Dir(path), ".updater-probe-")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return canWriteFile(path)
+}
+
+// elevatedCommand suggests re-running the current command with sudo, or
+// returns an empty string on platforms without sudo.
+func elevatedCommand() string {
+	if runtime.GOOS == "windows" || len(os.Args) == 0 {
+		return ""
+	}
+	return "sudo " + strings.Join(os.Args, " ")
+}
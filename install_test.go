@@ -0,0 +1,265 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestHomebrewPackage(t *testing.T) {
+	testCases := []struct {
+		path         string
+		expectedName string
+		expectedCask bool
+	}{
+		{"/opt/homebrew/Cellar/mytool/1.2.3/bin/mytool", "mytool", false},
+		{"/home/linuxbrew/.linuxbrew/Cellar/mytool/1.2.3/bin/mytool", "mytool", false},
+		{"/opt/homebrew/Caskroom/mytool/1.2.3/mytool", "mytool", true},
+		{"/usr/local/bin/mytool", "", false},
+		{"/opt/Cellar", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			name, cask := homebrewPackage(tc.path)
+			if name != tc.expectedName || cask != tc.expectedCask {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tc.expectedName, tc.expectedCask, name, cask)
+			}
+		})
+	}
+}
+
+func TestDetectPackageManager(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("package databases are only consulted on Linux")
+	}
+
+	originalDpkgPackageOwner := dpkgPackageOwner
+	originalRPMPackageOwner := rpmPackageOwner
+	defer func() {
+		dpkgPackageOwner = originalDpkgPackageOwner
+		rpmPackageOwner = originalRPMPackageOwner
+	}()
+
+	var queried []string
+	dpkgPackageOwner = func(path string) (string, error) {
+		queried = append(queried, path)
+		if path == "/usr/bin/mytool" || path == "/bin/legacytool" {
+			return "mytool", nil
+		}
+		return "", nil
+	}
+	rpmPackageOwner = func(path string) (string, error) {
+		queried = append(queried, path)
+		if path == "/opt/rpmtool/bin/rpmtool" {
+			return "rpmtool", nil
+		}
+		return "", nil
+	}
+
+	testCases := []struct {
+		path            string
+		expectedManager string
+		expectedPackage string
+		expectedCommand string
+	}{
+		{"/usr/bin/mytool", PackageManagerDpkg, "mytool", "sudo apt-get install --only-upgrade mytool"},
+		{"/usr/bin/legacytool", PackageManagerDpkg, "mytool", "sudo apt-get install --only-upgrade mytool"},
+		{"/opt/rpmtool/bin/rpmtool", PackageManagerRPM, "rpmtool", "sudo dnf upgrade rpmtool"},
+		{"/usr/sbin/unknowntool", PackageManagerSystem, "", ""},
+		{"/opt/homebrew/Cellar/mytool/1.2.3/bin/mytool", PackageManagerHomebrew, "mytool", "brew upgrade mytool"},
+		{"/opt/tools/agent", "", "", ""},
+		{"/srv/tools/agent", "", "", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			queried = nil
+			err := detectPackageManager(tc.path)
+			if !isPackagePath(tc.path) && len(queried) != 0 {
+				t.Errorf("expected no package database queries outside the system prefixes, got %v", queried)
+			}
+			if tc.expectedManager == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			var managed *ManagedInstallError
+			if !errors.As(err, &managed) {
+				t.Fatalf("expected a ManagedInstallError, got %v", err)
+			}
+			if managed.Manager != tc.expectedManager || managed.Package != tc.expectedPackage || managed.UpgradeCommand != tc.expectedCommand {
+				t.Errorf("unexpected error %+v", managed)
+			}
+			if !errors.Is(err, ErrManagedInstall) {
+				t.Errorf("expected error to match ErrManagedInstall")
+			}
+		})
+	}
+}
+
+func TestParseDpkgQuery(t *testing.T) {
+	testCases := []struct {
+		output   string
+		expected string
+	}{
+		{"mytool:amd64: /usr/bin/mytool\n", "mytool"},
+		{"mytool: /usr/bin/mytool\n", "mytool"},
+		{"libfoo1:amd64, libfoo1:i386: /usr/share/doc/libfoo1\n", "libfoo1"},
+		{"diversion by dash from: /bin/sh\ndiversion by dash to: /bin/sh.distrib\ndash: /bin/sh\n", "dash"},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		if got := parseDpkgQuery(tc.output); got != tc.expected {
+			t.Errorf("parseDpkgQuery(%q): expected %q, got %q", tc.output, tc.expected, got)
+		}
+	}
+}
+
+func TestCheckInstallation(t *testing.T) {
+	t.Run("writable", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "agent")
+		if err := os.WriteFile(path, []byte("binary"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckInstallation(path); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("homebrew symlink", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges on Windows")
+		}
+		dir := t.TempDir()
+		cellar := filepath.Join(dir, "Cellar", "mytool", "1.2.3", "bin")
+		if err := os.MkdirAll(cellar, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(cellar, "mytool"), []byte("binary"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(dir, "mytool")
+		if err := os.Symlink(filepath.Join(cellar, "mytool"), link); err != nil {
+			t.Fatal(err)
+		}
+
+		var managed *ManagedInstallError
+		if err := CheckInstallation(link); !errors.As(err, &managed) || managed.UpgradeCommand != "brew upgrade mytool" {
+			t.Errorf("expected a Homebrew ManagedInstallError, got %v", err)
+		}
+	})
+
+	t.Run("read-only directory", func(t *testing.T) {
+		if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+			t.Skip("directory permissions are not enforced")
+		}
+		dir := t.TempDir()
+		path := filepath.Join(dir, "agent")
+		if err := os.WriteFile(path, []byte("binary"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(dir, 0555); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(dir, 0755)
+
+		err := CheckInstallation(path)
+		var notWritable *NotWritableError
+		if !errors.As(err, &notWritable) || notWritable.Path != path || !errors.Is(err, ErrNotWritable) {
+			t.Errorf("expected a NotWritableError, got %v", err)
+		}
+	})
+}
+
+func TestCheckForUpdatesHTTP_ManagedInstall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest.json" {
+			fmt.Fprintln(w, `{"version": "1.1.0", "url": "http://example.com/update"}`)
+		}
+	}))
+	defer server.Close()
+
+	originalDoUpdate := DoUpdate
+	originalCheckInstallation := CheckInstallation
+	originalVersion := Version
+	defer func() {
+		DoUpdate = originalDoUpdate
+		CheckInstallation = originalCheckInstallation
+		Version = originalVersion
+	}()
+
+	doUpdateCalled := false
+	DoUpdate = func(url string) error {
+		doUpdateCalled = true
+		return nil
+	}
+	CheckInstallation = func(target string) error {
+		return &ManagedInstallError{Path: "/usr/bin/mytool", Manager: PackageManagerDpkg, Package: "mytool",
+			UpgradeCommand: "sudo apt-get install --only-upgrade mytool"}
+	}
+	Version = "1.0.0"
+
+	err := CheckForUpdatesHTTP(server.URL)
+	if !errors.Is(err, ErrManagedInstall) {
+		t.Errorf("expected ErrManagedInstall, got %v", err)
+	}
+	if doUpdateCalled {
+		t.Errorf("expected DoUpdate not to be called for a managed installation")
+	}
+}
+
+func TestUpdateService_ManagedInstall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest.json" {
+			fmt.Fprintln(w, `{"version": "1.1.0", "url": "http://example.com/update"}`)
+		}
+	}))
+	defer server.Close()
+
+	originalDownloadUpdate := DownloadUpdate
+	originalCheckInstallation := CheckInstallation
+	originalDetectVersion := DetectVersion
+	defer func() {
+		DownloadUpdate = originalDownloadUpdate
+		CheckInstallation = originalCheckInstallation
+		DetectVersion = originalDetectVersion
+	}()
+
+	DownloadUpdate = func(url, path string) error {
+		t.Errorf("expected nothing to be downloaded for a managed installation")
+		return nil
+	}
+	var checked string
+	CheckInstallation = func(target string) error {
+		checked = target
+		return &ManagedInstallError{Path: target, Manager: PackageManagerHomebrew, Package: "agent", UpgradeCommand: "brew upgrade agent"}
+	}
+	DetectVersion = func(path string) (string, error) { return "1.0.0", nil }
+
+	target := filepath.Join(t.TempDir(), "agent")
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        server.URL,
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		TargetPath:     target,
+		StagingDir:     t.TempDir(),
+		StatePath:      filepath.Join(t.TempDir(), "state.json"),
+		LockPath:       filepath.Join(t.TempDir(), "update.lock"),
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	var managed *ManagedInstallError
+	if err := service.Start(); !errors.As(err, &managed) || managed.UpgradeCommand != "brew upgrade agent" {
+		t.Errorf("expected a ManagedInstallError, got %v", err)
+	}
+	if checked != target {
+		t.Errorf("expected the target %s to be checked, got %q", target, checked)
+	}
+}
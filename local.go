@@ -0,0 +1,149 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// sourceClient fetches update manifests and assets from remote update
+// sources over http and https. Redirects are only followed to http and https
+// URLs. The oci:// URLs of an OCI repository are fetched by the client of its
+// ociSource, which holds the registry's credentials.
+var sourceClient = &http.Client{
+	Transport:     http.DefaultTransport.(*http.Transport).Clone(),
+	CheckRedirect: checkSourceRedirect,
+}
+
+// localClient reads file:// URLs from the local filesystem, so that a release
+// directory on a mounted drive can serve as the RepoURL of an offline
+// installation. It is kept apart from sourceClient, so that a remote manifest
+// or redirect can never make the updater read local files.
+var localClient = &http.Client{Transport: fileTransport{}}
+
+// checkSourceRedirect refuses redirects to any scheme other than http and
+// https, and otherwise applies the default limit of 10 redirects.
+func checkSourceRedirect(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("refusing redirect to %s URL %s", req.URL.Scheme, req.URL.Redacted())
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// clientFor returns the client that fetches rawURL: localClient for file://
+// URLs and sourceClient for everything else.
+func clientFor(rawURL string) *http.Client {
+	if isFileURL(rawURL) {
+		return localClient
+	}
+	return sourceClient
+}
+
+// isFileURL reports whether rawURL is a file:// URL.
+func isFileURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && strings.EqualFold(u.Scheme, "file")
+}
+
+// checkSourceURL refuses a file:// URL obtained from source unless source is a
+// file:// URL itself, so that only local release directories refer to local
+// files.
+func checkSourceURL(source, rawURL string) error {
+	if isFileURL(rawURL) && !isFileURL(source) {
+		return fmt.Errorf("refusing local file URL %s from remote source %s", rawURL, source)
+	}
+	return nil
+}
+
+// fileTransport serves GET and HEAD requests for file:// URLs from the local
+// filesystem, answering like a static HTTP server.
+type fileTransport struct{}
+
+func (fileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, err := fileURLPath(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	response := func(code int, body io.ReadCloser, size int64) *http.Response {
+		resp := &http.Response{
+			Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+			StatusCode:    code,
+			Proto:         "HTTP/1.0",
+			ProtoMajor:    1,
+			Header:        make(http.Header),
+			Body:          body,
+			ContentLength: size,
+			Request:       req,
+		}
+		if size >= 0 {
+			resp.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		return resp
+	}
+	empty := io.NopCloser(strings.NewReader(""))
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return response(http.StatusMethodNotAllowed, empty, 0), nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return response(http.StatusNotFound, empty, 0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return response(http.StatusNotFound, empty, 0), nil
+	}
+	if req.Method == http.MethodHead {
+		f.Close()
+		return response(http.StatusOK, empty, info.Size()), nil
+	}
+	return response(http.StatusOK, f, info.Size()), nil
+}
+
+// fileURLPath returns the local path of a file:// URL. Only local files are
+// supported; a host other than "localhost" is refused.
+func fileURLPath(u *url.URL) (string, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("unsupported file URL %s: only local files can be read", u)
+	}
+	path := u.Path
+	// file:///C:/updates is the path C:/updates on Windows
+	if runtime.GOOS == "windows" && len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// FileURL returns the file:// URL of a local path, which can be used as the
+// RepoURL of a release directory holding latest.json and the release assets.
+// Relative paths are made absolute.
+func FileURL(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	slashed := filepath.ToSlash(abs)
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String(), nil
+}
@@ -0,0 +1,178 @@
+package updater
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileURL(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "release dir")
+	u, err := FileURL(dir)
+	if err != nil {
+		t.Fatalf("FileURL failed: %v", err)
+	}
+	if !strings.HasPrefix(u, "file:///") || !strings.HasSuffix(u, "/release%20dir") {
+		t.Errorf("unexpected file URL %s", u)
+	}
+}
+
+func TestLocalClient(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "latest.json"), []byte(`{"version": "1.3.0"}`), 0644); err != nil {
+		t.Fatalf("failed to write latest.json: %v", err)
+	}
+	base, err := FileURL(dir)
+	if err != nil {
+		t.Fatalf("FileURL failed: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		method       string
+		url          string
+		expectStatus int
+		expectBody   string
+		expectErr    bool
+	}{
+		{name: "get", method: http.MethodGet, url: base + "/latest.json", expectStatus: http.StatusOK, expectBody: `{"version": "1.3.0"}`},
+		{name: "head", method: http.MethodHead, url: base + "/latest.json", expectStatus: http.StatusOK},
+		{name: "missing file", method: http.MethodGet, url: base + "/blocked.json", expectStatus: http.StatusNotFound},
+		{name: "directory", method: http.MethodGet, url: base, expectStatus: http.StatusNotFound},
+		{name: "post", method: http.MethodPost, url: base + "/latest.json", expectStatus: http.StatusMethodNotAllowed},
+		{name: "remote host", method: http.MethodGet, url: "file://server/share/latest.json", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.url, nil)
+			if err != nil {
+				t.Fatalf("NewRequest failed: %v", err)
+			}
+			resp, err := localClient.Do(req)
+			if tc.expectErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.expectStatus {
+				t.Errorf("expected status %d, got %d", tc.expectStatus, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tc.expectBody {
+				t.Errorf("expected body %q, got %q", tc.expectBody, body)
+			}
+			if tc.expectStatus == http.StatusOK && resp.ContentLength != int64(len(`{"version": "1.3.0"}`)) {
+				t.Errorf("unexpected content length %d", resp.ContentLength)
+			}
+		})
+	}
+}
+
+func TestSourceClient_RefusesLocalFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	local, err := FileURL(filepath.Join(dir, "secret"))
+	if err != nil {
+		t.Fatalf("FileURL failed: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, local, http.StatusFound)
+		case "/latest.json":
+			fmt.Fprintf(w, `{"version": "1.3.0", "url": %q}`, local)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if resp, err := sourceClient.Get(local); err == nil {
+		resp.Body.Close()
+		t.Errorf("expected the source client to refuse %s", local)
+	}
+	if resp, err := sourceClient.Get(server.URL + "/redirect"); err == nil || !strings.Contains(err.Error(), "refusing redirect") {
+		if err == nil {
+			resp.Body.Close()
+		}
+		t.Errorf("expected the redirect to a file URL to be refused, got %v", err)
+	}
+	if err := DownloadUpdate(server.URL+"/redirect", filepath.Join(dir, "update")); err == nil {
+		t.Errorf("expected the download to refuse the redirect")
+	}
+	if _, err := GetLatestUpdateFromURL(server.URL); err == nil || !strings.Contains(err.Error(), "refusing local file URL") {
+		t.Errorf("expected a remote latest.json pointing at a local file to be refused, got %v", err)
+	}
+}
+
+func TestUpdateService_FileSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "latest.json"), []byte(`{"version": "1.3.0", "url": "bin/agent"}`), 0644); err != nil {
+		t.Fatalf("failed to write latest.json: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0755); err != nil {
+		t.Fatalf("failed to create bin: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bin", "agent"), []byte("new agent"), 0755); err != nil {
+		t.Fatalf("failed to write agent: %v", err)
+	}
+	source, err := FileURL(dir)
+	if err != nil {
+		t.Fatalf("FileURL failed: %v", err)
+	}
+
+	info, err := GetLatestUpdateFromURL(source)
+	if err != nil {
+		t.Fatalf("GetLatestUpdateFromURL failed: %v", err)
+	}
+	if info.URL != source+"/bin/agent" {
+		t.Errorf("expected the asset URL to be resolved against latest.json, got %s", info.URL)
+	}
+
+	originalDetectVersion := DetectVersion
+	originalApplyUpdateFile := ApplyUpdateFile
+	defer func() {
+		DetectVersion = originalDetectVersion
+		ApplyUpdateFile = originalApplyUpdateFile
+	}()
+	target := filepath.Join(t.TempDir(), "agent")
+	DetectVersion = func(path string) (string, error) { return "1.2.0", nil }
+	var applied string
+	ApplyUpdateFile = func(path, to string) error {
+		data, err := os.ReadFile(path)
+		applied = string(data)
+		return err
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        source,
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		TargetPath:     target,
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if applied != "new agent" {
+		t.Errorf("expected the agent from the release directory to be applied, got %q", applied)
+	}
+}
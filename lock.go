@@ -0,0 +1,140 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUpdateLocked is returned when another process holds the update lock.
+// Use errors.Is to detect it.
+var ErrUpdateLocked = errors.New("another update is already in progress")
+
+// DefaultLockTimeout is how long UpdateService waits for another process to
+// finish updating when the configured LockTimeout is zero.
+const DefaultLockTimeout = 30 * time.Second
+
+// lockRetryInterval is how often a held lock is polled while waiting for it.
+const lockRetryInterval = 100 * time.Millisecond
+
+// UpdateLock is an exclusive, cross-process advisory lock that guards the
+// download and replacement of a binary. It is backed by flock on Unix-like
+// systems and LockFileEx on Windows, so the lock is released by the operating
+// system if the holding process dies.
+type UpdateLock struct {
+	path string
+	file *os.File
+}
+
+// AcquireUpdateLock takes the update lock at the given path, creating the lock
+// file and its parent directory if needed.
+//
+// The timeout controls how long to wait if another process holds the lock:
+// zero fails immediately, a positive value polls until it elapses, and a
+// negative value waits indefinitely. If the lock cannot be obtained, the
+// returned error wraps ErrUpdateLocked.
+//
+// Example:
+//
+//	lock, err := updater.AcquireUpdateLock("/tmp/myapp.lock", 30*time.Second)
+//	if errors.Is(err, updater.ErrUpdateLocked) {
+//		// another instance is updating
+//	}
+//	defer lock.Release()
+func AcquireUpdateLock(path string, timeout time.Duration) (*UpdateLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		if timeout == 0 || (timeout > 0 && time.Now().After(deadline)) {
+			f.Close()
+			return nil, lockHeldError(path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	// Record the holder so that a competing process can report who it is waiting on.
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+
+	return &UpdateLock{path: path, file: f}, nil
+}
+
+// Path returns the location of the lock file.
+func (l *UpdateLock) Path() string {
+	return l.path
+}
+
+// Release unlocks and closes the lock file. It is safe to call on a nil lock.
+func (l *UpdateLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := unlockFile(l.file)
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	l.file = nil
+	return err
+}
+
+// DefaultLockPath returns the lock file used to guard updates of the binary at
+// target. Lock files live in the user cache directory, and are named after the
+// binary and a hash of its absolute path so that different installations of the
+// same tool do not contend with each other.
+func DefaultLockPath(target string) (string, error) {
+	key, err := installationKey(target)
+	if err != nil {
+		return "", err
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "updater", key+".lock"), nil
+}
+
+// installationKey identifies the installation of the binary at target by its
+// name and a hash of its absolute path, e.g. "myapp-1a2b3c4d".
+func installationKey(target string) (string, error) {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs))
+	sum := sha256.Sum256([]byte(abs))
+	return fmt.Sprintf("%s-%x", name, sum[:4]), nil
+}
+
+// lockHeldError builds the error returned when the lock is held, naming the
+// holding process if it recorded its PID.
+func lockHeldError(path string) error {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if pid := strings.TrimSpace(string(data)); pid != "" {
+			return fmt.Errorf("%w: lock %s is held by process %s", ErrUpdateLocked, path, pid)
+		}
+	}
+	return fmt.Errorf("%w: lock %s is held by another process", ErrUpdateLocked, path)
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package updater
+
+import "os"
+
+// tryLockFile is a no-op on platforms without advisory file locking; the lock
+// always succeeds so that updates are not blocked.
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlockFile is a no-op on platforms without advisory file locking.
+func unlockFile(f *os.File) error {
+	return nil
+}
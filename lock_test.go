@@ -0,0 +1,103 @@
+package updater
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireUpdateLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "app.lock")
+
+	lock, err := AcquireUpdateLock(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireUpdateLock failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read lock file: %v", err)
+	}
+	if string(data) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("expected lock file to contain PID %d, got %q", os.Getpid(), data)
+	}
+
+	_, err = AcquireUpdateLock(path, 0)
+	if !errors.Is(err, ErrUpdateLocked) {
+		t.Fatalf("expected ErrUpdateLocked, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Errorf("expected error to name the holding process, got: %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+
+	lock, err = AcquireUpdateLock(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireUpdateLock after release failed: %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireUpdateLock_Timeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+
+	held, err := AcquireUpdateLock(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireUpdateLock failed: %v", err)
+	}
+
+	start := time.Now()
+	_, err = AcquireUpdateLock(path, 250*time.Millisecond)
+	if !errors.Is(err, ErrUpdateLocked) {
+		t.Fatalf("expected ErrUpdateLocked, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected to wait for the timeout, returned after %v", elapsed)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		held.Release()
+	}()
+
+	lock, err := AcquireUpdateLock(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected to acquire lock once released, got: %v", err)
+	}
+	lock.Release()
+}
+
+func TestUpdateLock_ReleaseNil(t *testing.T) {
+	var lock *UpdateLock
+	if err := lock.Release(); err != nil {
+		t.Errorf("expected nil lock release to be a no-op, got: %v", err)
+	}
+}
+
+func TestDefaultLockPath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	a, err := DefaultLockPath("/opt/one/myapp")
+	if err != nil {
+		t.Fatalf("DefaultLockPath failed: %v", err)
+	}
+	b, err := DefaultLockPath("/opt/two/myapp")
+	if err != nil {
+		t.Fatalf("DefaultLockPath failed: %v", err)
+	}
+
+	if a == b {
+		t.Errorf("expected different lock paths for different installations, got %s", a)
+	}
+	if !strings.HasPrefix(filepath.Base(a), "myapp-") || filepath.Ext(a) != ".lock" {
+		t.Errorf("unexpected lock file name: %s", a)
+	}
+}
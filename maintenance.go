@@ -0,0 +1,326 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoPendingUpdate is returned when asked to apply a deferred update while
+// none has been downloaded.
+var ErrNoPendingUpdate = errors.New("no pending update")
+
+// pendingPollInterval is how often ApplyWhenAllowed checks whether a deferred
+// update may be applied.
+var pendingPollInterval = time.Minute
+
+// timeNow returns the current time. This can be replaced in tests.
+var timeNow = time.Now
+
+// MaintenanceWindow is a recurring period of the week during which updates may
+// be applied, such as weekdays from 02:00 to 04:00 local time.
+type MaintenanceWindow struct {
+	// Days are the weekdays on which the window opens. If empty, the window
+	// opens every day.
+	Days []time.Weekday
+	// Start is the time of day the window opens, as an offset from midnight.
+	Start time.Duration
+	// End is the time of day the window closes. If End is not after Start,
+	// the window extends past midnight into the next day.
+	End time.Duration
+	// Location is the time zone of Start and End. If nil, local time is used.
+	Location *time.Location
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseMaintenanceWindow parses a window such as "Mon-Fri 02:00-04:00",
+// "Sat,Sun 22:00-06:00" or "03:00-05:00" (every day), in local time.
+func ParseMaintenanceWindow(expr string) (MaintenanceWindow, error) {
+	var window MaintenanceWindow
+
+	fields := strings.Fields(expr)
+	if len(fields) == 0 || len(fields) > 2 {
+		return window, fmt.Errorf("invalid maintenance window %q: expected [days] HH:MM-HH:MM", expr)
+	}
+	if len(fields) == 2 {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return window, fmt.Errorf("invalid maintenance window %q: %w", expr, err)
+		}
+		window.Days = days
+	}
+
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return window, fmt.Errorf("invalid maintenance window %q: expected HH:MM-HH:MM", expr)
+	}
+	var err error
+	if window.Start, err = parseTimeOfDay(start); err != nil {
+		return window, fmt.Errorf("invalid maintenance window %q: %w", expr, err)
+	}
+	if window.End, err = parseTimeOfDay(end); err != nil {
+		return window, fmt.Errorf("invalid maintenance window %q: %w", expr, err)
+	}
+	return window, nil
+}
+
+// parseWeekdays parses a comma-separated list of weekdays and ranges, such as
+// "Mon-Fri" or "Sat,Sun".
+func parseWeekdays(expr string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(expr, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdayNames[strings.ToLower(from)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayNames[strings.ToLower(to)]; !ok {
+				return nil, fmt.Errorf("unknown weekday %q", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseTimeOfDay parses "HH:MM" as an offset from midnight.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls within the window.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	if w.Location != nil {
+		t = t.In(w.Location)
+	}
+	// The wall-clock time of day, which differs from the time elapsed since
+	// midnight on the days daylight saving time starts or ends.
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+
+	if w.End > w.Start {
+		return offset >= w.Start && offset < w.End && w.opensOn(t.Weekday())
+	}
+	// The window extends past midnight: it is open late on a day it opens, or
+	// early on the day after.
+	if offset >= w.Start && w.opensOn(t.Weekday()) {
+		return true
+	}
+	return offset < w.End && w.opensOn((t.Weekday()+6)%7)
+}
+
+// opensOn reports whether the window opens on the given weekday.
+func (w MaintenanceWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// PendingUpdate describes an update that has been downloaded but not yet
+// applied, because no maintenance window was open and the application was not
+// idle.
+type PendingUpdate struct {
+	// Version is the version of the downloaded update.
+	Version string
+	// Path is the location of the downloaded binary.
+	Path string
+	// DownloadedAt is when the update finished downloading.
+	DownloadedAt time.Time
+}
+
+// defersApply reports whether updates are only applied during maintenance
+// windows or while the application is idle.
+func (s *UpdateService) defersApply() bool {
+	return len(s.config.MaintenanceWindows) > 0 || s.config.IsIdle != nil
+}
+
+// CanApplyNow reports whether an update may be applied right now: no
+// maintenance windows or idle check are configured, a window is open, or the
+// application reports that it is idle.
+func (s *UpdateService) CanApplyNow() bool {
+	if !s.defersApply() {
+		return true
+	}
+	now := timeNow()
+	for _, window := range s.config.MaintenanceWindows {
+		if window.Contains(now) {
+			return true
+		}
+	}
+	return s.config.IsIdle != nil && s.config.IsIdle()
+}
+
+// PendingUpdate returns the update waiting to be applied, or nil if there is
+// none.
+func (s *UpdateService) PendingUpdate() *PendingUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		return nil
+	}
+	pending := *s.pending
+	return &pending
+}
+
+// ApplyPendingUpdate applies the pending update immediately, overriding the
+// maintenance windows. It returns ErrNoPendingUpdate if there is none. Like an
+// update applied by a check, an update of the running executable stops the
+// periodic checks.
+func (s *UpdateService) ApplyPendingUpdate() error {
+	lock, err := s.acquireLock()
+	if err != nil {
+		return err
+	}
+	err = s.applyPending()
+	lock.Release()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	checking := s.stop != nil
+	s.mu.Unlock()
+	if s.config.TargetPath == "" && checking {
+		fmt.Fprintln(s.out(), "No further update checks until the application is restarted.")
+		s.Stop()
+	}
+	return nil
+}
+
+// DiscardPendingUpdate removes the pending update without applying it.
+func (s *UpdateService) DiscardPendingUpdate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		return nil
+	}
+	err := os.RemoveAll(filepath.Dir(s.pending.Path))
+	s.pending = nil
+	return err
+}
+
+// ApplyWhenAllowed waits until the pending update may be applied, then applies
+// it. It returns immediately if there is no pending update, and returns the
+// context's error if ctx is done first.
+//
+// Example:
+//
+//	go func() {
+//		if err := service.ApplyWhenAllowed(ctx); err != nil && !errors.Is(err, context.Canceled) {
+//			log.Printf("deferred update failed: %v", err)
+//		}
+//	}()
+func (s *UpdateService) ApplyWhenAllowed(ctx context.Context) error {
+	ticker := time.NewTicker(pendingPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.PendingUpdate() == nil {
+			return nil
+		}
+		if s.CanApplyNow() {
+			err := s.ApplyPendingUpdate()
+			if errors.Is(err, ErrNoPendingUpdate) {
+				return nil
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// deferUpdate downloads the update from current to version from url, verifies
+// it against sum and records it as pending, replacing any previously pending
+// update. A version that is already pending is not downloaded again. The
+// update is applied straight away if allowed. The caller must hold the update
+// lock.
+func (s *UpdateService) deferUpdate(version, current, url string, sum assetChecksum) error {
+	display := formatVersionForDisplay(version, s.config.ForceSemVerPrefix)
+	if pending := s.PendingUpdate(); pending != nil && canonicalVersion(pending.Version) == canonicalVersion(version) {
+		if !s.CanApplyNow() {
+			fmt.Fprintf(s.out(), "Update %s is already downloaded; it will be applied during the next maintenance window.\n", display)
+			s.record(UpdateDeferred, version, current)
+			return nil
+		}
+	} else {
+		s.announce(version, current, "Downloading...")
+
+		dir, err := os.MkdirTemp("", "updater-pending-")
+		if err != nil {
+			return fmt.Errorf("failed to create download directory: %w", err)
+		}
+		path := filepath.Join(dir, "update")
+		if err := downloadVerified(s.downloader(), url, path, sum, s.binaryName()); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+
+		if err := s.DiscardPendingUpdate(); err != nil {
+			fmt.Fprintf(s.out(), "failed to remove previous pending update: %v\n", err)
+		}
+		s.mu.Lock()
+		s.pending = &PendingUpdate{Version: version, Path: path, DownloadedAt: timeNow()}
+		s.mu.Unlock()
+
+		if !s.CanApplyNow() {
+			fmt.Fprintf(s.out(), "Update %s downloaded; it will be applied during the next maintenance window.\n", display)
+			s.record(UpdateDeferred, version, current)
+			return nil
+		}
+	}
+	if err := s.applyPending(); err != nil {
+		return err
+	}
+	s.record(UpdateApplied, version, current)
+	return nil
+}
+
+// applyPending applies and clears the pending update. An update of the running
+// executable is recorded as its current version from then on. The caller must
+// hold the update lock.
+func (s *UpdateService) applyPending() error {
+	pending := s.PendingUpdate()
+	if pending == nil {
+		return ErrNoPendingUpdate
+	}
+
+	fmt.Fprintf(s.out(), "Applying update %s...\n", formatVersionForDisplay(pending.Version, s.config.ForceSemVerPrefix))
+	if err := s.applyFile(pending.Path); err != nil {
+		return err
+	}
+	if s.config.TargetPath == "" {
+		s.mu.Lock()
+		s.replaced = pending.Version
+		s.mu.Unlock()
+	}
+	return s.DiscardPendingUpdate()
+}
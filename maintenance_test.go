@@ -0,0 +1,321 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	testCases := []struct {
+		expr        string
+		days        []time.Weekday
+		start, end  time.Duration
+		expectError bool
+	}{
+		{expr: "03:00-05:00", start: 3 * time.Hour, end: 5 * time.Hour},
+		{expr: "Mon-Fri 02:00-04:30", days: []time.Weekday{1, 2, 3, 4, 5}, start: 2 * time.Hour, end: 4*time.Hour + 30*time.Minute},
+		{expr: "sat,Sun 22:00-06:00", days: []time.Weekday{6, 0}, start: 22 * time.Hour, end: 6 * time.Hour},
+		{expr: "Fri-Mon 01:00-02:00", days: []time.Weekday{5, 6, 0, 1}, start: time.Hour, end: 2 * time.Hour},
+		{expr: "", expectError: true},
+		{expr: "Mon-Fri", expectError: true},
+		{expr: "Someday 02:00-04:00", expectError: true},
+		{expr: "02:00-25:00", expectError: true},
+		{expr: "Mon 02:00-04:00 extra", expectError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			window, err := ParseMaintenanceWindow(tc.expr)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error %v, got %v", tc.expectError, err)
+			}
+			if tc.expectError {
+				return
+			}
+			if fmt.Sprint(window.Days) != fmt.Sprint(tc.days) || window.Start != tc.start || window.End != tc.end {
+				t.Errorf("unexpected window %+v", window)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	weekdays, _ := ParseMaintenanceWindow("Mon-Fri 02:00-04:00")
+	overnight, _ := ParseMaintenanceWindow("Fri 22:00-02:00")
+
+	// 2026-10-19 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local)
+	}
+	testCases := []struct {
+		name     string
+		window   MaintenanceWindow
+		t        time.Time
+		expected bool
+	}{
+		{"weekday inside", weekdays, at(19, 3, 15), true},
+		{"weekday start", weekdays, at(19, 2, 0), true},
+		{"weekday end", weekdays, at(19, 4, 0), false},
+		{"weekday before", weekdays, at(19, 1, 59), false},
+		{"weekend", weekdays, at(24, 3, 0), false},
+		{"overnight evening", overnight, at(23, 23, 0), true},
+		{"overnight after midnight", overnight, at(24, 1, 30), true},
+		{"overnight wrong evening", overnight, at(24, 23, 0), false},
+		{"overnight wrong morning", overnight, at(23, 1, 30), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.window.Contains(tc.t); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindow_ContainsDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	window, _ := ParseMaintenanceWindow("04:00-05:00")
+	window.Location = berlin
+
+	// Daylight saving time starts on 2026-03-29 and ends on 2026-10-25 in
+	// Berlin, so 04:30 is 3.5 and 5.5 hours after midnight on those days.
+	for _, day := range []time.Time{
+		time.Date(2026, time.March, 29, 0, 0, 0, 0, berlin),
+		time.Date(2026, time.October, 25, 0, 0, 0, 0, berlin),
+	} {
+		inside := time.Date(day.Year(), day.Month(), day.Day(), 4, 30, 0, 0, berlin)
+		if !window.Contains(inside) {
+			t.Errorf("expected %s to be inside the window", inside)
+		}
+		before := time.Date(day.Year(), day.Month(), day.Day(), 3, 30, 0, 0, berlin)
+		if window.Contains(before) {
+			t.Errorf("expected %s to be outside the window", before)
+		}
+	}
+}
+
+func TestUpdateService_DeferredApply(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprintf(w, `{"version": "1.3.0", "url": "http://%s/binary"}`, r.Host)
+		case "/binary":
+			fmt.Fprint(w, "new binary")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalTimeNow := timeNow
+	originalVersion := Version
+	defer func() {
+		ApplyUpdateFile = originalApplyUpdateFile
+		timeNow = originalTimeNow
+		Version = originalVersion
+	}()
+
+	var applied []string
+	ApplyUpdateFile = func(path, target string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		applied = append(applied, string(data))
+		return nil
+	}
+	Version = "1.2.0"
+
+	window, _ := ParseMaintenanceWindow("02:00-04:00")
+	idle := false
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:            server.URL,
+		CheckOnStartup:     CheckAndUpdateOnStartup,
+		LockPath:           filepath.Join(t.TempDir(), "update.lock"),
+		MaintenanceWindows: []MaintenanceWindow{window},
+		IsIdle:             func() bool { return idle },
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	// Outside the window, the update is downloaded but not applied.
+	timeNow = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local) }
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	pending := service.PendingUpdate()
+	if pending == nil || pending.Version != "1.3.0" || len(applied) != 0 {
+		t.Fatalf("expected 1.3.0 to be pending, got %+v (applied %v)", pending, applied)
+	}
+
+	// Waiting gives up when the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := service.ApplyWhenAllowed(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to time out, got %v", err)
+	}
+
+	// Once the application is idle, the update is applied.
+	idle = true
+	if err := service.ApplyWhenAllowed(context.Background()); err != nil {
+		t.Fatalf("ApplyWhenAllowed failed: %v", err)
+	}
+	if len(applied) != 1 || applied[0] != "new binary" {
+		t.Errorf("expected the downloaded binary to be applied, got %v", applied)
+	}
+	if service.PendingUpdate() != nil {
+		t.Errorf("expected no pending update after applying")
+	}
+	if _, err := os.Stat(pending.Path); !os.IsNotExist(err) {
+		t.Errorf("expected the download to be removed, got %v", err)
+	}
+	if err := service.ApplyPendingUpdate(); !errors.Is(err, ErrNoPendingUpdate) {
+		t.Errorf("expected ErrNoPendingUpdate, got %v", err)
+	}
+}
+
+func TestUpdateService_ApplyPendingUpdateOverride(t *testing.T) {
+	originalDownloadUpdate := DownloadUpdate
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalTimeNow := timeNow
+	defer func() {
+		DownloadUpdate = originalDownloadUpdate
+		ApplyUpdateFile = originalApplyUpdateFile
+		timeNow = originalTimeNow
+	}()
+
+	DownloadUpdate = func(url, path string) error {
+		return os.WriteFile(path, []byte(url), 0644)
+	}
+	var applied int
+	ApplyUpdateFile = func(path, target string) error {
+		applied++
+		return nil
+	}
+
+	window, _ := ParseMaintenanceWindow("Mon-Fri 02:00-04:00")
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:            "https://example.com/updates",
+		LockPath:           filepath.Join(t.TempDir(), "update.lock"),
+		MaintenanceWindows: []MaintenanceWindow{window},
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	// Saturday at 03:00 is outside the weekday window.
+	timeNow = func() time.Time { return time.Date(2026, 10, 24, 3, 0, 0, 0, time.Local) }
+	if service.CanApplyNow() {
+		t.Errorf("did not expect updates to be allowed on a Saturday")
+	}
+	if err := service.deferUpdate("1.3.0", Version, "https://example.com/binary", assetChecksum{}); err != nil {
+		t.Fatalf("deferUpdate failed: %v", err)
+	}
+	if err := service.deferUpdate("1.3.1", Version, "https://example.com/binary2", assetChecksum{}); err != nil {
+		t.Fatalf("deferUpdate failed: %v", err)
+	}
+	if pending := service.PendingUpdate(); pending == nil || pending.Version != "1.3.1" {
+		t.Fatalf("expected the newest download to replace the pending update, got %+v", pending)
+	}
+	if applied != 0 {
+		t.Fatalf("expected nothing to be applied outside the window")
+	}
+
+	if err := service.ApplyPendingUpdate(); err != nil {
+		t.Fatalf("ApplyPendingUpdate failed: %v", err)
+	}
+	if applied != 1 || service.PendingUpdate() != nil {
+		t.Errorf("expected the override to apply the pending update")
+	}
+}
+
+func TestUpdateService_CheckAfterPendingUpdate(t *testing.T) {
+	var downloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprintf(w, `{"version": "1.3.0", "url": "http://%s/binary"}`, r.Host)
+		case "/binary":
+			downloads++
+			fmt.Fprint(w, "new binary")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalTimeNow := timeNow
+	originalVersion := Version
+	defer func() {
+		ApplyUpdateFile = originalApplyUpdateFile
+		timeNow = originalTimeNow
+		Version = originalVersion
+	}()
+	var applied int
+	ApplyUpdateFile = func(path, target string) error {
+		applied++
+		return nil
+	}
+	Version = "1.2.0"
+
+	window, _ := ParseMaintenanceWindow("02:00-04:00")
+	var out strings.Builder
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:            server.URL,
+		CheckOnStartup:     CheckAndUpdateOnStartup,
+		CheckInterval:      time.Hour,
+		LockPath:           filepath.Join(t.TempDir(), "update.lock"),
+		MaintenanceWindows: []MaintenanceWindow{window},
+		Output:             &out,
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	defer service.Stop()
+
+	// Checks outside the window download the update once.
+	timeNow = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local) }
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	result, err := service.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.Action != UpdateDeferred || downloads != 1 {
+		t.Fatalf("expected the pending 1.3.0 to be kept, got %+v after %d downloads", result, downloads)
+	}
+
+	if err := service.ApplyPendingUpdate(); err != nil {
+		t.Fatalf("ApplyPendingUpdate failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No further update checks") {
+		t.Errorf("expected the periodic checks to stop, got %q", out.String())
+	}
+	if current, _ := service.CurrentVersion(); current != "1.3.0" {
+		t.Errorf("expected 1.3.0 to be the current version, got %s", current)
+	}
+
+	// Inside the window, a check finds nothing left to apply.
+	timeNow = func() time.Time { return time.Date(2026, 10, 20, 3, 0, 0, 0, time.Local) }
+	result, err = service.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.Action != UpdateNone || downloads != 1 || applied != 1 {
+		t.Errorf("expected 1.3.0 to be applied once, got %+v after %d downloads and %d applies", result, downloads, applied)
+	}
+}
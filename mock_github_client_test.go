@@ -9,6 +9,7 @@ type MockGithubClient struct {
 	GetLatestReleaseFunc        func(ctx context.Context, owner, repo, channel string) (*Release, error)
 	GetReleaseByPullRequestFunc func(ctx context.Context, owner, repo string, prNumber int) (*Release, error)
 	GetPublicReposFunc          func(ctx context.Context, userOrOrg string) ([]string, error)
+	ListReleasesFunc            func(ctx context.Context, owner, repo string) ([]Release, error)
 }
 
 // GetLatestRelease mocks the GetLatestRelease method of the GithubClient interface.
@@ -34,3 +35,11 @@ func (m *MockGithubClient) GetPublicRepos(ctx context.Context, userOrOrg string)
 	}
 	return []string{"repo1", "repo2"}, nil
 }
+
+// ListReleases mocks the ListReleases method of the GithubClient interface.
+func (m *MockGithubClient) ListReleases(ctx context.Context, owner, repo string) ([]Release, error) {
+	if m.ListReleasesFunc != nil {
+		return m.ListReleasesFunc(ctx, owner, repo)
+	}
+	return nil, nil
+}
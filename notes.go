@@ -0,0 +1,86 @@
+package updater
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// ReleaseNotes returns the releases newer than from, up to and including to,
+// newest first, to show what changed between the two versions, e.g. "what's
+// new since 1.2.0" before an update. Only releases of channels received by the
+// channel of to are included, so the notes of a stable release do not repeat
+// those of its betas.
+func (s *UpdateService) ReleaseNotes(from, to string) ([]Release, error) {
+	releases, err := s.Releases()
+	if err != nil {
+		return nil, err
+	}
+	return ReleasesBetween(releases, from, to, s.channelRules()), nil
+}
+
+// ReleasesBetween returns the releases newer than from, up to and including
+// to, newest first. Only releases of channels that rules let the channel of to
+// receive are included. Releases without a valid semantic version are skipped.
+func ReleasesBetween(releases []Release, from, to string, rules ChannelRules) []Release {
+	vFrom, vTo := formatVersionForComparison(from), formatVersionForComparison(to)
+	channel := rules.Channel(to, false)
+
+	var between []Release
+	for _, release := range releases {
+		v := formatVersionForComparison(release.TagName)
+		if !semver.IsValid(v) || semver.Compare(v, vFrom) <= 0 || semver.Compare(v, vTo) > 0 {
+			continue
+		}
+		if !rules.Receives(channel, rules.ReleaseChannel(&release)) {
+			continue
+		}
+		between = append(between, release)
+	}
+	sort.SliceStable(between, func(i, j int) bool {
+		return semver.Compare(formatVersionForComparison(between[i].TagName), formatVersionForComparison(between[j].TagName)) > 0
+	})
+	return between
+}
+
+// FormatReleaseNotes combines the notes of releases into one Markdown document,
+// with a heading per release giving its tag, title and publish date. Updater
+// directives are removed from the notes.
+func FormatReleaseNotes(releases []Release) string {
+	var b strings.Builder
+	for _, release := range releases {
+		heading := release.TagName
+		if release.Name != "" && release.Name != release.TagName {
+			heading += " - " + release.Name
+		}
+		if !release.PublishedAt.IsZero() {
+			heading += fmt.Sprintf(" (%s)", release.PublishedAt.Format("2006-01-02"))
+		}
+		fmt.Fprintf(&b, "## %s\n\n", heading)
+		if body := release.Notes(); body != "" {
+			fmt.Fprintf(&b, "%s\n\n", body)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Notes returns the release notes of r without updater directives.
+func (r *Release) Notes() string {
+	return stripDirectives(r.Body)
+}
+
+// stripDirectives removes updater directives from a release body, both those
+// wrapped in HTML comments and those written on a line of their own.
+func stripDirectives(body string) string {
+	body = directiveCommentPattern.ReplaceAllString(body, "")
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		if isDirectiveLine(line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,132 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelease_DecodeGitHubJSON(t *testing.T) {
+	data := `{
+		"tag_name": "v1.3.0",
+		"name": "Faster downloads",
+		"prerelease": false,
+		"body": "Notes",
+		"published_at": "2025-03-01T12:00:00Z",
+		"html_url": "https://github.com/owner/repo/releases/tag/v1.3.0",
+		"author": {"login": "octocat", "html_url": "https://github.com/octocat"},
+		"assets": [{
+			"name": "agent_linux_amd64",
+			"browser_download_url": "https://github.com/owner/repo/releases/download/v1.3.0/agent_linux_amd64",
+			"size": 8388608,
+			"content_type": "application/octet-stream",
+			"digest": "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+		}]
+	}`
+
+	var release Release
+	if err := json.Unmarshal([]byte(data), &release); err != nil {
+		t.Fatalf("failed to decode release: %v", err)
+	}
+	if release.Name != "Faster downloads" || release.Author.Login != "octocat" ||
+		release.HTMLURL != "https://github.com/owner/repo/releases/tag/v1.3.0" ||
+		!release.PublishedAt.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected release %+v", release)
+	}
+	asset := release.Assets[0]
+	if asset.Size != 8388608 || asset.ContentType != "application/octet-stream" ||
+		asset.Digest != "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+		t.Errorf("unexpected asset %+v", asset)
+	}
+}
+
+func TestReleasesBetween(t *testing.T) {
+	releases := []Release{
+		{TagName: "v1.1.0"},
+		{TagName: "v1.3.0"},
+		{TagName: "v1.3.0-beta.1", PreRelease: true},
+		{TagName: "v1.2.0"},
+		{TagName: "v1.4.0"},
+		{TagName: "nightly"},
+		{TagName: "v1.2.1"},
+	}
+
+	testCases := []struct {
+		name     string
+		from, to string
+		expected string
+	}{
+		{"stable update", "1.1.0", "v1.3.0", "v1.3.0 v1.2.1 v1.2.0"},
+		{"beta update", "v1.2.1", "v1.3.0-beta.1", "v1.3.0-beta.1"},
+		{"beta receives stable", "v1.1.0", "v1.3.0-beta.1", "v1.3.0-beta.1 v1.2.1 v1.2.0"},
+		{"up to date", "v1.4.0", "v1.4.0", ""},
+		{"downgrade", "v1.4.0", "v1.2.0", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var tags []string
+			for _, release := range ReleasesBetween(releases, tc.from, tc.to, DefaultChannelRules) {
+				tags = append(tags, release.TagName)
+			}
+			if strings.Join(tags, " ") != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, strings.Join(tags, " "))
+			}
+		})
+	}
+}
+
+func TestFormatReleaseNotes(t *testing.T) {
+	releases := []Release{
+		{
+			TagName:     "v1.3.0",
+			Name:        "Faster downloads",
+			PublishedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+			Body:        "<!-- updater:min-version=1.1.0 updater:rollout=50 -->\n- Resumable downloads\r\n",
+		},
+		{TagName: "v1.2.1", Name: "v1.2.1", Body: "updater:blocked\n"},
+		{TagName: "v1.2.0", Body: "<!-- keep this comment -->\n- Fixed --target"},
+	}
+
+	expected := `## v1.3.0 - Faster downloads (2025-03-01)
+
+- Resumable downloads
+
+## v1.2.1
+
+## v1.2.0
+
+<!-- keep this comment -->
+- Fixed --target`
+	if got := FormatReleaseNotes(releases); got != expected {
+		t.Errorf("unexpected notes:\n%s\nexpected:\n%s", got, expected)
+	}
+	if got := FormatReleaseNotes(nil); got != "" {
+		t.Errorf("expected no notes, got %q", got)
+	}
+}
+
+func TestUpdateService_ReleaseNotes(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	defer func() { NewGithubClient = originalNewGithubClient }()
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{{TagName: "v1.3.0-rc.1", PreRelease: true}, {TagName: "v1.2.0"}, {TagName: "v1.1.0"}}, nil
+			},
+		}
+	}
+
+	service, err := NewUpdateService(UpdateServiceConfig{RepoURL: "https://github.com/owner/repo"})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	releases, err := service.ReleaseNotes("v1.0.0", "v1.2.0")
+	if err != nil {
+		t.Fatalf("ReleaseNotes failed: %v", err)
+	}
+	if len(releases) != 2 || releases[0].TagName != "v1.2.0" || releases[1].TagName != "v1.1.0" {
+		t.Errorf("unexpected releases %+v", releases)
+	}
+}
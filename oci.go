@@ -0,0 +1,466 @@
+package updater
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// Media types of the manifests accepted from an OCI registry.
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// Annotations read from OCI artifact manifests. A layer holding a binary names
+// its platform with OCIAnnotationOS and OCIAnnotationArch, or else with the
+// platform of its descriptor, and its file name with OCIAnnotationTitle. The
+// description of the manifest is used as the release notes, so it can carry
+// directives such as "updater:blocked" or "updater:rollout=25".
+const (
+	OCIAnnotationOS          = "com.github.snider.updater.os"
+	OCIAnnotationArch        = "com.github.snider.updater.arch"
+	OCIAnnotationTitle       = "org.opencontainers.image.title"
+	OCIAnnotationDescription = "org.opencontainers.image.description"
+	OCIAnnotationCreated     = "org.opencontainers.image.created"
+)
+
+// OCIConfig configures access to the OCI registry selected with a RepoURL of
+// the form "oci://registry.example.com/org/agent".
+type OCIConfig struct {
+	// Username and Password authenticate with the registry, or with the
+	// token service it delegates to. If empty, anonymous pull access is
+	// requested.
+	Username string
+	Password string
+	// PlainHTTP talks to the registry over HTTP instead of HTTPS, for local
+	// test registries.
+	PlainHTTP bool
+}
+
+// ociDescriptor is an OCI content descriptor.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform,omitempty"`
+}
+
+// ociManifest is an OCI image or artifact manifest.
+type ociManifest struct {
+	MediaType   string            `json:"mediaType"`
+	Layers      []ociDescriptor   `json:"layers"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociSource lists releases from the tags of an OCI repository.
+type ociSource struct {
+	host       string
+	repository string
+	config     OCIConfig
+	// client fetches the oci:// URLs of the repository, authenticating with
+	// config.
+	client *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+// isOCIURL reports whether repoURL selects an OCI repository.
+func isOCIURL(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "oci://")
+}
+
+// newOCISource returns the source for a RepoURL of the form
+// "oci://registry/repository", accessing the registry with config.
+func newOCISource(repoURL string, config OCIConfig) (*ociSource, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Scheme != "oci" || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid OCI URL %q: expected oci://registry/repository", repoURL)
+	}
+	s := &ociSource{host: u.Host, repository: strings.Trim(u.Path, "/"), config: config}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.RegisterProtocol("oci", ociTransport{base: http.DefaultTransport.(*http.Transport).Clone(), source: s})
+	s.client = &http.Client{Transport: transport, CheckRedirect: checkSourceRedirect}
+	return s, nil
+}
+
+// Download downloads the asset at the oci:// URL url to the file at path.
+func (s *ociSource) Download(url, path string) error {
+	return downloadFile(s.client, url, path)
+}
+
+// name returns the last element of the repository, which normally names the
+// product.
+func (s *ociSource) name() string {
+	return path.Base(s.repository)
+}
+
+// apiURL returns the oci:// URL of a Distribution API endpoint of the
+// repository, such as "tags/list" or "blobs/<digest>".
+func (s *ociSource) apiURL(endpoint string) string {
+	return "oci://" + s.host + "/v2/" + s.repository + "/" + endpoint
+}
+
+// ociLinkNext matches the next page in the Link header of a tag listing.
+var ociLinkNext = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// Tags lists the tags of the repository, following the pagination links of
+// the registry.
+func (s *ociSource) Tags() ([]string, error) {
+	var tags []string
+	next := s.apiURL("tags/list")
+	for next != "" {
+		current, err := url.Parse(next)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag list URL %q: %w", next, err)
+		}
+		resp, err := s.client.Get(next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %w", s.repository, err)
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = decodeOCIResponse(resp, &page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %w", s.repository, err)
+		}
+		tags = append(tags, page.Tags...)
+
+		next = ""
+		if m := ociLinkNext.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			u, err := current.Parse(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid pagination link %q: %w", m[1], err)
+			}
+			if u.Host == s.host {
+				u.Scheme = "oci"
+			}
+			next = u.String()
+		}
+	}
+	return tags, nil
+}
+
+// Manifest fetches the manifest of tag and verifies it against the digest
+// reported by the registry.
+func (s *ociSource) Manifest(tag string) (*ociManifest, error) {
+	req, err := http.NewRequest(http.MethodGet, s.apiURL("manifests/"+tag), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create manifest request: %w", err)
+	}
+	req.Header.Set("Accept", ociManifestMediaType+", "+dockerManifestMediaType)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest %s: %w", tag, err)
+	}
+	var data json.RawMessage
+	if err := decodeOCIResponse(resp, &data); err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest %s: %w", tag, err)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		if err := verifyDigest(digest, data); err != nil {
+			return nil, fmt.Errorf("manifest %s: %w", tag, err)
+		}
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", tag, err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = resp.Header.Get("Content-Type")
+	}
+	if manifest.MediaType != ociManifestMediaType && manifest.MediaType != dockerManifestMediaType {
+		return nil, fmt.Errorf("manifest %s has %w %q", tag, errUnsupportedManifest, manifest.MediaType)
+	}
+	return &manifest, nil
+}
+
+// errUnsupportedManifest reports a manifest that is not an image manifest, such
+// as an image index or a manifest list.
+var errUnsupportedManifest = errors.New("unsupported media type")
+
+// ListReleases returns a release for every semver tag of the repository,
+// newest first. The assets of a release are the layers of its manifest that
+// are annotated with a platform; their download URLs are verified against the
+// layer digests while they are downloaded.
+func (s *ociSource) ListReleases() ([]Release, error) {
+	tags, err := s.Tags()
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, tag := range tags {
+		if semver.IsValid(formatVersionForComparison(tag)) {
+			versions = append(versions, tag)
+		}
+	}
+	return s.Releases(versions)
+}
+
+// Releases returns the releases of tags, newest first. Tags whose manifest is
+// not an image manifest are skipped.
+func (s *ociSource) Releases(tags []string) ([]Release, error) {
+	var releases []Release
+	for _, tag := range tags {
+		manifest, err := s.Manifest(tag)
+		if errors.Is(err, errUnsupportedManifest) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, s.release(tag, manifest))
+	}
+	sortReleases(releases)
+	return releases, nil
+}
+
+// release returns the release described by the manifest of tag.
+func (s *ociSource) release(tag string, manifest *ociManifest) Release {
+	release := Release{
+		TagName: tag,
+		Name:    tag,
+		Body:    manifest.Annotations[OCIAnnotationDescription],
+	}
+	if created, err := time.Parse(time.RFC3339, manifest.Annotations[OCIAnnotationCreated]); err == nil {
+		release.PublishedAt = created
+	}
+	for _, layer := range manifest.Layers {
+		goos, goarch := layer.Annotations[OCIAnnotationOS], layer.Annotations[OCIAnnotationArch]
+		if (goos == "" || goarch == "") && layer.Platform != nil {
+			goos, goarch = layer.Platform.OS, layer.Platform.Architecture
+		}
+		if goos == "" || goarch == "" {
+			continue
+		}
+		name := goos + "_" + goarch
+		if title := layer.Annotations[OCIAnnotationTitle]; title != "" {
+			name += "/" + path.Base(title)
+		}
+		release.Assets = append(release.Assets, ReleaseAsset{
+			Name:        name,
+			DownloadURL: s.apiURL("blobs/" + layer.Digest),
+			Size:        layer.Size,
+			Digest:      layer.Digest,
+		})
+	}
+	return release
+}
+
+// decodeOCIResponse decodes the JSON body of a registry response into v and
+// closes it, or returns the registry's error for another status than 200.
+func decodeOCIResponse(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Errors []struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(body, &e) == nil && len(e.Errors) > 0 {
+			return fmt.Errorf("%s: %s", e.Errors[0].Code, e.Errors[0].Message)
+		}
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// ociMaxRedirects is how many redirects ociTransport follows, e.g. from a
+// blob to its storage location.
+const ociMaxRedirects = 10
+
+// ociTransport serves the oci:// URLs of source, which name Distribution API
+// endpoints, e.g. "oci://registry/v2/org/agent/blobs/sha256:…". It
+// authenticates with the registry as it demands, follows redirects itself, and
+// verifies blobs against their digest while they are read.
+type ociTransport struct {
+	base   http.RoundTripper
+	source *ociSource
+}
+
+// ociBlobDigest matches the digest of a blob URL.
+var ociBlobDigest = regexp.MustCompile(`/blobs/([a-z0-9]+:[a-fA-F0-9]+)$`)
+
+func (t ociTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.source.host {
+		return nil, fmt.Errorf("refusing OCI URL %s outside registry %s", req.URL.Redacted(), t.source.host)
+	}
+	u := *req.URL
+	u.Scheme = "https"
+	if t.source.config.PlainHTTP {
+		u.Scheme = "http"
+	}
+
+	resp, err := t.authorized(req, &u)
+	if err != nil {
+		return nil, err
+	}
+	for redirects := 0; isRedirect(resp.StatusCode); redirects++ {
+		location, err := resp.Location()
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect from %s: %w", u.Redacted(), err)
+		}
+		if redirects == ociMaxRedirects {
+			return nil, fmt.Errorf("stopped after %d redirects", ociMaxRedirects)
+		}
+		// Storage backends get the request without the registry's credentials.
+		next := req.Clone(req.Context())
+		next.URL = location
+		next.Host = ""
+		next.Header.Del("Authorization")
+		if resp, err = t.base.RoundTrip(next); err != nil {
+			return nil, err
+		}
+	}
+
+	if m := ociBlobDigest.FindStringSubmatch(req.URL.Path); m != nil && req.Method == http.MethodGet && resp.StatusCode == http.StatusOK {
+		body, err := newDigestReader(resp.Body, m[1])
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body = body
+	}
+	return resp, nil
+}
+
+// authorized sends req to u with the source's token, and retries once with
+// new credentials if the registry challenges them.
+func (t ociTransport) authorized(req *http.Request, u *url.URL) (*http.Response, error) {
+	source := t.source
+	send := func(authorization string) (*http.Response, error) {
+		r := req.Clone(req.Context())
+		r.URL = u
+		r.Host = ""
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		return t.base.RoundTrip(r)
+	}
+
+	source.mu.Lock()
+	token := source.token
+	source.mu.Unlock()
+	authorization := ""
+	if token != "" {
+		authorization = "Bearer " + token
+	}
+	resp, err := send(authorization)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch {
+	case strings.EqualFold(scheme, "Bearer") && params["realm"] != "":
+		resp.Body.Close()
+		if token, err = t.fetchToken(params); err != nil {
+			return nil, err
+		}
+		source.mu.Lock()
+		source.token = token
+		source.mu.Unlock()
+		return send("Bearer " + token)
+	case strings.EqualFold(scheme, "Basic") && source.config.Username != "":
+		resp.Body.Close()
+		credentials := source.config.Username + ":" + source.config.Password
+		return send("Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	return resp, nil
+}
+
+// fetchToken requests a bearer token from the token service named by the
+// challenge parameters. Credentials are only sent to a realm that is https or
+// on the registry's own host.
+func (t ociTransport) fetchToken(params map[string]string) (string, error) {
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", params["realm"], err)
+	}
+	credentials := t.source.config.Username != ""
+	if credentials && u.Scheme != "https" && u.Host != t.source.host {
+		return "", fmt.Errorf("refusing to send registry credentials to token realm %s: it is neither https nor on registry %s",
+			u.Redacted(), t.source.host)
+	}
+	query := u.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	if credentials {
+		req.SetBasicAuth(t.source.config.Username, t.source.config.Password)
+	}
+	resp, err := (&http.Client{Transport: t.base}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := decodeOCIResponse(resp, &body); err != nil {
+		return "", fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	if body.Token == "" {
+		body.Token = body.AccessToken
+	}
+	if body.Token == "" {
+		return "", fmt.Errorf("failed to fetch registry token: no token returned")
+	}
+	return body.Token, nil
+}
+
+// ociChallengeParam matches a parameter of a WWW-Authenticate challenge.
+var ociChallengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// parseChallenge returns the scheme and parameters of a WWW-Authenticate
+// header.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+	for _, m := range ociChallengeParam.FindAllStringSubmatch(rest, -1) {
+		params[m[1]] = m[2]
+	}
+	return scheme, params
+}
+
+// isRedirect reports whether code redirects to the Location header.
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
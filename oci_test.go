@@ -0,0 +1,390 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ociDigest returns the sha256 digest of content.
+func ociDigest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ociTestRelease is a tag served by newOCIRegistry.
+type ociTestRelease struct {
+	tag         string
+	description string
+	binaries    map[string]string // "os/arch" to content
+}
+
+// ociTestRegistry is a Distribution API stand-in serving one repository. It
+// hands out bearer tokens for ci:secret, lists tags two per page, and
+// redirects blobs to an unauthenticated storage path.
+type ociTestRegistry struct {
+	*httptest.Server
+	manifests map[string][]byte
+	blobs     map[string]string
+	// corrupt makes the storage serve other content than the digest names.
+	corrupt bool
+	// fetched lists the tags whose manifests were requested.
+	fetched []string
+}
+
+// newOCIRegistry starts a registry serving releases in repository.
+func newOCIRegistry(t *testing.T, repository string, releases []ociTestRelease) *ociTestRegistry {
+	t.Helper()
+	registry := &ociTestRegistry{manifests: make(map[string][]byte), blobs: make(map[string]string)}
+	for _, release := range releases {
+		manifest := ociManifest{
+			MediaType: ociManifestMediaType,
+			Annotations: map[string]string{
+				OCIAnnotationDescription: release.description,
+				OCIAnnotationCreated:     "2025-01-02T03:04:05Z",
+			},
+		}
+		platforms := make([]string, 0, len(release.binaries))
+		for platform := range release.binaries {
+			platforms = append(platforms, platform)
+		}
+		sort.Strings(platforms)
+		for _, platform := range platforms {
+			content := release.binaries[platform]
+			goos, goarch, _ := strings.Cut(platform, "/")
+			digest := ociDigest(content)
+			registry.blobs[digest] = content
+			manifest.Layers = append(manifest.Layers, ociDescriptor{
+				MediaType: "application/octet-stream",
+				Digest:    digest,
+				Size:      int64(len(content)),
+				Annotations: map[string]string{
+					OCIAnnotationOS:    goos,
+					OCIAnnotationArch:  goarch,
+					OCIAnnotationTitle: "agent",
+				},
+			})
+		}
+		manifest.Layers = append(manifest.Layers, ociDescriptor{MediaType: "text/plain", Digest: ociDigest("notes"), Size: 5})
+		data, _ := json.Marshal(manifest)
+		registry.manifests[release.tag] = data
+	}
+
+	prefix := "/v2/" + repository + "/"
+	registry.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if user, password, ok := r.BasicAuth(); !ok || user != "ci" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") != "repository:"+repository+":pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token": "pull-token"}`)
+			return
+		case strings.HasPrefix(r.URL.Path, "/storage/"):
+			if r.Header.Get("Authorization") != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content := registry.blobs[strings.TrimPrefix(r.URL.Path, "/storage/")]
+			if registry.corrupt {
+				content += " tampered"
+			}
+			fmt.Fprint(w, content)
+			return
+		case !strings.HasPrefix(r.URL.Path, prefix):
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test",scope="repository:%s:pull"`,
+				registry.URL, repository))
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"errors": [{"code": "UNAUTHORIZED", "message": "authentication required"}]}`)
+			return
+		}
+		endpoint := strings.TrimPrefix(r.URL.Path, prefix)
+		switch {
+		case endpoint == "tags/list":
+			tags := []string{"latest"}
+			for _, release := range releases {
+				tags = append(tags, release.tag)
+			}
+			sort.Strings(tags)
+			start := 0
+			if last := r.URL.Query().Get("last"); last != "" {
+				start = sort.SearchStrings(tags, last) + 1
+			}
+			end := min(start+2, len(tags))
+			if end < len(tags) {
+				w.Header().Set("Link", fmt.Sprintf(`<%stags/list?n=2&last=%s>; rel="next"`, prefix, tags[end-1]))
+			}
+			json.NewEncoder(w).Encode(map[string]any{"name": repository, "tags": tags[start:end]})
+		case strings.HasPrefix(endpoint, "manifests/"):
+			tag := strings.TrimPrefix(endpoint, "manifests/")
+			registry.fetched = append(registry.fetched, tag)
+			if tag == "latest" {
+				tag = releases[len(releases)-1].tag
+			}
+			data, ok := registry.manifests[tag]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"errors": [{"code": "MANIFEST_UNKNOWN", "message": "manifest unknown"}]}`)
+				return
+			}
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			w.Header().Set("Docker-Content-Digest", ociDigest(string(data)))
+			w.Write(data)
+		case strings.HasPrefix(endpoint, "blobs/"):
+			http.Redirect(w, r, "/storage/"+strings.TrimPrefix(endpoint, "blobs/"), http.StatusTemporaryRedirect)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return registry
+}
+
+// repoURL returns the RepoURL of repository in the registry.
+func (r *ociTestRegistry) repoURL(repository string) string {
+	return "oci://" + strings.TrimPrefix(r.URL, "http://") + "/" + repository
+}
+
+func TestNewOCISource(t *testing.T) {
+	s, err := newOCISource("oci://registry.example.com/org/agent/", OCIConfig{})
+	if err != nil {
+		t.Fatalf("newOCISource failed: %v", err)
+	}
+	if s.host != "registry.example.com" || s.repository != "org/agent" || s.name() != "agent" {
+		t.Errorf("unexpected host %q, repository %q or name %q", s.host, s.repository, s.name())
+	}
+	if got := s.apiURL("tags/list"); got != "oci://registry.example.com/v2/org/agent/tags/list" {
+		t.Errorf("unexpected API URL %s", got)
+	}
+	for _, repoURL := range []string{"oci://registry.example.com", "oci:///org/agent", "https://registry.example.com/org/agent"} {
+		if _, err := newOCISource(repoURL, OCIConfig{}); err == nil {
+			t.Errorf("expected an error for %q", repoURL)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	testCases := []struct {
+		header string
+		scheme string
+		params map[string]string
+	}{
+		{
+			`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:org/agent:pull"`,
+			"Bearer",
+			map[string]string{"realm": "https://auth.example.com/token", "service": "registry.example.com", "scope": "repository:org/agent:pull"},
+		},
+		{`Basic realm="Registry"`, "Basic", map[string]string{"realm": "Registry"}},
+		{"", "", map[string]string{}},
+	}
+	for _, tc := range testCases {
+		scheme, params := parseChallenge(tc.header)
+		if scheme != tc.scheme || fmt.Sprint(params) != fmt.Sprint(tc.params) {
+			t.Errorf("parseChallenge(%q): expected %q %v, got %q %v", tc.header, tc.scheme, tc.params, scheme, params)
+		}
+	}
+}
+
+func TestOCISource_ListReleases(t *testing.T) {
+	registry := newOCIRegistry(t, "org/agent", []ociTestRelease{
+		{tag: "v1.1.0", description: "Broken.\n\n<!-- updater:blocked -->", binaries: map[string]string{"linux/amd64": "agent 1.1.0"}},
+		{tag: "v1.3.0-beta.1", binaries: map[string]string{"linux/amd64": "agent 1.3.0-beta.1"}},
+		{tag: "v1.2.0", description: "Faster startup.", binaries: map[string]string{
+			"linux/amd64":  "agent 1.2.0 linux",
+			"darwin/arm64": "agent 1.2.0 darwin",
+		}},
+	})
+	defer registry.Close()
+
+	s, err := newOCISource(registry.repoURL("org/agent"), OCIConfig{Username: "ci", Password: "secret", PlainHTTP: true})
+	if err != nil {
+		t.Fatalf("newOCISource failed: %v", err)
+	}
+	// A source for the same registry with other credentials keeps them to
+	// itself.
+	other, err := newOCISource(registry.repoURL("org/agent"), OCIConfig{Username: "ci", Password: "wrong", PlainHTTP: true})
+	if err != nil {
+		t.Fatalf("newOCISource failed: %v", err)
+	}
+	releases, err := s.ListReleases()
+	if err != nil {
+		t.Fatalf("ListReleases failed: %v", err)
+	}
+
+	var tags []string
+	for _, release := range releases {
+		tags = append(tags, release.TagName)
+	}
+	if got := strings.Join(tags, ","); got != "v1.3.0-beta.1,v1.2.0,v1.1.0" {
+		t.Fatalf("unexpected releases %s", got)
+	}
+	release := releases[1]
+	if release.Body != "Faster startup." || !release.PublishedAt.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected release notes %q or publish time %s", release.Body, release.PublishedAt)
+	}
+	if !isReleaseBlocked(&releases[2]) {
+		t.Errorf("expected v1.1.0 to be blocked by the directive in its description")
+	}
+	if len(release.Assets) != 2 {
+		t.Fatalf("expected the two annotated layers as assets, got %+v", release.Assets)
+	}
+	asset := release.Assets[1]
+	if asset.Name != "linux_amd64/agent" || asset.Digest != ociDigest("agent 1.2.0 linux") || asset.Size != int64(len("agent 1.2.0 linux")) {
+		t.Errorf("unexpected asset %+v", asset)
+	}
+
+	path := filepath.Join(t.TempDir(), "agent")
+	if err := s.Download(asset.DownloadURL, path); err != nil {
+		t.Fatalf("failed to download layer: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "agent 1.2.0 linux" {
+		t.Errorf("unexpected layer content %q", data)
+	}
+
+	registry.corrupt = true
+	if err := s.Download(asset.DownloadURL, path); err == nil || !strings.Contains(err.Error(), "does not match digest") {
+		t.Errorf("expected a digest mismatch, got %v", err)
+	}
+	registry.corrupt = false
+
+	registry.manifests["v1.2.0"] = []byte(`{"mediaType": "application/vnd.oci.image.index.v1+json", "manifests": []}`)
+	releases, err = s.ListReleases()
+	if err != nil {
+		t.Fatalf("expected the image index to be skipped, got %v", err)
+	}
+	if len(releases) != 2 || findReleaseByVersion(releases, "v1.2.0") != nil {
+		t.Errorf("expected only the image index to be skipped, got %+v", releases)
+	}
+
+	if _, err := other.ListReleases(); err == nil || !strings.Contains(err.Error(), "registry token") {
+		t.Errorf("expected an error for wrong credentials, got %v", err)
+	}
+	if err := other.Download(asset.DownloadURL, path); err == nil {
+		t.Errorf("expected the download to fail with wrong credentials")
+	}
+	if err := DownloadUpdate(asset.DownloadURL, path); err == nil {
+		t.Errorf("expected oci:// URLs to be fetched only by the client of their source")
+	}
+}
+
+func TestOCISource_InsecureTokenRealm(t *testing.T) {
+	var leaked bool
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok {
+			leaked = true
+		}
+		fmt.Fprint(w, `{"token": "pull-token"}`)
+	}))
+	defer tokens.Close()
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test"`, tokens.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer registry.Close()
+
+	s, err := newOCISource("oci://"+strings.TrimPrefix(registry.URL, "http://")+"/org/agent",
+		OCIConfig{Username: "ci", Password: "secret", PlainHTTP: true})
+	if err != nil {
+		t.Fatalf("newOCISource failed: %v", err)
+	}
+	if _, err := s.Tags(); err == nil || !strings.Contains(err.Error(), "refusing to send registry credentials") {
+		t.Errorf("expected the http realm on another host to be refused, got %v", err)
+	}
+	if leaked {
+		t.Errorf("expected no credentials to be sent to the http realm")
+	}
+}
+
+func TestOCISource_ManifestDigest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Content-Digest", ociDigest("something else"))
+		fmt.Fprintf(w, `{"mediaType": %q, "layers": []}`, ociManifestMediaType)
+	}))
+	defer server.Close()
+
+	s, err := newOCISource("oci://"+strings.TrimPrefix(server.URL, "http://")+"/agent", OCIConfig{PlainHTTP: true})
+	if err != nil {
+		t.Fatalf("newOCISource failed: %v", err)
+	}
+	if _, err := s.Manifest("v1.0.0"); err == nil || !strings.Contains(err.Error(), "does not match digest") {
+		t.Errorf("expected a manifest digest mismatch, got %v", err)
+	}
+}
+
+func TestUpdateService_OCISource(t *testing.T) {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	registry := newOCIRegistry(t, "org/agent", []ociTestRelease{
+		{tag: "v1.2.0", binaries: map[string]string{platform: "agent 1.2.0"}},
+		{tag: "v1.3.0", description: "Known bad.\n\n<!-- updater:blocked -->", binaries: map[string]string{platform: "agent 1.3.0"}},
+		{tag: "v1.4.0-beta.1", binaries: map[string]string{platform: "agent 1.4.0-beta.1"}},
+	})
+	defer registry.Close()
+
+	originalDetectVersion := DetectVersion
+	originalApplyUpdateFile := ApplyUpdateFile
+	defer func() {
+		DetectVersion = originalDetectVersion
+		ApplyUpdateFile = originalApplyUpdateFile
+	}()
+	target := filepath.Join(t.TempDir(), "agent")
+	DetectVersion = func(path string) (string, error) { return "v1.1.0", nil }
+	var applied string
+	ApplyUpdateFile = func(path, to string) error {
+		data, err := os.ReadFile(path)
+		applied = string(data)
+		return err
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        registry.repoURL("org/agent"),
+		OCI:            OCIConfig{Username: "ci", Password: "secret", PlainHTTP: true},
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		TargetPath:     target,
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	status, err := service.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Channel != StableChannel || status.Latest != "v1.2.0" || !status.UpdateAvailable {
+		t.Errorf("expected v1.2.0 from the stable channel past the blocked v1.3.0, got %+v", status)
+	}
+	if got := strings.Join(registry.fetched, ","); got != "v1.2.0,v1.3.0" {
+		t.Errorf("expected only the manifests of the stable candidates to be fetched, got %s", got)
+	}
+	if status.AssetURL != registry.repoURL("v2/org/agent/blobs/"+ociDigest("agent 1.2.0")) {
+		t.Errorf("unexpected asset URL %s", status.AssetURL)
+	}
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if applied != "agent 1.2.0" {
+		t.Errorf("expected v1.2.0 to be applied, got %q", applied)
+	}
+}
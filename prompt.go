@@ -0,0 +1,246 @@
+package updater
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// releaseNotesExcerptLines is how many lines of release notes the update
+// prompt shows.
+const releaseNotesExcerptLines = 12
+
+// isTerminal reports whether f is an interactive terminal. This can be
+// replaced in tests.
+var isTerminal = func(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// promptInput is where answers to the update prompt are read from. This can be
+// replaced in tests.
+var promptInput io.Reader = os.Stdin
+
+// startPrompt checks for an update and asks the user whether to apply it. If
+// stdin is not a terminal, it falls back to CheckOnStartup. Versions the user
+// chose to skip are not offered again, unless the update is required.
+func (s *UpdateService) startPrompt() error {
+	if !isTerminal(os.Stdin) {
+		return s.startCheck(CheckOnStartup)
+	}
+
+	status, err := s.Status()
+	if err != nil {
+		return err
+	}
+	display := func(version string) string { return formatVersionForDisplay(version, s.config.ForceSemVerPrefix) }
+	if !status.UpdateAvailable {
+		fmt.Fprintf(s.out(), "You are running the latest version: %s\n", display(status.Current))
+		if status.Pending != "" {
+			s.record(UpdateHeldBack, status.Pending, status.Current)
+		} else {
+			s.record(UpdateNone, status.Latest, status.Current)
+		}
+		return nil
+	}
+	s.record(UpdateAvailable, status.Latest, status.Current)
+	skipped, err := s.isSkipped(status.Latest)
+	if err != nil {
+		return err
+	}
+	if skipped && !status.Required {
+		fmt.Fprintf(s.out(), "Skipping version %s as requested.\n", display(status.Latest))
+		return nil
+	}
+
+	fmt.Fprintf(s.out(), "A new version is available: %s (current version: %s)\n", display(status.Latest), display(status.Current))
+	notes, size := s.updateDetails(status)
+	if size > 0 {
+		fmt.Fprintf(s.out(), "Download size: %s\n", formatSize(size))
+	}
+	if notes != "" {
+		fmt.Fprintf(s.out(), "\n%s\n\n", releaseNotesExcerpt(notes, releaseNotesExcerptLines))
+	}
+
+	options := "[Y/n/skip this version]"
+	if status.Required {
+		fmt.Fprintf(s.out(), "%s is no longer supported; this update is required.\n", display(status.Current))
+		options = "[Y/n]"
+	}
+	fmt.Fprintf(s.out(), "Update now? %s ", options)
+	answer, err := bufio.NewReader(promptInput).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(s.out())
+		return nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		lock, err := s.acquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		return s.deliver(status.Latest, status.Current, status.AssetURL, status.checksum)
+	case "s", "skip", "skip this version":
+		if status.Required {
+			break
+		}
+		if err := s.SkipVersion(status.Latest); err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out(), "Version %s will not be offered again.\n", display(status.Latest))
+		return nil
+	}
+	if status.Required {
+		return fmt.Errorf("%w: version %s is no longer supported, update to %s",
+			ErrUpdateRequired, display(status.Current), display(status.Latest))
+	}
+	fmt.Fprintln(s.out(), "Update postponed.")
+	return nil
+}
+
+// SkipVersion records in the state file that the user does not want to be
+// offered version by PromptOnStartup.
+func (s *UpdateService) SkipVersion(version string) error {
+	path, err := s.statePath()
+	if err != nil {
+		return err
+	}
+	state, err := LoadState(path)
+	if err != nil {
+		return err
+	}
+	if !containsVersion(state.SkippedVersions, version) {
+		state.SkippedVersions = append(state.SkippedVersions, version)
+	}
+	return state.Save(path)
+}
+
+// isSkipped reports whether the user chose to skip version.
+func (s *UpdateService) isSkipped(version string) (bool, error) {
+	path, err := s.statePath()
+	if err != nil {
+		return false, err
+	}
+	state, err := LoadState(path)
+	if err != nil {
+		return false, err
+	}
+	return containsVersion(state.SkippedVersions, version), nil
+}
+
+// containsVersion reports whether versions contains version, ignoring a "v"
+// prefix.
+func containsVersion(versions []string, version string) bool {
+	for _, v := range versions {
+		if canonicalVersion(v) == canonicalVersion(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateDetails returns the release notes of every release from the current
+// to the latest version of status, for GitHub sources, and the download
+// size of the update, or 0 if it is unknown.
+func (s *UpdateService) updateDetails(status *UpdateStatus) (string, int64) {
+	var notes string
+	var size int64
+	if releases, err := s.Releases(); err == nil {
+		if s.listsReleases() {
+			notes = FormatReleaseNotes(ReleasesBetween(releases, status.Current, status.Latest, s.channelRules()))
+		}
+		if release := findReleaseByVersion(releases, status.Latest); release != nil {
+			for _, asset := range release.Assets {
+				if asset.DownloadURL == status.AssetURL {
+					size = asset.Size
+				}
+			}
+		}
+	}
+	if size == 0 {
+		size, _ = downloadSize(status.AssetURL)
+	}
+	return notes, size
+}
+
+// downloadSize returns the size of the asset at url from a HEAD request, or 0
+// if the server does not report it.
+var downloadSize = func(url string) (int64, error) {
+	resp, err := clientFor(url).Head(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return 0, nil
+	}
+	return resp.ContentLength, nil
+}
+
+// formatSize formats a size in bytes for display, e.g. "8.4 MB".
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
+
+var (
+	htmlCommentPattern  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	markdownLinkPattern = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+)
+
+// releaseNotesExcerpt renders Markdown release notes as plain text for the
+// terminal: updater directives and HTML comments are removed, headings, emphasis
+// and links are reduced to their text, and at most maxLines lines are kept.
+func releaseNotesExcerpt(body string, maxLines int) string {
+	body = htmlCommentPattern.ReplaceAllString(body, "")
+	body = markdownLinkPattern.ReplaceAllString(body, "$1")
+	body = strings.NewReplacer("**", "", "__", "", "`", "").Replace(body)
+
+	var lines []string
+	blank := true
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if isDirectiveLine(line) {
+			continue
+		}
+		if trimmed := strings.TrimLeft(line, "#"); len(trimmed) < len(line) && strings.HasPrefix(trimmed, " ") {
+			line = strings.TrimSpace(trimmed)
+		}
+		if strings.HasPrefix(line, "* ") {
+			line = "- " + line[2:]
+		}
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], "...")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,183 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUpdateService_PromptOnStartup(t *testing.T) {
+	originalVersion := Version
+	originalNewGithubClient := NewGithubClient
+	originalDoUpdate := DoUpdate
+	originalIsTerminal := isTerminal
+	originalPromptInput := promptInput
+	originalDownloadSize := downloadSize
+	defer func() {
+		Version = originalVersion
+		NewGithubClient = originalNewGithubClient
+		DoUpdate = originalDoUpdate
+		isTerminal = originalIsTerminal
+		promptInput = originalPromptInput
+		downloadSize = originalDownloadSize
+	}()
+	Version = "v1.1.0"
+	downloadSize = func(url string) (int64, error) { return 8 << 20, nil }
+
+	asset := fmt.Sprintf("agent_%s_%s", runtime.GOOS, runtime.GOARCH)
+	var body string
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{{
+					TagName: "v1.2.0",
+					Body:    body,
+					Assets:  []ReleaseAsset{{Name: asset, DownloadURL: "https://example.com/v1.2.0"}},
+				}}, nil
+			},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		terminal      bool
+		answers       []string
+		body          string
+		expectUpdates int
+		expectSkipped []string
+		expectErr     error
+	}{
+		{name: "accept by default", terminal: true, answers: []string{"\n"}, expectUpdates: 1},
+		{name: "accept", terminal: true, answers: []string{"yes\n"}, expectUpdates: 1},
+		{name: "decline", terminal: true, answers: []string{"n\n"}},
+		{name: "end of input", terminal: true, answers: []string{""}},
+		{
+			name:          "skip this version",
+			terminal:      true,
+			answers:       []string{"skip this version\n", "y\n"},
+			expectSkipped: []string{"v1.2.0"},
+		},
+		{name: "not a terminal", answers: []string{"y\n"}},
+		{
+			name:      "required update cannot be skipped",
+			terminal:  true,
+			body:      "<!-- updater:min-version=1.2.0 -->",
+			answers:   []string{"s\n"},
+			expectErr: ErrUpdateRequired,
+		},
+		{
+			name:          "required update accepted",
+			terminal:      true,
+			body:          "<!-- updater:min-version=1.2.0 -->",
+			answers:       []string{"y\n"},
+			expectUpdates: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body = tc.body
+			updates := 0
+			DoUpdate = func(url string) error {
+				if url != "https://example.com/v1.2.0" {
+					t.Errorf("unexpected update URL %s", url)
+				}
+				updates++
+				return nil
+			}
+			isTerminal = func(f *os.File) bool { return tc.terminal }
+
+			statePath := filepath.Join(t.TempDir(), "state.json")
+			service, err := NewUpdateService(UpdateServiceConfig{
+				RepoURL:        "https://github.com/owner/repo",
+				CheckOnStartup: PromptOnStartup,
+				StatePath:      statePath,
+				LockPath:       filepath.Join(t.TempDir(), "update.lock"),
+			})
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+
+			for i, answer := range tc.answers {
+				promptInput = strings.NewReader(answer)
+				err := service.Start()
+				if i == 0 && !errors.Is(err, tc.expectErr) {
+					t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+				}
+			}
+
+			if updates != tc.expectUpdates {
+				t.Errorf("expected %d updates, got %d", tc.expectUpdates, updates)
+			}
+			state, err := LoadState(statePath)
+			if err != nil {
+				t.Fatalf("LoadState failed: %v", err)
+			}
+			if fmt.Sprint(state.SkippedVersions) != fmt.Sprint(tc.expectSkipped) {
+				t.Errorf("expected skipped versions %v, got %v", tc.expectSkipped, state.SkippedVersions)
+			}
+		})
+	}
+}
+
+func TestReleaseNotesExcerpt(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		maxLines int
+		expected string
+	}{
+		{
+			name:     "markdown",
+			body:     "<!-- updater:min-version=1.0.0 -->\r\n## What's Changed\r\n\r\n\r\n* **Faster** downloads by [@dev](https://github.com/dev)\r\n* Fixed `--target`\r\n",
+			maxLines: 10,
+			expected: "What's Changed\n\n- Faster downloads by @dev\n- Fixed --target",
+		},
+		{
+			name:     "directive line",
+			body:     "updater:blocked\nBroken build",
+			maxLines: 10,
+			expected: "Broken build",
+		},
+		{
+			name:     "truncated",
+			body:     "one\ntwo\nthree\nfour",
+			maxLines: 2,
+			expected: "one\ntwo\n...",
+		},
+		{
+			name:     "empty",
+			body:     "<!-- updater:rollout=10 -->",
+			maxLines: 10,
+			expected: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := releaseNotesExcerpt(tc.body, tc.maxLines); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := []struct {
+		size     int64
+		expected string
+	}{
+		{512, "512 B"},
+		{2048, "2.0 KB"},
+		{8 << 20, "8.0 MB"},
+		{3 << 30, "3.0 GB"},
+	}
+	for _, tc := range testCases {
+		if got := formatSize(tc.size); got != tc.expected {
+			t.Errorf("formatSize(%d): expected %q, got %q", tc.size, tc.expected, got)
+		}
+	}
+}
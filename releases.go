@@ -0,0 +1,117 @@
+package updater
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+
+	"golang.org/x/mod/semver"
+)
+
+// Releases returns the releases offered by the configured source. GitHub, S3
+// and OCI sources list every published release. Generic HTTP sources only
+// offer the version in latest.json, which is returned as a single release with
+// one asset.
+func (s *UpdateService) Releases() ([]Release, error) {
+	if s.listsReleases() {
+		return s.listReleases()
+	}
+
+	info, err := GetLatestUpdateFromURL(s.config.RepoURL)
+	if err != nil {
+		return nil, err
+	}
+	return []Release{{
+		TagName: info.Version,
+		Assets:  []ReleaseAsset{{Name: assetName(info.URL), DownloadURL: info.URL, Digest: info.checksum().digest}},
+	}}, nil
+}
+
+// DownloadURL returns the URL of the asset of release for this platform. The
+// single asset of a generic HTTP source is always for this platform.
+func (s *UpdateService) DownloadURL(release *Release) (string, error) {
+	if s.listsReleases() {
+		return GetDownloadURL(release, s.config.ReleaseURLFormat)
+	}
+	if release == nil || len(release.Assets) == 0 {
+		return "", fmt.Errorf("no download asset found for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	return release.Assets[0].DownloadURL, nil
+}
+
+// Rollback installs an older version, holding the update lock while it is
+// applied. If version is empty, the newest release older than the current
+// version in the tracked channel is installed. Versions that are not older
+// than the current version are refused, and blocked releases are never
+// installed. Rollback does not require AllowDowngrade.
+func (s *UpdateService) Rollback(version string) error {
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		previous, err := s.previousRelease(current)
+		if err != nil {
+			return err
+		}
+		version = previous.TagName
+	}
+	if !isNewerThan(current, version) {
+		return fmt.Errorf("cannot roll back to %s: it is not older than the current version %s",
+			formatVersionForDisplay(version, s.config.ForceSemVerPrefix),
+			formatVersionForDisplay(current, s.config.ForceSemVerPrefix))
+	}
+	return s.updateTo(version, true)
+}
+
+// PreviousRelease returns the release Rollback installs without a version: the
+// newest release older than the current version in the tracked channel that
+// is not blocked.
+func (s *UpdateService) PreviousRelease() (*Release, error) {
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+	return s.previousRelease(current)
+}
+
+// previousRelease returns the newest release older than current that the
+// tracked channel receives and that is not blocked.
+func (s *UpdateService) previousRelease(current string) (*Release, error) {
+	releases, err := s.Releases()
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := s.blockedVersions()
+	if err != nil {
+		return nil, err
+	}
+	channel, err := s.channelFor(current)
+	if err != nil {
+		return nil, err
+	}
+
+	release := latestMatchingRelease(releases, s.channelRules(), channel, func(r *Release) bool {
+		return !blocked.has(r.TagName) && isNewerThan(current, r.TagName)
+	})
+	if release == nil {
+		return nil, fmt.Errorf("no release older than %s found in channel %s",
+			formatVersionForDisplay(current, s.config.ForceSemVerPrefix), channel)
+	}
+	return release, nil
+}
+
+// sortReleases sorts releases by version, newest first, for sources that do
+// not list them in order.
+func sortReleases(releases []Release) {
+	sort.Slice(releases, func(i, j int) bool {
+		a, b := formatVersionForComparison(releases[i].TagName), formatVersionForComparison(releases[j].TagName)
+		if c := semver.Compare(a, b); c != 0 {
+			return c > 0
+		}
+		if releases[i].TagName != releases[j].TagName {
+			return releases[i].TagName < releases[j].TagName
+		}
+		return releases[i].Channel < releases[j].Channel
+	})
+}
@@ -0,0 +1,204 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUpdateService_Releases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest.json" {
+			fmt.Fprintln(w, `{"version": "1.2.0", "url": "http://example.com/downloads/agent_linux_amd64"}`)
+		}
+	}))
+	defer server.Close()
+
+	originalNewGithubClient := NewGithubClient
+	defer func() { NewGithubClient = originalNewGithubClient }()
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				if owner != "owner" || repo != "repo" {
+					t.Errorf("unexpected repository %s/%s", owner, repo)
+				}
+				return []Release{{TagName: "v1.1.0"}, {TagName: "v1.0.0"}}, nil
+			},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		repoURL  string
+		expected string
+	}{
+		{"GitHub", "https://github.com/owner/repo", "v1.1.0 v1.0.0"},
+		{"HTTP", server.URL, "1.2.0:agent_linux_amd64"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, err := NewUpdateService(UpdateServiceConfig{RepoURL: tc.repoURL})
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+			releases, err := service.Releases()
+			if err != nil {
+				t.Fatalf("Releases failed: %v", err)
+			}
+			var got []string
+			for _, release := range releases {
+				entry := release.TagName
+				for _, asset := range release.Assets {
+					entry += ":" + asset.Name
+				}
+				got = append(got, entry)
+			}
+			if strings.Join(got, " ") != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, strings.Join(got, " "))
+			}
+		})
+	}
+}
+
+func TestUpdateService_DownloadURL(t *testing.T) {
+	platform := fmt.Sprintf("agent_%s_%s", runtime.GOOS, runtime.GOARCH)
+	testCases := []struct {
+		name        string
+		config      UpdateServiceConfig
+		release     *Release
+		expectedURL string
+		expectErr   bool
+	}{
+		{
+			name:   "GitHub platform asset",
+			config: UpdateServiceConfig{RepoURL: "https://github.com/owner/repo"},
+			release: &Release{TagName: "v1.0.0", Assets: []ReleaseAsset{
+				{Name: "checksums.txt", DownloadURL: "http://example.com/checksums.txt"},
+				{Name: platform, DownloadURL: "http://example.com/" + platform},
+			}},
+			expectedURL: "http://example.com/" + platform,
+		},
+		{
+			name:        "GitHub release URL format",
+			config:      UpdateServiceConfig{RepoURL: "https://github.com/owner/repo", ReleaseURLFormat: "http://example.com/{tag}/agent"},
+			release:     &Release{TagName: "v1.0.0"},
+			expectedURL: "http://example.com/v1.0.0/agent",
+		},
+		{
+			name:      "GitHub without platform asset",
+			config:    UpdateServiceConfig{RepoURL: "https://github.com/owner/repo"},
+			release:   &Release{TagName: "v1.0.0", Assets: []ReleaseAsset{{Name: "checksums.txt"}}},
+			expectErr: true,
+		},
+		{
+			name:        "HTTP",
+			config:      UpdateServiceConfig{RepoURL: "http://updates.example.com"},
+			release:     &Release{TagName: "1.0.0", Assets: []ReleaseAsset{{Name: "agent", DownloadURL: "http://example.com/agent"}}},
+			expectedURL: "http://example.com/agent",
+		},
+		{
+			name:      "HTTP without asset",
+			config:    UpdateServiceConfig{RepoURL: "http://updates.example.com"},
+			release:   &Release{TagName: "1.0.0"},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, err := NewUpdateService(tc.config)
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+			url, err := service.DownloadURL(tc.release)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+			if url != tc.expectedURL {
+				t.Errorf("expected URL %q, got %q", tc.expectedURL, url)
+			}
+		})
+	}
+}
+
+func TestUpdateService_Rollback(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	originalUpdateToVersion := UpdateToVersion
+	originalVersion := Version
+	defer func() {
+		NewGithubClient = originalNewGithubClient
+		UpdateToVersion = originalUpdateToVersion
+		Version = originalVersion
+	}()
+
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{
+					{TagName: "v1.3.0"},
+					{TagName: "v1.3.0-beta.1", PreRelease: true},
+					{TagName: "v1.2.0", Body: "updater:blocked"},
+					{TagName: "v1.1.0"},
+					{TagName: "v1.0.0"},
+				}, nil
+			},
+		}
+	}
+	var installed string
+	UpdateToVersion = func(owner, repo, version string, allowDowngrade, forceSemVerPrefix bool, releaseURLFormat string) error {
+		if !allowDowngrade {
+			t.Errorf("expected rollback to allow downgrades")
+		}
+		installed = version
+		return nil
+	}
+
+	testCases := []struct {
+		name        string
+		current     string
+		version     string
+		expected    string
+		expectError string
+	}{
+		{name: "previous release", current: "v1.3.0", expected: "v1.1.0"},
+		{name: "explicit version", current: "v1.3.0", version: "v1.0.0", expected: "v1.0.0"},
+		{name: "oldest release", current: "v1.0.0", expectError: "no release older than v1.0.0"},
+		{name: "newer version", current: "v1.1.0", version: "v1.3.0", expectError: "not older than the current version v1.1.0"},
+		{name: "current version", current: "v1.1.0", version: "1.1.0", expectError: "not older than the current version v1.1.0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			installed = ""
+			Version = tc.current
+			service, err := NewUpdateService(UpdateServiceConfig{
+				RepoURL:           "https://github.com/owner/repo",
+				ForceSemVerPrefix: true,
+				LockPath:          filepath.Join(t.TempDir(), "update.lock"),
+			})
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+
+			err = service.Rollback(tc.version)
+			if tc.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+					t.Errorf("expected error containing %q, got %v", tc.expectError, err)
+				}
+				if installed != "" {
+					t.Errorf("expected nothing to be installed, got %s", installed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rollback failed: %v", err)
+			}
+			if installed != tc.expected {
+				t.Errorf("expected %s to be installed, got %q", tc.expected, installed)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// InstallID returns the persistent, random identifier of this installation,
+// used to decide whether it is part of a staged rollout. By default it is kept
+// in the state file of the running executable. This can be replaced in tests.
+var InstallID = func() (string, error) {
+	path, err := executableStatePath()
+	if err != nil {
+		return "", err
+	}
+	return loadOrCreateInstallID(path)
+}
+
+// rolloutBucket places an installation in one of 100 buckets for a version. The
+// bucket is stable for a given installation and version, so raising the rollout
+// percentage only ever adds installations to it.
+func rolloutBucket(installID, version string) int {
+	sum := sha256.Sum256([]byte(installID + ":" + canonicalVersion(version)))
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+// inRollout reports whether the installation is among the given percentage of
+// installations receiving version.
+func inRollout(installID, version string, percent int) bool {
+	if percent >= 100 {
+		return true
+	}
+	return rolloutBucket(installID, version) < percent
+}
+
+// parseRolloutPercent parses a rollout percentage such as "25" or "25%",
+// clamped to 0-100. Invalid values fail closed: the release is offered to no
+// installation until the directive is fixed.
+func parseRolloutPercent(value string) int {
+	percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "%"))
+	if err != nil {
+		return 0
+	}
+	return max(0, min(percent, 100))
+}
+
+// releaseRolloutPercent returns the percentage of installations a release is
+// offered to, from an "updater:rollout=25" directive in its body.
+func releaseRolloutPercent(release *Release) int {
+	value, ok := parseReleaseDirectives(release.Body)["rollout"]
+	if !ok {
+		return 100
+	}
+	return parseRolloutPercent(value)
+}
+
+// rolloutPercent returns the percentage of installations the update is offered
+// to. Updates without a rollout field are offered to everyone.
+func (info *GenericUpdateInfo) rolloutPercent() int {
+	if info.Rollout == nil {
+		return 100
+	}
+	return max(0, min(*info.Rollout, 100))
+}
+
+// checkRollout reports whether version, offered to percent of installations, is
+// still pending rollout for the installation identified by installID. The ID is
+// only looked up for partial rollouts.
+func checkRollout(version string, percent int, installID func() (string, error)) (bool, error) {
+	if percent >= 100 {
+		return false, nil
+	}
+	id, err := installID()
+	if err != nil {
+		return false, fmt.Errorf("failed to determine install ID: %w", err)
+	}
+	return !inRollout(id, version, percent), nil
+}
+
+// releasePendingRollout reports whether release is held back from this
+// installation of the current version by a staged rollout. Updates required by
+// a minimum supported version are never held back.
+func releasePendingRollout(release *Release, current string, installID func() (string, error)) (bool, error) {
+	minVersion, err := releaseMinimumVersion(release)
+	if err != nil {
+		return false, err
+	}
+	if checkMinimumVersion(current, minVersion, release.TagName) != nil {
+		return false, nil
+	}
+	return checkRollout(release.TagName, releaseRolloutPercent(release), installID)
+}
+
+// releaseHeldBack is like releasePendingRollout, but also prints a notice if
+// the release is held back.
+func releaseHeldBack(w io.Writer, release *Release, forceSemVerPrefix bool, installID func() (string, error)) (bool, error) {
+	pending, err := releasePendingRollout(release, Version, installID)
+	if pending {
+		reportPendingRollout(w, formatVersionForDisplay(release.TagName, forceSemVerPrefix),
+			formatVersionForDisplay(Version, forceSemVerPrefix), releaseRolloutPercent(release))
+	}
+	return pending, err
+}
+
+// updateHeldBack is the generic HTTP counterpart of releaseHeldBack, for an
+// installation of the current version.
+func updateHeldBack(w io.Writer, info *GenericUpdateInfo, current string, installID func() (string, error)) (bool, error) {
+	if checkMinimumVersion(current, info.MinVersion, info.Version) != nil {
+		return false, nil
+	}
+	pending, err := checkRollout(info.Version, info.rolloutPercent(), installID)
+	if pending {
+		reportPendingRollout(w, info.Version, current, info.rolloutPercent())
+	}
+	return pending, err
+}
+
+// reportPendingRollout prints to w that an update is available but not yet
+// rolled out to this installation.
+func reportPendingRollout(w io.Writer, version, current string, percent int) {
+	fmt.Fprintf(w, "Update %s is pending rollout (%d%% of installations, current version: %s).\n",
+		version, percent, current)
+}
@@ -0,0 +1,210 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestInRollout(t *testing.T) {
+	// Raising the percentage must only ever add installations to the rollout.
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("install-%d", i)
+		was := false
+		for _, percent := range []int{0, 5, 25, 50, 100} {
+			in := inRollout(id, "v1.2.0", percent)
+			if was && !in {
+				t.Fatalf("install %s left the rollout when it grew to %d%%", id, percent)
+			}
+			was = in
+		}
+		if !was {
+			t.Fatalf("install %s not included at 100%%", id)
+		}
+	}
+
+	var count int
+	for i := 0; i < 1000; i++ {
+		if inRollout(fmt.Sprintf("install-%d", i), "v1.2.0", 25) {
+			count++
+		}
+	}
+	if count < 180 || count > 320 {
+		t.Errorf("expected roughly 25%% of installations in the rollout, got %d/1000", count)
+	}
+}
+
+func TestParseRolloutPercent(t *testing.T) {
+	testCases := map[string]int{
+		"25":    25,
+		"5%":    5,
+		" 0 ":   0,
+		"150":   100,
+		"-3":    0,
+		"soon":  0,
+		"":      0,
+		"100%%": 0,
+	}
+	for value, expected := range testCases {
+		if got := parseRolloutPercent(value); got != expected {
+			t.Errorf("parseRolloutPercent(%q): expected %d, got %d", value, expected, got)
+		}
+	}
+
+	// A release with an invalid rollout is held back from every installation.
+	release := &Release{TagName: "v1.3.0", Body: "updater:rollout=25percent"}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		pending, err := checkRollout(release.TagName, releaseRolloutPercent(release), func() (string, error) { return id, nil })
+		if err != nil || !pending {
+			t.Errorf("expected %s to be held back by an invalid rollout, got %v (%v)", id, pending, err)
+		}
+	}
+}
+
+// findInstallID returns an install ID that is inside (or outside) the rollout
+// of version at percent.
+func findInstallID(t *testing.T, version string, percent int, inside bool) string {
+	t.Helper()
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("install-%d", i)
+		if inRollout(id, version, percent) == inside {
+			return id
+		}
+	}
+	t.Fatalf("no install ID found")
+	return ""
+}
+
+func TestCheckOnly_Rollout(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	originalInstallID := InstallID
+	originalVersion := Version
+	defer func() {
+		NewGithubClient = originalNewGithubClient
+		InstallID = originalInstallID
+		Version = originalVersion
+	}()
+
+	NewGithubClient = func() GithubClient {
+		return &mockGithubClient{
+			getLatestRelease: func(ctx context.Context, owner, repo, channel string) (*Release, error) {
+				return &Release{TagName: "v1.3.0", Body: "<!-- updater:rollout=10 -->"}, nil
+			},
+		}
+	}
+	Version = "1.2.0"
+
+	for _, inside := range []bool{true, false} {
+		id := findInstallID(t, "v1.3.0", 10, inside)
+		InstallID = func() (string, error) { return id, nil }
+
+		var updates int
+		originalDoUpdate := DoUpdate
+		DoUpdate = func(url string) error {
+			updates++
+			return nil
+		}
+		err := CheckForUpdates("owner", "repo", "stable", true, "https://example.com/{tag}")
+		DoUpdate = originalDoUpdate
+		if err != nil {
+			t.Fatalf("CheckForUpdates failed: %v", err)
+		}
+
+		expected := 0
+		if inside {
+			expected = 1
+		}
+		if updates != expected {
+			t.Errorf("inside rollout %v: expected %d updates, got %d", inside, expected, updates)
+		}
+	}
+}
+
+func TestUpdateService_RolloutFallback(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	originalVersion := Version
+	defer func() {
+		NewGithubClient = originalNewGithubClient
+		Version = originalVersion
+	}()
+
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{
+					{TagName: "v1.3.0", Body: "updater:rollout=5"},
+					{TagName: "v1.2.0"},
+					{TagName: "v1.1.0"},
+				}, nil
+			},
+		}
+	}
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	outside := findInstallID(t, "v1.3.0", 5, false)
+	if err := (&State{InstallID: outside}).Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:   "https://github.com/owner/repo",
+		StatePath: statePath,
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	// Behind the rollout, the newest fully released version is offered instead.
+	Version = "v1.1.0"
+	release, available, pending, err := service.resolveRelease(Version, "stable")
+	if err != nil {
+		t.Fatalf("resolveRelease failed: %v", err)
+	}
+	if !available || release.TagName != "v1.2.0" || pending == nil || pending.TagName != "v1.3.0" {
+		t.Errorf("expected v1.2.0 with v1.3.0 pending, got %v (available %v), pending %v", release, available, pending)
+	}
+
+	// Already on the newest fully released version, v1.3.0 stays pending.
+	Version = "v1.2.0"
+	release, available, pending, err = service.resolveRelease(Version, "stable")
+	if err != nil {
+		t.Fatalf("resolveRelease failed: %v", err)
+	}
+	if available || pending == nil || pending.TagName != "v1.3.0" {
+		t.Errorf("expected no update with v1.3.0 pending, got %v (available %v), pending %v", release, available, pending)
+	}
+}
+
+func TestCheckForUpdatesHTTP_Rollout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest.json" {
+			fmt.Fprintln(w, `{"version": "1.3.0", "url": "http://example.com/update", "rollout": 0}`)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	originalInstallID := InstallID
+	originalDoUpdate := DoUpdate
+	originalVersion := Version
+	defer func() {
+		InstallID = originalInstallID
+		DoUpdate = originalDoUpdate
+		Version = originalVersion
+	}()
+
+	InstallID = func() (string, error) { return "any", nil }
+	DoUpdate = func(url string) error {
+		t.Errorf("update should be held back by a 0%% rollout")
+		return nil
+	}
+	Version = "1.2.0"
+
+	if err := CheckForUpdatesHTTP(server.URL); err != nil {
+		t.Fatalf("CheckForUpdatesHTTP failed: %v", err)
+	}
+}
@@ -0,0 +1,397 @@
+package updater
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPresignExpiry is how long presigned asset URLs stay valid if
+// S3Config.PresignExpiry is not set.
+const defaultPresignExpiry = time.Hour
+
+// S3Config configures an S3-compatible bucket, such as Amazon S3 or MinIO, as
+// the update source. The bucket is selected with a RepoURL of the form
+// "s3://bucket/prefix". Releases are stored under the prefix as
+//
+//	{channel}/{version}/{os}_{arch}/{file}
+//
+// e.g. "stable/v1.4.0/linux_amd64/agent". Files directly in a version
+// directory belong to the release as a whole, e.g. an empty BLOCKED file marks
+// it as blocked, and a blocked.json under the prefix lists further blocked
+// versions.
+type S3Config struct {
+	// Endpoint is the base URL of the S3 API, e.g. "http://localhost:9000"
+	// for MinIO. If empty, the Amazon S3 endpoint of Region is used. Buckets
+	// are always addressed in path style.
+	Endpoint string
+	// Region is the region requests are signed for. If empty, AWS_REGION is
+	// used, or else "us-east-1".
+	Region string
+	// AccessKeyID, SecretAccessKey and SessionToken are static credentials
+	// for signing requests with AWS Signature Version 4. If AccessKeyID is
+	// empty, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+	// are used. Without credentials, the bucket must allow anonymous reads.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PresignExpiry is how long the presigned download URLs of assets stay
+	// valid. Defaults to one hour.
+	PresignExpiry time.Duration
+}
+
+// s3Source lists and downloads releases from an S3-compatible bucket.
+type s3Source struct {
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	region   string
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	presignExpiry   time.Duration
+}
+
+// isS3URL reports whether repoURL selects an S3 bucket.
+func isS3URL(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "s3://")
+}
+
+// newS3Source returns the source for a RepoURL of the form
+// "s3://bucket/prefix".
+func newS3Source(repoURL string, config S3Config) (*s3Source, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 URL %q: expected s3://bucket/prefix", repoURL)
+	}
+	s := &s3Source{
+		bucket:          u.Host,
+		prefix:          strings.Trim(u.Path, "/"),
+		region:          config.Region,
+		accessKeyID:     config.AccessKeyID,
+		secretAccessKey: config.SecretAccessKey,
+		sessionToken:    config.SessionToken,
+		presignExpiry:   config.PresignExpiry,
+	}
+	if s.region == "" {
+		s.region = os.Getenv("AWS_REGION")
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.accessKeyID == "" {
+		s.accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if s.presignExpiry <= 0 {
+		s.presignExpiry = defaultPresignExpiry
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.region + ".amazonaws.com"
+	}
+	if s.endpoint, err = url.Parse(strings.TrimSuffix(endpoint, "/")); err != nil || s.endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	return s, nil
+}
+
+// signed reports whether requests are signed with credentials.
+func (s *s3Source) signed() bool {
+	return s.accessKeyID != "" && s.secretAccessKey != ""
+}
+
+// name returns the last element of the prefix, or else the bucket, which
+// normally names the product.
+func (s *s3Source) name() string {
+	if s.prefix == "" {
+		return s.bucket
+	}
+	return path.Base(s.prefix)
+}
+
+// key returns the object key of name under the prefix.
+func (s *s3Source) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+// objectURL returns the path-style URL of the object with the given key.
+func (s *s3Source) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = s.endpoint.Path + "/" + s.bucket + "/" + key
+	u.RawPath = s3Escape(s.endpoint.Path, false) + "/" + s3Escape(s.bucket, false) + "/" + s3Escape(key, false)
+	return &u
+}
+
+// downloadURL returns a URL from which the object with the given key can be
+// downloaded without credentials: a presigned URL if requests are signed.
+func (s *s3Source) downloadURL(key string) string {
+	u := s.objectURL(key)
+	if s.signed() {
+		s.presign(u, timeNow())
+	}
+	return u.String()
+}
+
+// listBucketResult is the response of ListObjectsV2.
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// s3Error is the error document returned by S3 for failed requests.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// ListReleases lists the objects under the prefix and groups them into one
+// release per channel directory and version, newest first. Each release
+// belongs to the channel of its directory, and releases outside the stable
+// channel directory are flagged as prereleases, like GitHub prereleases.
+func (s *s3Source) ListReleases() ([]Release, error) {
+	byDirectory := make(map[string]*Release)
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if s.prefix != "" {
+			query.Set("prefix", s.prefix+"/")
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u := *s.endpoint
+		u.Path = s.endpoint.Path + "/" + s.bucket
+		u.RawQuery = query.Encode()
+
+		var result listBucketResult
+		if err := s.get(&u, &result); err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			s.addObject(byDirectory, object.Key, object.Size, object.LastModified)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	releases := make([]Release, 0, len(byDirectory))
+	for _, release := range byDirectory {
+		releases = append(releases, *release)
+	}
+	sortReleases(releases)
+	return releases, nil
+}
+
+// addObject adds the object with key to the release of its channel directory
+// and version.
+func (s *s3Source) addObject(byDirectory map[string]*Release, key string, size int64, modified time.Time) {
+	name := key
+	if s.prefix != "" {
+		name = strings.TrimPrefix(key, s.prefix+"/")
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" || parts[len(parts)-1] == "" {
+		return
+	}
+	channel, version := parts[0], parts[1]
+	if len(parts) == 4 && !strings.Contains(parts[2], "_") {
+		return
+	}
+
+	release, ok := byDirectory[channel+"/"+version]
+	if !ok {
+		release = &Release{TagName: version, Name: version, PreRelease: channel != StableChannel, Channel: channel}
+		byDirectory[channel+"/"+version] = release
+	}
+	if modified.After(release.PublishedAt) {
+		release.PublishedAt = modified
+	}
+	release.Assets = append(release.Assets, ReleaseAsset{
+		Name:        path.Join(parts[2:]...),
+		DownloadURL: s.downloadURL(key),
+		Size:        size,
+	})
+}
+
+// BlockListURL returns the URL of blocked.json under the prefix.
+func (s *s3Source) BlockListURL() string {
+	return s.downloadURL(s.key("blocked.json"))
+}
+
+// get sends a signed GET request for u and decodes the XML response into v.
+func (s *s3Source) get(u *url.URL, v any) error {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create S3 request: %w", err)
+	}
+	if s.signed() {
+		s.sign(req, timeNow())
+	}
+	resp, err := sourceClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to list S3 bucket: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e s3Error
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+			return fmt.Errorf("failed to list S3 bucket %s: %s: %s", s.bucket, e.Code, e.Message)
+		}
+		return fmt.Errorf("failed to list S3 bucket %s: status code %d", s.bucket, resp.StatusCode)
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse S3 listing: %w", err)
+	}
+	return nil
+}
+
+// unsignedPayload is the payload hash of presigned requests.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// sign adds an AWS Signature Version 4 Authorization header to req, which
+// must have no body. All headers set on req are signed.
+func (s *s3Source) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	emptyHash := sha256.Sum256(nil)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(emptyHash[:]))
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(headers[name]))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	scope, signature := s.signature(now, req.Method, req.URL, canonicalHeaders.String(), signedHeaders, hex.EncodeToString(emptyHash[:]))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature))
+}
+
+// presign adds AWS Signature Version 4 query parameters to u, so that it can
+// be fetched with a plain GET request until the presign expiry.
+func (s *s3Source) presign(u *url.URL, now time.Time) {
+	now = now.UTC()
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.accessKeyID+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(s.presignExpiry/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if s.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	u.RawQuery = s3CanonicalQuery(query)
+
+	_, signature := s.signature(now, http.MethodGet, u, "host:"+u.Host+"\n", "host", unsignedPayload)
+	u.RawQuery += "&X-Amz-Signature=" + signature
+}
+
+// scope returns the credential scope of requests signed at now.
+func (s *s3Source) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature returns the credential scope and the signature of a request.
+func (s *s3Source) signature(now time.Time, method string, u *url.URL, canonicalHeaders, signedHeaders, payloadHash string) (string, string) {
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		s3CanonicalQuery(u.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := s.scope(now)
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3CanonicalQuery encodes query as required for signing: sorted by key, with
+// keys and values escaped by s3Escape.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes s as required for signing, keeping only unreserved
+// characters. Slashes are kept unless escapeSlash is set.
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -5,9 +5,19 @@
 package updater
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/semver"
 )
 
 // StartupCheckMode defines the updater's behavior on startup.
@@ -20,16 +30,63 @@ const (
 	CheckOnStartup
 	// CheckAndUpdateOnStartup checks for and applies updates on startup.
 	CheckAndUpdateOnStartup
+	// PromptOnStartup checks for updates on startup and, if stdin is a
+	// terminal, shows the new version with an excerpt of its release notes and
+	// asks whether to apply it. Otherwise it behaves like CheckOnStartup.
+	PromptOnStartup
+)
+
+// UpdateAction is what a check did about the update it looked for.
+type UpdateAction string
+
+const (
+	// UpdateNone means there was no update to move to, or the latest release
+	// was skipped because it is blocked or outside the allowed versions.
+	UpdateNone UpdateAction = "none"
+	// UpdateAvailable means an update was found but not applied, because the
+	// check only reports updates or the user postponed or skipped it.
+	UpdateAvailable UpdateAction = "available"
+	// UpdateHeldBack means an update is held back from this installation by a
+	// staged rollout.
+	UpdateHeldBack UpdateAction = "held_back"
+	// UpdateDryRun means the update was downloaded and verified in a dry run,
+	// but not applied.
+	UpdateDryRun UpdateAction = "dry_run"
+	// UpdateStaged means the update was staged to be applied on the next start.
+	UpdateStaged UpdateAction = "staged"
+	// UpdateDeferred means the update was downloaded and is pending until a
+	// maintenance window opens.
+	UpdateDeferred UpdateAction = "deferred"
+	// UpdateApplied means the update was applied.
+	UpdateApplied UpdateAction = "applied"
 )
 
+// CheckResult reports what a check did.
+type CheckResult struct {
+	// Current is the version installed when the check ran.
+	Current string
+	// Version is the release the action concerns, such as the update that was
+	// applied or held back. For UpdateNone it is the latest release, if any.
+	Version string
+	// Action is what the check did.
+	Action UpdateAction
+}
+
 // UpdateServiceConfig holds the configuration for the UpdateService.
 type UpdateServiceConfig struct {
 	// RepoURL is the URL to the repository for updates. It can be a GitHub
-	// repository URL (e.g., "https://github.com/owner/repo") or a base URL
-	// for a generic HTTP update server.
+	// repository URL (e.g., "https://github.com/owner/repo"), an S3 bucket
+	// URL (e.g., "s3://bucket/prefix", see S3Config), an OCI repository URL
+	// (e.g., "oci://registry.example.com/org/agent", see OCIConfig) or a base
+	// URL for a generic HTTP update server.
 	RepoURL string
-	// Channel specifies the release channel to track (e.g., "stable", "prerelease").
-	// This is only used for GitHub-based updates.
+	// S3 configures the endpoint and credentials of an S3 RepoURL.
+	S3 S3Config
+	// OCI configures the credentials of an OCI RepoURL.
+	OCI OCIConfig
+	// Channel specifies the release channel to track (e.g., "stable", "beta").
+	// If empty, the channel saved with SetChannel is used, or else the channel
+	// of the running version. This is only used for GitHub, S3 and OCI sources.
 	Channel string
 	// CheckOnStartup determines the update behavior when the service starts.
 	CheckOnStartup StartupCheckMode
@@ -39,6 +96,94 @@ type UpdateServiceConfig struct {
 	// ReleaseURLFormat provides a template for constructing the download URL for a
 	// release asset. The placeholder {tag} will be replaced with the release tag.
 	ReleaseURLFormat string
+	// CheckInterval makes Start keep checking for updates in the background at
+	// this interval until Stop is called. Later checks run in the
+	// CheckOnStartup mode, except that PromptOnStartup only prompts on
+	// startup and reports later updates like CheckOnStartup. Checks stop once
+	// an update has replaced the running executable, which keeps reporting its
+	// old version until it is restarted. Zero checks on startup only.
+	CheckInterval time.Duration
+	// LockPath is the file used to serialize updates across processes. If empty,
+	// a lock file for the running executable is placed in the user cache directory.
+	LockPath string
+	// LockTimeout is how long to wait for another process to finish updating
+	// before giving up with ErrUpdateLocked. Zero waits DefaultLockTimeout and
+	// a negative value waits indefinitely; a short timeout such as a
+	// millisecond gives up almost at once.
+	LockTimeout time.Duration
+	// VersionConstraint restricts updates to versions matching a semver
+	// constraint expression, e.g. ">=1.4 <2.0", "~1.6" or "^1.2". See
+	// VersionConstraint for the supported syntax.
+	VersionConstraint string
+	// PinnedVersion restricts updates to a single exact version, e.g. "v1.6.2".
+	// It cannot be combined with VersionConstraint.
+	PinnedVersion string
+	// BlockedVersions lists versions that must never be installed, such as a
+	// broken release that was yanked too late.
+	BlockedVersions []string
+	// BlockListURL is the location of a remote blocked.json listing further
+	// blocked versions, which must be reachable. Generic HTTP servers are
+	// also checked for a blocked.json next to latest.json, which is optional.
+	BlockListURL string
+	// DowngradeFromBlocked allows moving to an older release when the running
+	// version is blocked and no newer release is available.
+	DowngradeFromBlocked bool
+	// ApplyRequiredUpdates makes CheckOnStartup apply an update when the
+	// running version is below the minimum supported version declared by the
+	// latest release. If false, Start returns an error wrapping
+	// ErrUpdateRequired instead, which the application can use to block usage.
+	ApplyRequiredUpdates bool
+	// AllowDowngrade permits installing versions older than the running
+	// version. It is required by UpdateTo for older versions, and lets startup
+	// checks move to the latest release of a more stable channel, e.g. from a
+	// beta back to stable.
+	AllowDowngrade bool
+	// StatePath is the file holding the updater's persistent state, such as
+	// the random install ID used for staged rollouts. If empty, a state file
+	// for the running executable is kept in the user config directory.
+	StatePath string
+	// Channels defines custom release channels as ordered rules matching
+	// semver prerelease identifiers, and which channels each subscriber
+	// receives. If empty, DefaultChannelRules are used.
+	Channels ChannelRules
+	// MaintenanceWindows restricts when updates are applied. Updates are still
+	// checked for and downloaded at any time, but applying them is deferred
+	// until a window opens or IsIdle reports true. See ApplyWhenAllowed.
+	MaintenanceWindows []MaintenanceWindow
+	// IsIdle reports whether the application is idle, allowing a deferred
+	// update to be applied outside the maintenance windows. If set without
+	// MaintenanceWindows, updates are only applied while idle.
+	IsIdle func() bool
+	// StageUpdates makes CheckAndUpdateOnStartup download and verify updates
+	// into a staging area instead of applying them. The staged update is
+	// applied by ApplyStagedUpdate on the next start. It takes precedence over
+	// MaintenanceWindows.
+	StageUpdates bool
+	// StagingDir is the staging area for StageUpdates. If empty, a directory
+	// for the running executable is used in the user cache directory.
+	StagingDir string
+	// TargetPath is the binary to update. If empty, the running executable is
+	// updated. The version of another binary is determined with DetectVersion,
+	// and its file mode is kept when it is replaced.
+	TargetPath string
+	// Elevate replaces the target binary when the current user cannot write
+	// it, e.g. CommandElevator("sudo"). If nil, updates of binaries that are
+	// not writable fail with ErrNotWritable before anything is downloaded.
+	Elevate Elevator
+	// DryRun makes the service select, download and verify updates into a
+	// temporary location without applying them, reporting the release, asset,
+	// size and checksum that would have been installed. It takes precedence
+	// over StageUpdates and MaintenanceWindows. See LastDryRun.
+	DryRun bool
+	// PublicKey is the Ed25519 key that update bundles must be signed with.
+	// ApplyBundle refuses bundles if it is not set. See ParsePublicKey.
+	PublicKey ed25519.PublicKey
+	// Output receives the messages the service prints while checking for and
+	// applying updates. If nil, they are printed to os.Stdout. If set, the
+	// service also downloads and applies updates itself rather than through
+	// DoUpdate and the other package-level functions, which print to
+	// os.Stdout.
+	Output io.Writer
 }
 
 // UpdateService provides a configurable interface for handling application updates.
@@ -46,17 +191,33 @@ type UpdateServiceConfig struct {
 // them automatically. The service can handle updates from both GitHub releases
 // and generic HTTP servers.
 type UpdateService struct {
-	config   UpdateServiceConfig
-	isGitHub bool
-	owner    string
-	repo     string
+	config     UpdateServiceConfig
+	isGitHub   bool
+	s3         *s3Source
+	oci        *ociSource
+	owner      string
+	repo       string
+	constraint *VersionConstraint
+
+	mu      sync.Mutex
+	pending *PendingUpdate
+	// replaced is the version of the pending update applied to the running
+	// executable, if any.
+	replaced string
+	dryRun   *DryRunReport
+	result   *CheckResult
+	stop     chan struct{}
+	stopped  chan struct{}
+
+	checkMu sync.Mutex
 }
 
 // NewUpdateService creates and configures a new UpdateService.
 // It parses the repository URL to determine if it's a GitHub repository
-// and extracts the owner and repo name.
+// and extracts the owner and repo name. An invalid VersionConstraint,
+// PinnedVersion or set of Channels is reported as an error.
 func NewUpdateService(config UpdateServiceConfig) (*UpdateService, error) {
-	isGitHub := strings.Contains(config.RepoURL, "github.com")
+	isGitHub := strings.Contains(config.RepoURL, "github.com") && !isS3URL(config.RepoURL) && !isOCIURL(config.RepoURL)
 	var owner, repo string
 	var err error
 
@@ -66,50 +227,916 @@ func NewUpdateService(config UpdateServiceConfig) (*UpdateService, error) {
 			return nil, fmt.Errorf("failed to parse GitHub repo URL: %w", err)
 		}
 	}
+	var s3 *s3Source
+	if isS3URL(config.RepoURL) {
+		if s3, err = newS3Source(config.RepoURL, config.S3); err != nil {
+			return nil, err
+		}
+	}
+	var oci *ociSource
+	if isOCIURL(config.RepoURL) {
+		if oci, err = newOCISource(config.RepoURL, config.OCI); err != nil {
+			return nil, err
+		}
+	}
+
+	var constraint *VersionConstraint
+	switch {
+	case config.VersionConstraint != "" && config.PinnedVersion != "":
+		return nil, fmt.Errorf("VersionConstraint and PinnedVersion cannot both be set")
+	case config.VersionConstraint != "":
+		constraint, err = ParseVersionConstraint(config.VersionConstraint)
+	case config.PinnedVersion != "":
+		constraint, err = exactVersionConstraint(config.PinnedVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Channels.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid channel rules: %w", err)
+	}
 
 	return &UpdateService{
-		config:   config,
-		isGitHub: isGitHub,
-		owner:    owner,
-		repo:     repo,
+		config:     config,
+		isGitHub:   isGitHub,
+		s3:         s3,
+		oci:        oci,
+		owner:      owner,
+		repo:       repo,
+		constraint: constraint,
 	}, nil
 }
 
+// out returns the writer the service prints its messages to.
+func (s *UpdateService) out() io.Writer {
+	if s.config.Output != nil {
+		return s.config.Output
+	}
+	return os.Stdout
+}
+
 // Start initiates the update check based on the service configuration.
 // It determines whether to perform a GitHub or HTTP-based update check
 // based on the RepoURL. The behavior of the check is controlled by the
 // CheckOnStartup setting in the configuration.
+//
+// When updates are applied, the service holds the cross-process update lock for
+// the duration of the download and apply, so concurrent instances of the same
+// application do not replace the executable at the same time.
+//
+// With a CheckInterval, Start returns after the first check and keeps checking
+// in the background until Stop is called.
 func (s *UpdateService) Start() error {
-	if s.isGitHub {
-		return s.startGitHubCheck()
+	if s.config.CheckInterval <= 0 || s.config.CheckOnStartup == NoCheck {
+		return s.startCheck(s.config.CheckOnStartup)
+	}
+	result, err := s.Check()
+	if !s.replacedExecutable(result) {
+		s.startPeriodicChecks()
 	}
-	return s.startHTTPCheck()
+	return err
 }
 
-func (s *UpdateService) startGitHubCheck() error {
-	switch s.config.CheckOnStartup {
-	case NoCheck:
+// Stop stops the periodic checks started by Start and waits for a check in
+// progress to finish. It does nothing if no periodic checks are running.
+func (s *UpdateService) Stop() {
+	s.mu.Lock()
+	stop, stopped := s.stop, s.stopped
+	s.stop, s.stopped = nil, nil
+	s.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// startPeriodicChecks starts checking every CheckInterval until Stop is
+// called, unless periodic checks are already running.
+func (s *UpdateService) startPeriodicChecks() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop, s.stopped = make(chan struct{}), make(chan struct{})
+	go s.checkPeriodically(s.stop, s.stopped)
+}
+
+// checkPeriodically runs a check every CheckInterval until stop is closed or an
+// update has replaced the running executable, then closes stopped. Failed
+// checks are printed and retried at the next interval.
+func (s *UpdateService) checkPeriodically(stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	mode := s.config.CheckOnStartup
+	if mode == PromptOnStartup {
+		mode = CheckOnStartup
+	}
+
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		result, err := s.check(mode)
+		if err != nil {
+			fmt.Fprintf(s.out(), "Update check failed: %v\n", err)
+		}
+		if s.replacedExecutable(result) {
+			return
+		}
+	}
+}
+
+// replacedExecutable reports whether the check with result applied an update
+// to the running executable, after which the service stops checking: the
+// running version is only updated by a restart. It prints why checks stop.
+func (s *UpdateService) replacedExecutable(result *CheckResult) bool {
+	if result.Action != UpdateApplied || s.config.TargetPath != "" {
+		return false
+	}
+	fmt.Fprintln(s.out(), "No further update checks until the application is restarted.")
+	return true
+}
+
+// Check runs the check configured by CheckOnStartup like Start, and reports
+// what it did, e.g. whether an update was applied or only found. Unlike Start,
+// it always selects and applies releases itself instead of leaving default
+// configurations to the package-level functions, which do not report what they
+// did. Checks of a service run one at a time.
+func (s *UpdateService) Check() (*CheckResult, error) {
+	return s.check(s.config.CheckOnStartup)
+}
+
+// check runs a check of the given mode and reports what it did.
+func (s *UpdateService) check(mode StartupCheckMode) (*CheckResult, error) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	result := &CheckResult{Action: UpdateNone}
+	s.mu.Lock()
+	s.result = result
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.result = nil
+		s.mu.Unlock()
+	}()
+
+	err := s.startCheck(mode)
+	return result, err
+}
+
+// record notes what the running Check did, if any.
+func (s *UpdateService) record(action UpdateAction, version, current string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.result != nil {
+		*s.result = CheckResult{Current: current, Version: version, Action: action}
+	}
+}
+
+// delegatesCheck reports whether a check can be left to the package-level
+// functions: no release policy is configured, no Check is recording what the
+// check does, and messages go to os.Stdout like theirs.
+func (s *UpdateService) delegatesCheck() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.usesReleasePolicy() && s.result == nil && s.config.Output == nil
+}
+
+// delegatesInstall reports whether installing a GitHub release can be left to
+// the package-level functions, which replace the running executable and print
+// to os.Stdout.
+func (s *UpdateService) delegatesInstall() bool {
+	return s.config.TargetPath == "" && s.config.Elevate == nil && !s.config.DryRun && s.config.Output == nil
+}
+
+// startCheck runs the startup check of the given mode.
+func (s *UpdateService) startCheck(mode StartupCheckMode) error {
+	if mode == PromptOnStartup {
+		return s.startPrompt()
+	}
+	if mode == CheckAndUpdateOnStartup {
+		lock, err := s.acquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	if s.listsReleases() {
+		return s.startReleaseCheck(mode)
+	}
+	return s.startHTTPCheck(mode)
+}
+
+// listsReleases reports whether the source lists every published release,
+// as GitHub repositories, S3 buckets and OCI repositories do, rather than only
+// the latest one.
+func (s *UpdateService) listsReleases() bool {
+	return s.isGitHub || s.s3 != nil || s.oci != nil
+}
+
+// downloader returns the function that downloads the assets of the source:
+// the registry client of an OCI repository, or else DownloadUpdate.
+func (s *UpdateService) downloader() downloadFunc {
+	if s.oci != nil {
+		return s.oci.Download
+	}
+	return DownloadUpdate
+}
+
+// listReleases lists the releases of a GitHub repository, S3 bucket or OCI
+// repository.
+func (s *UpdateService) listReleases() ([]Release, error) {
+	var releases []Release
+	var err error
+	switch {
+	case s.s3 != nil:
+		releases, err = s.s3.ListReleases()
+	case s.oci != nil:
+		releases, err = s.oci.ListReleases()
+	case s.isGitHub:
+		releases, err = listGithubReleases(context.Background(), NewGithubClient(), s.owner, s.repo)
+	default:
+		return nil, fmt.Errorf("release listing is only supported for GitHub repositories, S3 buckets and OCI repositories")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching releases: %w", err)
+	}
+	return releases, nil
+}
+
+// candidateReleases returns the releases resolveRelease chooses from when
+// moving from the current version in channel. OCI registries need a request
+// per release, so only the releases in channel that are not older than the
+// current version are fetched from them, or the newest release in channel if
+// there is none. Every release is a candidate if downgrades are allowed.
+func (s *UpdateService) candidateReleases(current, channel string) ([]Release, error) {
+	if s.oci == nil || s.config.AllowDowngrade || s.config.DowngradeFromBlocked {
+		return s.listReleases()
+	}
+	tags, err := s.oci.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching releases: %w", err)
+	}
+	rules := s.channelRules()
+	var candidates []string
+	newest := ""
+	for _, tag := range tags {
+		if !semver.IsValid(formatVersionForComparison(tag)) || !rules.Receives(channel, rules.Channel(tag, false)) {
+			continue
+		}
+		if !isNewerThan(current, tag) {
+			candidates = append(candidates, tag)
+		}
+		if newest == "" || isNewerThan(tag, newest) {
+			newest = tag
+		}
+	}
+	if len(candidates) == 0 && newest != "" {
+		candidates = append(candidates, newest)
+	}
+	releases, err := s.oci.Releases(candidates)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching releases: %w", err)
+	}
+	return releases, nil
+}
+
+func (s *UpdateService) startReleaseCheck(mode StartupCheckMode) error {
+	if mode == NoCheck {
 		return nil // Do nothing
+	}
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	channel, err := s.channelFor(current)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
 	case CheckOnStartup:
-		return CheckOnly(s.owner, s.repo, s.config.Channel, s.config.ForceSemVerPrefix, s.config.ReleaseURLFormat)
+		if s.delegatesCheck() {
+			err := CheckOnly(s.owner, s.repo, channel, s.config.ForceSemVerPrefix, s.config.ReleaseURLFormat)
+			return s.escalateRequiredUpdate(err, func() error {
+				return CheckForUpdates(s.owner, s.repo, channel, s.config.ForceSemVerPrefix, s.config.ReleaseURLFormat)
+			})
+		}
+		release, updateAvailable, pending, err := s.resolveRelease(current, channel)
+		if err != nil {
+			return err
+		}
+		if !updateAvailable && pending != nil {
+			s.reportPendingRelease(pending, current)
+			return nil
+		}
+		reportNewerVersion(s.out(), release, current, updateAvailable, s.config.ForceSemVerPrefix)
+		if !updateAvailable {
+			s.recordNone(release, current)
+			return nil
+		}
+		s.record(UpdateAvailable, release.TagName, current)
+		err = checkReleaseMinimumVersion(current, release)
+		return s.escalateRequiredUpdate(err, func() error {
+			return s.applyRelease(release, current, true)
+		})
 	case CheckAndUpdateOnStartup:
-		return CheckForUpdates(s.owner, s.repo, s.config.Channel, s.config.ForceSemVerPrefix, s.config.ReleaseURLFormat)
+		if s.delegatesCheck() {
+			return CheckForUpdates(s.owner, s.repo, channel, s.config.ForceSemVerPrefix, s.config.ReleaseURLFormat)
+		}
+		release, updateAvailable, pending, err := s.resolveRelease(current, channel)
+		if err != nil {
+			return err
+		}
+		if !updateAvailable && pending != nil {
+			s.reportPendingRelease(pending, current)
+			return nil
+		}
+		return s.applyRelease(release, current, updateAvailable)
 	default:
-		return fmt.Errorf("unknown startup check mode: %d", s.config.CheckOnStartup)
+		return fmt.Errorf("unknown startup check mode: %d", mode)
 	}
 }
 
-func (s *UpdateService) startHTTPCheck() error {
-	switch s.config.CheckOnStartup {
+func (s *UpdateService) startHTTPCheck(mode StartupCheckMode) error {
+	switch mode {
 	case NoCheck:
 		return nil // Do nothing
 	case CheckOnStartup:
-		return CheckOnlyHTTP(s.config.RepoURL)
+		if s.delegatesCheck() {
+			err := CheckOnlyHTTP(s.config.RepoURL)
+			return s.escalateRequiredUpdate(err, func() error {
+				return CheckForUpdatesHTTP(s.config.RepoURL)
+			})
+		}
+		return s.checkHTTPWithPolicy(false)
 	case CheckAndUpdateOnStartup:
-		return CheckForUpdatesHTTP(s.config.RepoURL)
+		if s.delegatesCheck() {
+			return CheckForUpdatesHTTP(s.config.RepoURL)
+		}
+		return s.checkHTTPWithPolicy(true)
 	default:
-		return fmt.Errorf("unknown startup check mode: %d", s.config.CheckOnStartup)
+		return fmt.Errorf("unknown startup check mode: %d", mode)
+	}
+}
+
+// CheckForNewerVersion finds the newest GitHub release in the configured channel
+// that satisfies the service's VersionConstraint or PinnedVersion and is not
+// blocked, and reports whether the service should move to it. If no channel is
+// configured, the saved or running version's channel is used (see Channel).
+//
+// An older release is reported as an update only when the current version is
+// blocked and DowngradeFromBlocked is set.
+func (s *UpdateService) CheckForNewerVersion() (*Release, bool, error) {
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return nil, false, err
+	}
+	channel, err := s.channelFor(current)
+	if err != nil {
+		return nil, false, err
+	}
+	release, updateAvailable, _, err := s.resolveRelease(current, channel)
+	return release, updateAvailable, err
+}
+
+// resolveRelease selects the release in channel the service should move to from
+// the current version. Releases in a staged rollout that excludes this
+// installation are passed over in favour of the newest fully available release;
+// if that leaves nothing to update to, the held back release is returned as
+// pending.
+func (s *UpdateService) resolveRelease(current, channel string) (release *Release, updateAvailable bool, pending *Release, err error) {
+	releases, err := s.candidateReleases(current, channel)
+	if err != nil {
+		return nil, false, nil, err
+	}
+
+	blocked, err := s.blockedVersions()
+	if err != nil {
+		return nil, false, nil, err
+	}
+	for i := range releases {
+		if isReleaseBlocked(&releases[i]) {
+			blocked.add(releases[i].TagName)
+		}
+	}
+
+	allowed := func(r *Release) bool {
+		return !blocked.has(r.TagName) && (s.constraint == nil || s.constraint.Check(r.TagName))
+	}
+	release = latestMatchingRelease(releases, s.channelRules(), channel, allowed)
+	if release == nil {
+		return nil, false, nil, nil
+	}
+
+	if s.shouldMoveTo(release.TagName, current, blocked) {
+		held, err := releasePendingRollout(release, current, s.installID)
+		if err != nil {
+			return nil, false, nil, err
+		}
+		if held {
+			pending = release
+			release = latestMatchingRelease(releases, s.channelRules(), channel, func(r *Release) bool {
+				if !allowed(r) {
+					return false
+				}
+				held, err := releasePendingRollout(r, current, s.installID)
+				return err == nil && !held
+			})
+			if release == nil {
+				return nil, false, pending, nil
+			}
+		}
+	}
+
+	return release, s.shouldMoveTo(release.TagName, current, blocked), pending, nil
+}
+
+// reportPendingRelease prints that release is held back from the current
+// version by a staged rollout.
+func (s *UpdateService) reportPendingRelease(release *Release, current string) {
+	s.record(UpdateHeldBack, release.TagName, current)
+	reportPendingRollout(s.out(), formatVersionForDisplay(release.TagName, s.config.ForceSemVerPrefix),
+		formatVersionForDisplay(current, s.config.ForceSemVerPrefix), releaseRolloutPercent(release))
+}
+
+// checkHTTPWithPolicy checks the generic HTTP endpoint, skipping an update that
+// is blocked or falls outside the configured constraint.
+func (s *UpdateService) checkHTTPWithPolicy(apply bool) error {
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	info, err := GetLatestUpdateFromURL(s.config.RepoURL)
+	if err != nil {
+		return err
+	}
+
+	blocked, err := s.blockedVersions()
+	if err != nil {
+		return err
+	}
+
+	if blocked.has(info.Version) {
+		fmt.Fprintf(s.out(), "Latest release %s is blocked; skipping.\n", info.Version)
+		s.record(UpdateNone, info.Version, current)
+		return nil
+	}
+	if s.constraint != nil && !s.constraint.Check(info.Version) {
+		fmt.Fprintf(s.out(), "Latest release %s is outside the allowed versions %s.\n", info.Version, s.constraint)
+		s.record(UpdateNone, info.Version, current)
+		return nil
+	}
+
+	updateAvailable := s.shouldMoveTo(info.Version, current, blocked)
+	if updateAvailable {
+		if held, err := updateHeldBack(s.out(), info, current, s.installID); err != nil || held {
+			if held {
+				s.record(UpdateHeldBack, info.Version, current)
+			}
+			return err
+		}
+	}
+	if apply {
+		return s.applyHTTPUpdate(info, current, updateAvailable)
+	}
+	reportHTTPVersion(s.out(), info, current, updateAvailable)
+	if !updateAvailable {
+		s.record(UpdateNone, info.Version, current)
+		return nil
+	}
+	s.record(UpdateAvailable, info.Version, current)
+	err = checkMinimumVersion(current, info.MinVersion, info.Version)
+	return s.escalateRequiredUpdate(err, func() error {
+		return s.applyHTTPUpdate(info, current, true)
+	})
+}
+
+// UpdateTo installs exactly the given version from the configured source,
+// holding the update lock while it is applied. Versions older than the current
+// version require AllowDowngrade, and blocked versions are refused.
+//
+// GitHub sources can install any published release. Generic HTTP sources only
+// offer the version in latest.json.
+func (s *UpdateService) UpdateTo(version string) error {
+	return s.updateTo(version, s.config.AllowDowngrade)
+}
+
+// UpdateToPullRequest installs the release built for a GitHub pull request,
+// holding the update lock while it is applied, and returns that release. It
+// returns nil if the pull request has no release. Pull request builds are
+// installed regardless of their version, but respect TargetPath, Elevate and
+// DryRun like any other update.
+func (s *UpdateService) UpdateToPullRequest(number int) (*Release, error) {
+	if !s.isGitHub {
+		return nil, fmt.Errorf("pull request builds are only available from GitHub repositories")
+	}
+	release, err := NewGithubClient().GetReleaseByPullRequest(context.Background(), s.owner, s.repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching release for pull request: %w", err)
+	}
+	if release == nil {
+		fmt.Fprintf(s.out(), "No release found for PR #%d.\n", number)
+		return nil, nil
+	}
+
+	lock, err := s.acquireLock()
+	if err != nil {
+		return release, err
+	}
+	defer lock.Release()
+
+	if s.delegatesInstall() {
+		return release, CheckForUpdatesByPullRequest(s.owner, s.repo, number, s.config.ReleaseURLFormat)
+	}
+
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return release, err
+	}
+	if err := s.checkInstallation(); err != nil {
+		return release, err
+	}
+	downloadURL, err := GetDownloadURL(release, s.config.ReleaseURLFormat)
+	if err != nil {
+		return release, fmt.Errorf("error getting download URL: %w", err)
+	}
+	fmt.Fprintf(s.out(), "Release %s found for PR #%d.\n", release.TagName, number)
+	return release, s.install(release.TagName, current, downloadURL, releaseChecksum(release, downloadURL))
+}
+
+// updateTo installs exactly the given version, refusing versions older than the
+// current version unless allowDowngrade is set.
+func (s *UpdateService) updateTo(version string, allowDowngrade bool) error {
+	blocked, err := s.blockedVersions()
+	if err != nil {
+		return err
+	}
+	if blocked.has(version) {
+		return fmt.Errorf("release %s is blocked", version)
+	}
+
+	lock, err := s.acquireLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if s.isGitHub && s.delegatesInstall() {
+		return UpdateToVersion(s.owner, s.repo, version, allowDowngrade, s.config.ForceSemVerPrefix, s.config.ReleaseURLFormat)
+	}
+
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	if err := s.checkInstallation(); err != nil {
+		return err
+	}
+
+	if s.listsReleases() {
+		releases, err := s.listReleases()
+		if err != nil {
+			return err
+		}
+		release := findReleaseByVersion(releases, version)
+		if release == nil {
+			return fmt.Errorf("release %s not found", version)
+		}
+		if isReleaseBlocked(release) {
+			return fmt.Errorf("release %s is blocked", release.TagName)
+		}
+
+		proceed, err := checkTargetVersion(s.out(), release.TagName, current, allowDowngrade, s.config.ForceSemVerPrefix)
+		if err != nil || !proceed {
+			return err
+		}
+		downloadURL, err := GetDownloadURL(release, s.config.ReleaseURLFormat)
+		if err != nil {
+			return fmt.Errorf("error getting download URL: %w", err)
+		}
+		return s.install(release.TagName, current, downloadURL, releaseChecksum(release, downloadURL))
+	}
+
+	info, err := GetLatestUpdateFromURL(s.config.RepoURL)
+	if err != nil {
+		return err
+	}
+	if canonicalVersion(info.Version) != canonicalVersion(version) {
+		return fmt.Errorf("version %s is not available from %s (latest is %s)", version, s.config.RepoURL, info.Version)
+	}
+
+	proceed, err := checkTargetVersion(s.out(), info.Version, current, allowDowngrade, s.config.ForceSemVerPrefix)
+	if err != nil || !proceed {
+		return err
+	}
+	return s.install(info.Version, current, info.URL, info.checksum())
+}
+
+// escalateRequiredUpdate runs apply under the update lock if err reports a
+// required update and the service is configured to apply required updates.
+// Otherwise err is returned unchanged.
+func (s *UpdateService) escalateRequiredUpdate(err error, apply func() error) error {
+	if !errors.Is(err, ErrUpdateRequired) || !s.config.ApplyRequiredUpdates {
+		return err
+	}
+
+	fmt.Fprintln(s.out(), "Update is required by the minimum supported version.")
+	lock, lerr := s.acquireLock()
+	if lerr != nil {
+		return lerr
+	}
+	defer lock.Release()
+	return apply()
+}
+
+// shouldMoveTo reports whether the service should replace the current version
+// with candidate: either it is newer, downgrades are allowed, or the current
+// version is blocked and downgrades away from blocked versions are allowed.
+func (s *UpdateService) shouldMoveTo(candidate, current string, blocked versionSet) bool {
+	if isNewerThan(candidate, current) {
+		return true
+	}
+	if canonicalVersion(candidate) == canonicalVersion(current) {
+		return false
+	}
+	return s.config.AllowDowngrade || (s.config.DowngradeFromBlocked && blocked.has(current))
+}
+
+// applyRelease applies release over the current version if updateAvailable, or
+// stages it for the next start or defers it to a maintenance window if the
+// service is configured to do so.
+func (s *UpdateService) applyRelease(release *Release, current string, updateAvailable bool) error {
+	if !updateAvailable {
+		reportNewerVersion(s.out(), release, current, false, s.config.ForceSemVerPrefix)
+		s.recordNone(release, current)
+		return nil
+	}
+	downloadURL, err := GetDownloadURL(release, s.config.ReleaseURLFormat)
+	if err != nil {
+		return fmt.Errorf("error getting download URL: %w", err)
+	}
+	return s.deliver(release.TagName, current, downloadURL, releaseChecksum(release, downloadURL))
+}
+
+// applyHTTPUpdate is the generic HTTP counterpart of applyRelease.
+func (s *UpdateService) applyHTTPUpdate(info *GenericUpdateInfo, current string, updateAvailable bool) error {
+	if !updateAvailable {
+		reportHTTPVersion(s.out(), info, current, false)
+		s.record(UpdateNone, info.Version, current)
+		return nil
+	}
+	return s.deliver(info.Version, current, info.URL, info.checksum())
+}
+
+// deliver dry-runs, stages, defers or installs the update from current to
+// version, downloaded from url and verified against sum. Nothing is downloaded
+// if the target binary cannot be replaced in place.
+func (s *UpdateService) deliver(version, current, url string, sum assetChecksum) error {
+	if err := checkSourceURL(s.config.RepoURL, url); err != nil {
+		return err
+	}
+	if err := s.checkInstallation(); err != nil {
+		return err
+	}
+	switch {
+	case s.config.DryRun:
+		return s.runDry(version, current, url, sum)
+	case s.config.StageUpdates:
+		return s.stageUpdate(version, current, url, sum)
+	case s.defersApply():
+		return s.deferUpdate(version, current, url, sum)
+	default:
+		return s.install(version, current, url, sum)
+	}
+}
+
+// install downloads the update for version from url, verifies it against sum
+// and applies it over the current version of the target binary, or only
+// reports it in a dry run.
+func (s *UpdateService) install(version, current, url string, sum assetChecksum) error {
+	if err := checkSourceURL(s.config.RepoURL, url); err != nil {
+		return err
+	}
+	return s.installFrom(version, current, url, sum)
+}
+
+// installFrom is install without the check of url against the RepoURL, for
+// updates that were extracted to a local file by the updater itself.
+func (s *UpdateService) installFrom(version, current, url string, sum assetChecksum) error {
+	if s.config.DryRun {
+		return s.runDry(version, current, url, sum)
+	}
+	if isNewerThan(version, current) {
+		s.announce(version, current, "Applying update...")
+	} else {
+		s.announce(version, current, "Applying downgrade...")
+	}
+	elevate, err := s.needsElevation()
+	if err != nil {
+		return err
+	}
+	// The running executable is updated through DoUpdate unless the service
+	// applies the update itself.
+	viaDoUpdate := s.config.TargetPath == "" && !elevate && s.config.Output == nil
+	if viaDoUpdate && sum == (assetChecksum{}) && archiveSuffix(assetName(url)) == "" {
+		if err := DoUpdate(url); err != nil {
+			return err
+		}
+		s.record(UpdateApplied, version, current)
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "updater-download-")
+	if err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "update")
+	if err := downloadVerified(s.downloader(), url, path, sum, s.binaryName()); err != nil {
+		return err
+	}
+	if viaDoUpdate {
+		verified, err := FileURL(path)
+		if err != nil {
+			return err
+		}
+		err = DoUpdate(verified)
+	} else {
+		err = s.applyFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	s.record(UpdateApplied, version, current)
+	return nil
+}
+
+// recordNone records that the check found no update to move to from current,
+// with release as the latest release, if any.
+func (s *UpdateService) recordNone(release *Release, current string) {
+	version := ""
+	if release != nil {
+		version = release.TagName
+	}
+	s.record(UpdateNone, version, current)
+}
+
+// announce prints that version was found while current is installed, followed
+// by the action being taken.
+func (s *UpdateService) announce(version, current, action string) {
+	format := "Version %s found (current: %s). %s\n"
+	if isNewerThan(version, current) {
+		format = "Newer version %s found (current: %s). %s\n"
+	}
+	fmt.Fprintf(s.out(), format,
+		formatVersionForDisplay(version, s.config.ForceSemVerPrefix),
+		formatVersionForDisplay(current, s.config.ForceSemVerPrefix), action)
+}
+
+// blockedVersions collects the versions blocked by the configuration and by any
+// remote block lists.
+func (s *UpdateService) blockedVersions() (versionSet, error) {
+	blocked := newVersionSet(s.config.BlockedVersions...)
+
+	if s.config.BlockListURL != "" {
+		list, err := FetchBlockList(s.config.BlockListURL)
+		if err != nil {
+			return nil, err
+		}
+		blocked.add(list.Versions...)
+	}
+	switch {
+	case s.s3 != nil:
+		blocked.add(fetchImplicitBlockList(s.out(), s.s3.BlockListURL()).Versions...)
+	case !s.listsReleases():
+		u, err := blockListURLFor(s.config.RepoURL)
+		if err != nil {
+			return nil, err
+		}
+		blocked.add(fetchImplicitBlockList(s.out(), u).Versions...)
+	}
+	return blocked, nil
+}
+
+// installID returns the install ID from the configured state file or that of
+// the target binary, or else from the default state file of the running
+// executable.
+func (s *UpdateService) installID() (string, error) {
+	if s.config.StatePath != "" || s.config.TargetPath != "" {
+		path, err := s.statePath()
+		if err != nil {
+			return "", err
+		}
+		return loadOrCreateInstallID(path)
+	}
+	return InstallID()
+}
+
+// usesReleasePolicy reports whether the configuration restricts which releases
+// may be installed or when they are applied, in which case the service selects
+// and applies releases itself rather than using the package-level checks.
+func (s *UpdateService) usesReleasePolicy() bool {
+	return s.constraint != nil ||
+		s.s3 != nil ||
+		s.oci != nil ||
+		len(s.config.BlockedVersions) > 0 ||
+		s.config.BlockListURL != "" ||
+		s.config.DowngradeFromBlocked ||
+		s.config.AllowDowngrade ||
+		s.config.StatePath != "" ||
+		len(s.config.Channels) > 0 ||
+		s.defersApply() ||
+		s.config.StageUpdates ||
+		s.config.TargetPath != "" ||
+		s.config.Elevate != nil ||
+		s.config.DryRun
+}
+
+// Channel returns the channel the service tracks: the configured Channel, or
+// else the channel saved with SetChannel, or else the channel of the running
+// version.
+func (s *UpdateService) Channel() (string, error) {
+	if s.config.Channel != "" {
+		return s.config.Channel, nil
+	}
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return "", err
+	}
+	return s.channelFor(current)
+}
+
+// channelFor is like Channel, but with the current version already known.
+func (s *UpdateService) channelFor(current string) (string, error) {
+	if s.config.Channel != "" {
+		return s.config.Channel, nil
+	}
+	path, err := s.statePath()
+	if err != nil {
+		return "", err
+	}
+	return subscribedChannel(path, s.channelRules(), current)
+}
+
+// SetChannel saves the user's choice of channel in the state file, to be used
+// when no Channel is configured. The channel must be defined by the service's
+// channel rules; an empty channel clears the choice.
+func (s *UpdateService) SetChannel(channel string) error {
+	path, err := s.statePath()
+	if err != nil {
+		return err
+	}
+	return saveChannel(path, channel, s.channelRules())
+}
+
+// statePath returns the configured state file, or the default state file of
+// the target binary.
+func (s *UpdateService) statePath() (string, error) {
+	if s.config.StatePath != "" {
+		return s.config.StatePath, nil
+	}
+	target, err := s.TargetPath()
+	if err != nil {
+		return "", err
+	}
+	return DefaultStatePath(target)
+}
+
+// channelRules returns the configured channel rules, or DefaultChannelRules.
+func (s *UpdateService) channelRules() ChannelRules {
+	if len(s.config.Channels) > 0 {
+		return s.config.Channels
+	}
+	return DefaultChannelRules
+}
+
+// acquireLock takes the update lock configured for the service, waiting up to
+// the LockTimeout, or DefaultLockTimeout if it is zero.
+func (s *UpdateService) acquireLock() (*UpdateLock, error) {
+	path := s.config.LockPath
+	if path == "" {
+		target, err := s.TargetPath()
+		if err != nil {
+			return nil, err
+		}
+		path, err = DefaultLockPath(target)
+		if err != nil {
+			return nil, err
+		}
+	}
+	timeout := s.config.LockTimeout
+	if timeout == 0 {
+		timeout = DefaultLockTimeout
 	}
+	return AcquireUpdateLock(path, timeout)
 }
 
 // ParseRepoURL extracts the owner and repository name from a GitHub URL.
@@ -1,9 +1,18 @@
 package updater
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewUpdateService(t *testing.T) {
@@ -34,6 +43,23 @@ func TestNewUpdateService(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "Invalid version constraint",
+			config: UpdateServiceConfig{
+				RepoURL:           "https://github.com/owner/repo",
+				VersionConstraint: ">=1.a",
+			},
+			expectError: true,
+		},
+		{
+			name: "Constraint and pin together",
+			config: UpdateServiceConfig{
+				RepoURL:           "https://github.com/owner/repo",
+				VersionConstraint: "^1.2",
+				PinnedVersion:     "v1.2.3",
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -50,6 +76,9 @@ func TestNewUpdateService(t *testing.T) {
 }
 
 func TestUpdateService_Start(t *testing.T) {
+	// Keep the default update lock out of the real cache directory
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
 	// Setup a mock server for HTTP tests
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`{"version": "v1.1.0", "url": "http://example.com/release.zip"}`))
@@ -168,3 +197,594 @@ func TestUpdateService_Start(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateService_StartLocked(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "update.lock")
+	held, err := AcquireUpdateLock(lockPath, 0)
+	if err != nil {
+		t.Fatalf("AcquireUpdateLock failed: %v", err)
+	}
+	defer held.Release()
+
+	var calls int
+	originalCheckForUpdates := CheckForUpdates
+	CheckForUpdates = func(owner, repo, channel string, forceSemVerPrefix bool, releaseURLFormat string) error {
+		calls++
+		return nil
+	}
+	defer func() { CheckForUpdates = originalCheckForUpdates }()
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        "https://github.com/owner/repo",
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		LockPath:       lockPath,
+		LockTimeout:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	if err := service.Start(); !errors.Is(err, ErrUpdateLocked) {
+		t.Errorf("Expected ErrUpdateLocked, got: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no update while the lock is held, got %d calls", calls)
+	}
+
+	held.Release()
+	if err := service.Start(); err != nil {
+		t.Errorf("Expected update to proceed once the lock is released, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 update call, got %d", calls)
+	}
+}
+
+func TestUpdateService_StartWaitsForLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "update.lock")
+	held, err := AcquireUpdateLock(lockPath, 0)
+	if err != nil {
+		t.Fatalf("AcquireUpdateLock failed: %v", err)
+	}
+
+	var calls int
+	originalCheckForUpdates := CheckForUpdates
+	CheckForUpdates = func(owner, repo, channel string, forceSemVerPrefix bool, releaseURLFormat string) error {
+		calls++
+		return nil
+	}
+	defer func() { CheckForUpdates = originalCheckForUpdates }()
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        "https://github.com/owner/repo",
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		LockPath:       lockPath,
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	time.AfterFunc(200*time.Millisecond, func() { held.Release() })
+	if err := service.Start(); err != nil {
+		t.Errorf("Expected Start to wait for the lock with the default timeout, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 update call, got %d", calls)
+	}
+}
+
+func TestUpdateService_CheckForNewerVersionConstrained(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	defer func() { NewGithubClient = originalNewGithubClient }()
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{
+					{TagName: "v2.1.0"},
+					{TagName: "v2.0.0"},
+					{TagName: "v1.7.0-beta.1", PreRelease: true},
+					{TagName: "v1.6.2"},
+					{TagName: "v1.6.1"},
+					{TagName: "v1.5.0"},
+				}, nil
+			},
+		}
+	}
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "v1.5.0"
+
+	testCases := []struct {
+		name            string
+		config          UpdateServiceConfig
+		expectedTag     string
+		expectAvailable bool
+	}{
+		{
+			name:            "Major version constraint",
+			config:          UpdateServiceConfig{VersionConstraint: ">=1.4 <2.0"},
+			expectedTag:     "v1.6.2",
+			expectAvailable: true,
+		},
+		{
+			name:            "Tilde constraint",
+			config:          UpdateServiceConfig{VersionConstraint: "~1.6", Channel: "stable"},
+			expectedTag:     "v1.6.2",
+			expectAvailable: true,
+		},
+		{
+			name:            "Pinned version",
+			config:          UpdateServiceConfig{PinnedVersion: "1.6.1"},
+			expectedTag:     "v1.6.1",
+			expectAvailable: true,
+		},
+		{
+			name:            "Beta channel within constraint",
+			config:          UpdateServiceConfig{VersionConstraint: "^1.5", Channel: "beta"},
+			expectedTag:     "v1.7.0-beta.1",
+			expectAvailable: true,
+		},
+		{
+			name:        "Nothing newer in range",
+			config:      UpdateServiceConfig{VersionConstraint: "<=1.5"},
+			expectedTag: "v1.5.0",
+		},
+		{
+			name:   "Nothing in range",
+			config: UpdateServiceConfig{VersionConstraint: "^3"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.RepoURL = "https://github.com/owner/repo"
+			service, err := NewUpdateService(tc.config)
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+
+			release, available, err := service.CheckForNewerVersion()
+			if err != nil {
+				t.Fatalf("CheckForNewerVersion failed: %v", err)
+			}
+			tag := ""
+			if release != nil {
+				tag = release.TagName
+			}
+			if tag != tc.expectedTag {
+				t.Errorf("Expected release %q, got %q", tc.expectedTag, tag)
+			}
+			if available != tc.expectAvailable {
+				t.Errorf("Expected update available: %v, got: %v", tc.expectAvailable, available)
+			}
+		})
+	}
+}
+
+func TestUpdateService_StartHTTPConstrained(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"version": "v2.0.0", "url": "http://example.com/release"}`)
+	}))
+	defer server.Close()
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "v1.5.0"
+
+	var updates int
+	originalDoUpdate := DoUpdate
+	defer func() { DoUpdate = originalDoUpdate }()
+	DoUpdate = func(url string) error {
+		updates++
+		return nil
+	}
+
+	for _, tc := range []struct {
+		constraint string
+		updates    int
+	}{
+		{constraint: "^1.5", updates: 0},
+		{constraint: ">=1.5", updates: 1},
+	} {
+		updates = 0
+		service, err := NewUpdateService(UpdateServiceConfig{
+			RepoURL:           server.URL,
+			CheckOnStartup:    CheckAndUpdateOnStartup,
+			VersionConstraint: tc.constraint,
+			LockPath:          filepath.Join(t.TempDir(), "update.lock"),
+		})
+		if err != nil {
+			t.Fatalf("NewUpdateService failed: %v", err)
+		}
+		if err := service.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if updates != tc.updates {
+			t.Errorf("constraint %q: expected %d updates, got %d", tc.constraint, tc.updates, updates)
+		}
+	}
+}
+
+func TestUpdateService_RequiredUpdate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest.json" {
+			fmt.Fprintln(w, `{"version": "1.3.0", "url": "http://example.com/update", "min_version": "1.2.0"}`)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "1.1.0"
+
+	var updates int
+	originalDoUpdate := DoUpdate
+	defer func() { DoUpdate = originalDoUpdate }()
+	DoUpdate = func(url string) error {
+		updates++
+		return nil
+	}
+
+	testCases := []struct {
+		name        string
+		config      UpdateServiceConfig
+		expectError bool
+		updates     int
+	}{
+		{
+			name:        "Required update is reported",
+			config:      UpdateServiceConfig{},
+			expectError: true,
+		},
+		{
+			name:    "Required update is applied",
+			config:  UpdateServiceConfig{ApplyRequiredUpdates: true},
+			updates: 1,
+		},
+		{
+			name:        "Required update is reported with a release policy",
+			config:      UpdateServiceConfig{VersionConstraint: "^1.1"},
+			expectError: true,
+		},
+		{
+			name:    "Required update is applied with a release policy",
+			config:  UpdateServiceConfig{VersionConstraint: "^1.1", ApplyRequiredUpdates: true},
+			updates: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updates = 0
+			tc.config.RepoURL = server.URL
+			tc.config.CheckOnStartup = CheckOnStartup
+			tc.config.LockPath = filepath.Join(t.TempDir(), "update.lock")
+			service, err := NewUpdateService(tc.config)
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+
+			err = service.Start()
+			if tc.expectError != errors.Is(err, ErrUpdateRequired) {
+				t.Errorf("Expected ErrUpdateRequired: %v, got: %v", tc.expectError, err)
+			}
+			if updates != tc.updates {
+				t.Errorf("Expected %d updates, got %d", tc.updates, updates)
+			}
+		})
+	}
+}
+
+func TestUpdateService_UpdateTo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprintln(w, `{"version": "1.2.0", "url": "http://example.com/update"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "1.3.0"
+
+	var updates int
+	originalDoUpdate := DoUpdate
+	defer func() { DoUpdate = originalDoUpdate }()
+	DoUpdate = func(url string) error {
+		updates++
+		return nil
+	}
+
+	newService := func(config UpdateServiceConfig) *UpdateService {
+		config.RepoURL = server.URL
+		config.LockPath = filepath.Join(t.TempDir(), "update.lock")
+		service, err := NewUpdateService(config)
+		if err != nil {
+			t.Fatalf("NewUpdateService failed: %v", err)
+		}
+		return service
+	}
+
+	if err := newService(UpdateServiceConfig{}).UpdateTo("1.2.0"); !errors.Is(err, ErrDowngradeNotAllowed) {
+		t.Errorf("Expected ErrDowngradeNotAllowed, got: %v", err)
+	}
+	if err := newService(UpdateServiceConfig{AllowDowngrade: true}).UpdateTo("1.1.0"); err == nil {
+		t.Errorf("Expected an error for a version not offered by the server")
+	}
+	if err := newService(UpdateServiceConfig{AllowDowngrade: true, BlockedVersions: []string{"1.2.0"}}).UpdateTo("1.2.0"); err == nil {
+		t.Errorf("Expected an error for a blocked version")
+	}
+	if updates != 0 {
+		t.Fatalf("Expected no updates so far, got %d", updates)
+	}
+
+	if err := newService(UpdateServiceConfig{AllowDowngrade: true}).UpdateTo("v1.2.0"); err != nil {
+		t.Errorf("UpdateTo failed: %v", err)
+	}
+	if updates != 1 {
+		t.Errorf("Expected the downgrade to be applied, got %d updates", updates)
+	}
+}
+
+func TestUpdateService_Check(t *testing.T) {
+	latest := `{"version": "1.3.0", "url": "http://example.com/update"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, latest)
+	}))
+	defer server.Close()
+
+	originalVersion := Version
+	originalDownloadUpdate := DownloadUpdate
+	originalApplyUpdateFile := ApplyUpdateFile
+	defer func() {
+		Version = originalVersion
+		DownloadUpdate = originalDownloadUpdate
+		ApplyUpdateFile = originalApplyUpdateFile
+	}()
+	Version = "1.2.0"
+	var updates int
+	DownloadUpdate = func(url, path string) error {
+		return os.WriteFile(path, []byte("update"), 0755)
+	}
+	ApplyUpdateFile = func(path, target string) error {
+		updates++
+		return nil
+	}
+
+	testCases := []struct {
+		name     string
+		latest   string
+		mode     StartupCheckMode
+		config   UpdateServiceConfig
+		expected CheckResult
+		updates  int
+	}{
+		{
+			name:     "applied",
+			latest:   `{"version": "1.3.0", "url": "http://example.com/update"}`,
+			mode:     CheckAndUpdateOnStartup,
+			expected: CheckResult{Current: "1.2.0", Version: "1.3.0", Action: UpdateApplied},
+			updates:  1,
+		},
+		{
+			name:     "check only",
+			latest:   `{"version": "1.3.0", "url": "http://example.com/update"}`,
+			mode:     CheckOnStartup,
+			expected: CheckResult{Current: "1.2.0", Version: "1.3.0", Action: UpdateAvailable},
+		},
+		{
+			name:     "up to date",
+			latest:   `{"version": "1.2.0", "url": "http://example.com/update"}`,
+			mode:     CheckAndUpdateOnStartup,
+			expected: CheckResult{Current: "1.2.0", Version: "1.2.0", Action: UpdateNone},
+		},
+		{
+			name:     "held back by rollout",
+			latest:   `{"version": "1.3.0", "url": "http://example.com/update", "rollout": 0}`,
+			mode:     CheckAndUpdateOnStartup,
+			expected: CheckResult{Current: "1.2.0", Version: "1.3.0", Action: UpdateHeldBack},
+		},
+		{
+			name:     "blocked",
+			latest:   `{"version": "1.3.0", "url": "http://example.com/update"}`,
+			mode:     CheckAndUpdateOnStartup,
+			config:   UpdateServiceConfig{BlockedVersions: []string{"1.3.0"}},
+			expected: CheckResult{Current: "1.2.0", Version: "1.3.0", Action: UpdateNone},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			latest = tc.latest
+			updates = 0
+			config := tc.config
+			config.RepoURL = server.URL
+			config.CheckOnStartup = tc.mode
+			config.LockPath = filepath.Join(t.TempDir(), "update.lock")
+			config.StatePath = filepath.Join(t.TempDir(), "state.json")
+			var out strings.Builder
+			config.Output = &out
+			service, err := NewUpdateService(config)
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+
+			result, err := service.Check()
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if *result != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, *result)
+			}
+			if updates != tc.updates {
+				t.Errorf("expected %d updates, got %d", tc.updates, updates)
+			}
+			if applied := strings.Contains(out.String(), "Update applied successfully."); applied != (tc.updates > 0) {
+				t.Errorf("expected the outcome to be printed to Output, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestUpdateService_CheckInterval(t *testing.T) {
+	var mu sync.Mutex
+	var checks int
+	latest := "1.2.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest.json" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		checks++
+		fmt.Fprintf(w, `{"version": %q, "url": "http://example.com/update"}`, latest)
+	}))
+	defer server.Close()
+	countChecks := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return checks
+	}
+
+	originalVersion := Version
+	originalDoUpdate := DoUpdate
+	defer func() {
+		Version = originalVersion
+		DoUpdate = originalDoUpdate
+	}()
+	Version = "1.2.0"
+	var updates int
+	DoUpdate = func(url string) error {
+		updates++
+		return nil
+	}
+
+	newService := func(mode StartupCheckMode) *UpdateService {
+		service, err := NewUpdateService(UpdateServiceConfig{
+			RepoURL:        server.URL,
+			CheckOnStartup: mode,
+			CheckInterval:  5 * time.Millisecond,
+			LockPath:       filepath.Join(t.TempDir(), "update.lock"),
+		})
+		if err != nil {
+			t.Fatalf("NewUpdateService failed: %v", err)
+		}
+		return service
+	}
+
+	service := newService(CheckOnStartup)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); countChecks() < 3; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			service.Stop()
+			t.Fatalf("expected periodic checks, got %d", countChecks())
+		}
+	}
+	service.Stop()
+	stopped := countChecks()
+	time.Sleep(20 * time.Millisecond)
+	if countChecks() != stopped {
+		t.Errorf("expected no checks after Stop, got %d more", countChecks()-stopped)
+	}
+
+	// Once the running executable is replaced, the service stops checking.
+	mu.Lock()
+	latest = "1.3.0"
+	checks = 0
+	mu.Unlock()
+	service = newService(CheckAndUpdateOnStartup)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	service.Stop()
+	if countChecks() != 1 || updates != 1 {
+		t.Errorf("expected a single check applying the update, got %d checks and %d updates", countChecks(), updates)
+	}
+}
+
+func TestUpdateService_UpdateToPullRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "pr build")
+	}))
+	defer server.Close()
+
+	originalNewGithubClient := NewGithubClient
+	originalCheckForUpdatesByPullRequest := CheckForUpdatesByPullRequest
+	originalDetectVersion := DetectVersion
+	originalApplyUpdateFile := ApplyUpdateFile
+	defer func() {
+		NewGithubClient = originalNewGithubClient
+		CheckForUpdatesByPullRequest = originalCheckForUpdatesByPullRequest
+		DetectVersion = originalDetectVersion
+		ApplyUpdateFile = originalApplyUpdateFile
+	}()
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			GetReleaseByPullRequestFunc: func(ctx context.Context, owner, repo string, prNumber int) (*Release, error) {
+				if prNumber != 7 {
+					return nil, nil
+				}
+				return &Release{TagName: "v1.3.0-pr.7", Assets: []ReleaseAsset{
+					{Name: "agent_" + runtime.GOOS + "_" + runtime.GOARCH, DownloadURL: server.URL + "/agent"},
+				}}, nil
+			},
+		}
+	}
+	CheckForUpdatesByPullRequest = func(owner, repo string, prNumber int, releaseURLFormat string) error {
+		t.Errorf("expected the target binary to be updated, not the running executable")
+		return nil
+	}
+	DetectVersion = func(path string) (string, error) { return "v1.2.0", nil }
+	var applied []string
+	ApplyUpdateFile = func(path, target string) error {
+		data, err := os.ReadFile(path)
+		applied = append(applied, target+": "+string(data))
+		return err
+	}
+
+	target := filepath.Join(t.TempDir(), "agent")
+	newService := func(config UpdateServiceConfig) *UpdateService {
+		config.RepoURL = "https://github.com/owner/agent"
+		config.TargetPath = target
+		config.LockPath = filepath.Join(t.TempDir(), "update.lock")
+		service, err := NewUpdateService(config)
+		if err != nil {
+			t.Fatalf("NewUpdateService failed: %v", err)
+		}
+		return service
+	}
+
+	dry := newService(UpdateServiceConfig{DryRun: true})
+	release, err := dry.UpdateToPullRequest(7)
+	if err != nil || release == nil || release.TagName != "v1.3.0-pr.7" {
+		t.Fatalf("UpdateToPullRequest failed: %v, %+v", err, release)
+	}
+	if report := dry.LastDryRun(); report == nil || report.Version != "v1.3.0-pr.7" || len(applied) != 0 {
+		t.Errorf("expected a dry run report and nothing applied, got %+v, %v", report, applied)
+	}
+
+	if _, err := newService(UpdateServiceConfig{}).UpdateToPullRequest(7); err != nil {
+		t.Fatalf("UpdateToPullRequest failed: %v", err)
+	}
+	if len(applied) != 1 || applied[0] != target+": pr build" {
+		t.Errorf("expected the pull request build to be applied to the target, got %v", applied)
+	}
+
+	if release, err := newService(UpdateServiceConfig{}).UpdateToPullRequest(8); release != nil || err != nil {
+		t.Errorf("expected no release for a pull request without one, got %+v, %v", release, err)
+	}
+}
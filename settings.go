@@ -0,0 +1,313 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Setting keys accepted in configuration files. The environment variable
+// overriding a key is UPDATER_ followed by the key in upper case, e.g.
+// UPDATER_CHANNEL or UPDATER_REPO_URL.
+const (
+	SettingRepoURL           = "repo_url"
+	SettingChannel           = "channel"
+	SettingReleaseURLFormat  = "release_url_format"
+	SettingVersionConstraint = "version_constraint"
+	SettingPinnedVersion     = "pinned_version"
+	SettingTargetPath        = "target_path"
+	SettingBlockedVersions   = "blocked_versions"
+	SettingBlockListURL      = "block_list_url"
+	SettingForceSemVerPrefix = "force_semver_prefix"
+	SettingAllowDowngrade    = "allow_downgrade"
+	SettingPublicKey         = "public_key"
+	SettingCheckInterval     = "check_interval"
+	SettingS3Endpoint        = "s3_endpoint"
+	SettingS3Region          = "s3_region"
+	SettingOCIUsername       = "oci_username"
+	SettingOCIPassword       = "oci_password"
+	SettingOCIPlainHTTP      = "oci_plain_http"
+)
+
+// settingKeys lists the known setting keys in display order.
+var settingKeys = []string{
+	SettingRepoURL,
+	SettingChannel,
+	SettingReleaseURLFormat,
+	SettingVersionConstraint,
+	SettingPinnedVersion,
+	SettingTargetPath,
+	SettingBlockedVersions,
+	SettingBlockListURL,
+	SettingForceSemVerPrefix,
+	SettingAllowDowngrade,
+	SettingPublicKey,
+	SettingCheckInterval,
+	SettingS3Endpoint,
+	SettingS3Region,
+	SettingOCIUsername,
+	SettingOCIPassword,
+	SettingOCIPlainHTTP,
+}
+
+// settingsFileNames are the configuration file names looked for in each
+// search directory, in order of preference.
+var settingsFileNames = []string{"config.yaml", "config.yml", "config.toml", "config.json"}
+
+// Setting is a configured value and where it came from, such as the path of a
+// configuration file or "env UPDATER_CHANNEL".
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Settings are update settings merged from configuration files, UPDATER_*
+// environment variables and any values set by the application, such as
+// command line flags. List values, like blocked_versions, are kept comma
+// separated.
+//
+// Example of config.yaml:
+//
+//	repo_url: https://github.com/owner/repo
+//	channel: beta
+//	version_constraint: ">=1.4 <2"
+//	blocked_versions: [v1.4.3]
+type Settings struct {
+	values map[string]Setting
+}
+
+// SettingsSearchDirs returns the directories searched for configuration files,
+// in order of precedence: "updater" in the user configuration directory
+// ($XDG_CONFIG_HOME/updater on Linux), then the directory of the running
+// executable.
+func SettingsSearchDirs() []string {
+	var dirs []string
+	if configDir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(configDir, "updater"))
+	}
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+	return dirs
+}
+
+// FindSettingsFiles returns the configuration files present in dirs, in the
+// same order. At most one file is used per directory.
+func FindSettingsFiles(dirs []string) []string {
+	var files []string
+	for _, dir := range dirs {
+		for _, name := range settingsFileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				files = append(files, path)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// LoadSettings merges the configuration files at paths and the UPDATER_*
+// environment variables. Earlier files take precedence over later ones, and
+// environment variables over all files.
+func LoadSettings(paths []string) (*Settings, error) {
+	settings := &Settings{values: make(map[string]Setting)}
+	for i := len(paths) - 1; i >= 0; i-- {
+		values, err := ReadSettingsFile(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			settings.Set(key, value, paths[i])
+		}
+	}
+	for _, key := range settingKeys {
+		env := "UPDATER_" + strings.ToUpper(key)
+		if value, ok := os.LookupEnv(env); ok {
+			settings.Set(key, value, "env "+env)
+		}
+	}
+	return settings, nil
+}
+
+// ReadSettingsFile reads a YAML, TOML or JSON configuration file, chosen by its
+// extension. Unknown keys are reported as errors.
+func ReadSettingsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q: %s", ext, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if !isSettingKey(key) {
+			return nil, fmt.Errorf("unknown setting %q in %s", key, path)
+		}
+		if values[key], err = settingString(value); err != nil {
+			return nil, fmt.Errorf("invalid setting %q in %s: %w", key, path, err)
+		}
+	}
+	return values, nil
+}
+
+// Set sets key to value, recording source as its origin.
+func (s *Settings) Set(key, value, source string) {
+	if s.values == nil {
+		s.values = make(map[string]Setting)
+	}
+	s.values[key] = Setting{Key: key, Value: value, Source: source}
+}
+
+// SetDefault sets key to value if it is not set yet, with the source
+// "default".
+func (s *Settings) SetDefault(key, value string) {
+	if _, ok := s.values[key]; !ok {
+		s.Set(key, value, "default")
+	}
+}
+
+// Get returns the value of key and whether it is set.
+func (s *Settings) Get(key string) (string, bool) {
+	setting, ok := s.values[key]
+	return setting.Value, ok
+}
+
+// All returns the settings that are set, in a stable order.
+func (s *Settings) All() []Setting {
+	all := make([]Setting, 0, len(s.values))
+	for _, key := range settingKeys {
+		if setting, ok := s.values[key]; ok {
+			all = append(all, setting)
+		}
+	}
+	return all
+}
+
+// Apply copies the settings that are set into config.
+func (s *Settings) Apply(config *UpdateServiceConfig) error {
+	for _, setting := range s.All() {
+		switch setting.Key {
+		case SettingRepoURL:
+			config.RepoURL = setting.Value
+		case SettingChannel:
+			config.Channel = setting.Value
+		case SettingReleaseURLFormat:
+			config.ReleaseURLFormat = setting.Value
+		case SettingVersionConstraint:
+			config.VersionConstraint = setting.Value
+		case SettingPinnedVersion:
+			config.PinnedVersion = setting.Value
+		case SettingTargetPath:
+			config.TargetPath = setting.Value
+		case SettingBlockedVersions:
+			config.BlockedVersions = splitList(setting.Value)
+		case SettingBlockListURL:
+			config.BlockListURL = setting.Value
+		case SettingPublicKey:
+			key, err := LoadPublicKey(setting.Value)
+			if err != nil {
+				return fmt.Errorf("invalid %s from %s: %w", setting.Key, setting.Source, err)
+			}
+			config.PublicKey = key
+		case SettingCheckInterval:
+			interval, err := time.ParseDuration(setting.Value)
+			if err != nil || interval < 0 {
+				return fmt.Errorf("invalid %s %q from %s: expected a duration such as 6h or 30m", setting.Key, setting.Value, setting.Source)
+			}
+			config.CheckInterval = interval
+		case SettingS3Endpoint:
+			config.S3.Endpoint = setting.Value
+		case SettingS3Region:
+			config.S3.Region = setting.Value
+		case SettingOCIUsername:
+			config.OCI.Username = setting.Value
+		case SettingOCIPassword:
+			config.OCI.Password = setting.Value
+		case SettingForceSemVerPrefix, SettingAllowDowngrade, SettingOCIPlainHTTP:
+			b, err := strconv.ParseBool(setting.Value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q from %s: expected true or false", setting.Key, setting.Value, setting.Source)
+			}
+			switch setting.Key {
+			case SettingForceSemVerPrefix:
+				config.ForceSemVerPrefix = b
+			case SettingAllowDowngrade:
+				config.AllowDowngrade = b
+			default:
+				config.OCI.PlainHTTP = b
+			}
+		}
+	}
+	return nil
+}
+
+// isSettingKey reports whether key is a known setting.
+func isSettingKey(key string) bool {
+	for _, known := range settingKeys {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
+
+// settingString converts a decoded configuration value to its string form.
+// Lists are joined with commas.
+func settingString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int64, float64:
+		return fmt.Sprint(v), nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := settingString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
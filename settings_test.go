@@ -0,0 +1,224 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSettingsFile(t *testing.T) {
+	expected := map[string]string{
+		"repo_url":            "https://github.com/owner/repo",
+		"channel":             "beta",
+		"version_constraint":  ">=1.4 <2",
+		"blocked_versions":    "v1.4.3,v1.4.4",
+		"force_semver_prefix": "false",
+	}
+
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "config.yaml",
+			content: `repo_url: https://github.com/owner/repo
+channel: beta
+version_constraint: ">=1.4 <2"
+blocked_versions: [v1.4.3, v1.4.4]
+force_semver_prefix: false
+`,
+		},
+		{
+			name: "config.toml",
+			content: `# Updates of the agent
+repo_url = "https://github.com/owner/repo"
+channel = 'beta' # tracked channel
+version_constraint = ">=1.4 <2" # "quoted" # comment
+blocked_versions = [
+  "v1.4.3",
+  "v1.4.4", # yanked
+]
+force_semver_prefix = false
+`,
+		},
+		{
+			name: "config.json",
+			content: `{
+  "repo_url": "https://github.com/owner/repo",
+  "channel": "beta",
+  "version_constraint": ">=1.4 <2",
+  "blocked_versions": ["v1.4.3", "v1.4.4"],
+  "force_semver_prefix": false
+}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.name)
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			values, err := ReadSettingsFile(path)
+			if err != nil {
+				t.Fatalf("ReadSettingsFile failed: %v", err)
+			}
+			if !reflect.DeepEqual(values, expected) {
+				t.Errorf("expected %v, got %v", expected, values)
+			}
+		})
+	}
+}
+
+func TestReadSettingsFile_TOMLEscapes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `target_path = "C:\\" # trailing backslash
+blocked_versions = ["a,b", 'c#d'] # comment
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	values, err := ReadSettingsFile(path)
+	if err != nil {
+		t.Fatalf("ReadSettingsFile failed: %v", err)
+	}
+	if values[SettingTargetPath] != `C:\` || values[SettingBlockedVersions] != "a,b,c#d" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestReadSettingsFile_Invalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{"config.yaml", "repository: x\n", `unknown setting "repository"`},
+		{"config.json", `{"channel": {"name": "beta"}}`, `invalid setting "channel"`},
+		{"config.toml", "[updater]\nchannel = \"beta\"\n", `unknown setting "updater"`},
+		{"config.toml", "channel = \"beta\nrepo_url = x\n", "line 1"},
+		{"config.toml", "channel = beta\n", `expected value but found "beta"`},
+		{"config.toml", "channel = \"a\"\nchannel = \"b\"\n", "Key 'channel' has already been defined"},
+		{"config.ini", "channel = beta\n", "unsupported config format"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name+" "+tc.expectedErr, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.name)
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			_, err := ReadSettingsFile(path)
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("expected error containing %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	userDir := t.TempDir()
+	exeDir := t.TempDir()
+	emptyDir := t.TempDir()
+	userConfig := filepath.Join(userDir, "config.toml")
+	exeConfig := filepath.Join(exeDir, "config.yaml")
+	if err := os.WriteFile(userConfig, []byte("channel = \"beta\"\ntarget_path = \"/opt/agent\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := os.WriteFile(exeConfig, []byte("repo_url: https://github.com/owner/repo\nchannel: stable\ntarget_path: /usr/bin/agent\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	// A JSON file in the same directory is ignored in favor of config.yaml
+	if err := os.WriteFile(filepath.Join(exeDir, "config.json"), []byte(`{"channel": "alpha"}`), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("UPDATER_TARGET_PATH", "/srv/agent")
+
+	files := FindSettingsFiles([]string{userDir, emptyDir, exeDir})
+	if !reflect.DeepEqual(files, []string{userConfig, exeConfig}) {
+		t.Fatalf("unexpected config files %v", files)
+	}
+	settings, err := LoadSettings(files)
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	settings.Set(SettingReleaseURLFormat, "https://example.com/{tag}", "flag --release-url-format")
+	settings.SetDefault(SettingRepoURL, "https://github.com/default/repo")
+	settings.SetDefault(SettingForceSemVerPrefix, "true")
+
+	expected := []Setting{
+		{SettingRepoURL, "https://github.com/owner/repo", exeConfig},
+		{SettingChannel, "beta", userConfig},
+		{SettingReleaseURLFormat, "https://example.com/{tag}", "flag --release-url-format"},
+		{SettingTargetPath, "/srv/agent", "env UPDATER_TARGET_PATH"},
+		{SettingForceSemVerPrefix, "true", "default"},
+	}
+	if got := settings.All(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected settings %v, got %v", expected, got)
+	}
+}
+
+func TestSettings_Apply(t *testing.T) {
+	var settings Settings
+	settings.Set(SettingRepoURL, "https://updates.example.com", "test")
+	settings.Set(SettingPinnedVersion, "v1.6.2", "test")
+	settings.Set(SettingBlockedVersions, "v1.4.3, ,v1.4.4", "test")
+	settings.Set(SettingBlockListURL, "https://example.com/blocked.json", "test")
+	settings.Set(SettingForceSemVerPrefix, "true", "test")
+	settings.Set(SettingAllowDowngrade, "1", "test")
+	settings.Set(SettingS3Endpoint, "http://localhost:9000", "test")
+	settings.Set(SettingS3Region, "eu-west-1", "test")
+	settings.Set(SettingOCIUsername, "ci", "test")
+	settings.Set(SettingOCIPassword, "secret", "test")
+	settings.Set(SettingOCIPlainHTTP, "true", "test")
+
+	var config UpdateServiceConfig
+	if err := settings.Apply(&config); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	expected := UpdateServiceConfig{
+		RepoURL:           "https://updates.example.com",
+		PinnedVersion:     "v1.6.2",
+		BlockedVersions:   []string{"v1.4.3", "v1.4.4"},
+		BlockListURL:      "https://example.com/blocked.json",
+		ForceSemVerPrefix: true,
+		AllowDowngrade:    true,
+		S3:                S3Config{Endpoint: "http://localhost:9000", Region: "eu-west-1"},
+		OCI:               OCIConfig{Username: "ci", Password: "secret", PlainHTTP: true},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %+v, got %+v", expected, config)
+	}
+
+	settings.Set(SettingAllowDowngrade, "sometimes", "env UPDATER_ALLOW_DOWNGRADE")
+	if err := settings.Apply(&config); err == nil || !strings.Contains(err.Error(), "env UPDATER_ALLOW_DOWNGRADE") {
+		t.Errorf("expected an error naming the source, got %v", err)
+	}
+
+	var interval Settings
+	interval.Set(SettingCheckInterval, "6h", "/etc/updater/config.yaml")
+	if err := interval.Apply(&config); err != nil || config.CheckInterval != 6*time.Hour {
+		t.Errorf("expected a check interval of 6h, got %s, error %v", config.CheckInterval, err)
+	}
+	interval.Set(SettingCheckInterval, "daily", "/etc/updater/config.yaml")
+	if err := interval.Apply(&config); err == nil || !strings.Contains(err.Error(), `invalid check_interval "daily" from /etc/updater/config.yaml`) {
+		t.Errorf("expected an invalid check_interval to be reported, got %v", err)
+	}
+}
+
+func TestSettings_ApplyPublicKey(t *testing.T) {
+	public, _, _ := ed25519.GenerateKey(nil)
+	var settings Settings
+	var config UpdateServiceConfig
+	settings.Set(SettingPublicKey, base64.StdEncoding.EncodeToString(public), "test")
+	if err := settings.Apply(&config); err != nil || !config.PublicKey.Equal(public) {
+		t.Errorf("expected the public key to be applied, got %v, error %v", config.PublicKey, err)
+	}
+	settings.Set(SettingPublicKey, filepath.Join(t.TempDir(), "missing.pub"), "env UPDATER_PUBLIC_KEY")
+	if err := settings.Apply(&config); err == nil || !strings.Contains(err.Error(), "env UPDATER_PUBLIC_KEY") {
+		t.Errorf("expected an error naming the source, got %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// ErrInvalidStagedUpdate is returned when a staged update does not match the
+// checksum or version recorded when it was staged. The staged files are
+// removed, so the update is downloaded again on the next check.
+var ErrInvalidStagedUpdate = errors.New("invalid staged update")
+
+const (
+	stagedManifestName = "staged.json"
+	stagedBinaryName   = "update"
+)
+
+// StagedUpdate describes an update that has been downloaded and verified, and
+// is waiting to be applied by ApplyStagedUpdate on the next start.
+type StagedUpdate struct {
+	// Version is the version of the staged binary.
+	Version string `json:"version"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the staged binary.
+	SHA256 string `json:"sha256"`
+	// From is the version the update was staged over. A staged version older
+	// than From is a downgrade, applied only while From is still running.
+	From string `json:"from,omitempty"`
+	// StagedAt is when the update was staged.
+	StagedAt time.Time `json:"staged_at"`
+}
+
+// DefaultStagingDir returns the staging area for updates of the binary at
+// target, in the user cache directory.
+func DefaultStagingDir(target string) (string, error) {
+	key, err := installationKey(target)
+	if err != nil {
+		return "", err
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "updater", key+"-staged"), nil
+}
+
+// StageUpdate downloads the update from current to version from url into the
+// staging directory dir, replacing anything staged before, and records its
+// checksum. The manifest is written last, so an interrupted download is never
+// applied. Release archives are unpacked, staging the binary named like the
+// running executable. The download itself is not verified against a published
+// checksum; UpdateService verifies the updates it stages.
+func StageUpdate(dir, version, current, url string) (*StagedUpdate, error) {
+	return stageDownload(DownloadUpdate, dir, version, current, url, assetChecksum{}, executableName())
+}
+
+// stageDownload is StageUpdate for a download with download verified against
+// sum, extracting the binary named binary from release archives.
+func stageDownload(download downloadFunc, dir, version, current, url string, sum assetChecksum, binary string) (*StagedUpdate, error) {
+	if err := ClearStagedUpdate(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	path := filepath.Join(dir, stagedBinaryName)
+	if err := downloadVerified(download, url, path, sum, binary); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	checksum, err := fileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+
+	staged := &StagedUpdate{Version: version, SHA256: checksum, From: current, StagedAt: timeNow()}
+	data, err := json.MarshalIndent(staged, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(dir, stagedManifestName+".tmp")
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write staging manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, stagedManifestName)); err != nil {
+		return nil, fmt.Errorf("failed to write staging manifest: %w", err)
+	}
+	return staged, nil
+}
+
+// LoadStagedUpdate returns the update staged in dir, or nil if there is none.
+func LoadStagedUpdate(dir string) (*StagedUpdate, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stagedManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staging manifest: %w", err)
+	}
+
+	var staged StagedUpdate
+	if err := json.Unmarshal(data, &staged); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse staging manifest: %v", ErrInvalidStagedUpdate, err)
+	}
+	return &staged, nil
+}
+
+// ClearStagedUpdate removes any update staged in dir. Only the files written by
+// StageUpdate are removed, so dir may be shared with other files; the
+// directory itself is removed only if nothing else is left in it.
+func ClearStagedUpdate(dir string) error {
+	for _, name := range []string{stagedManifestName, stagedManifestName + ".tmp", stagedBinaryName} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to clear staged update: %w", err)
+		}
+	}
+	os.Remove(dir) // fails if the directory holds other files
+	return nil
+}
+
+// ApplyStagedUpdate applies an update staged for the running executable, and
+// reports whether one was applied. It is meant to be called at the very
+// beginning of main, before the application does anything else, so that the
+// binary is never replaced mid-session:
+//
+//	func main() {
+//		if applied, err := updater.ApplyStagedUpdate(); err != nil {
+//			log.Printf("failed to apply staged update: %v", err)
+//		} else if applied {
+//			// The new binary takes effect on the next start; re-exec here if needed.
+//		}
+//		...
+//	}
+//
+// The staged binary must match the checksum recorded when it was staged, and
+// its version must be newer than the running version, or be a downgrade staged
+// over the running version; otherwise it is discarded, so a stale update never
+// downgrades a binary upgraded since. Like UpdateService with a zero
+// LockTimeout, it waits DefaultLockTimeout for another process holding the
+// update lock.
+var ApplyStagedUpdate = func() (bool, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("failed to locate executable: %w", err)
+	}
+	dir, err := DefaultStagingDir(exe)
+	if err != nil {
+		return false, err
+	}
+	lockPath, err := DefaultLockPath(exe)
+	if err != nil {
+		return false, err
+	}
+	return applyStagedUpdateFrom(os.Stdout, dir, Version, applyToExecutable, func() (*UpdateLock, error) {
+		return AcquireUpdateLock(lockPath, DefaultLockTimeout)
+	})
+}
+
+// applyStagedUpdateFrom verifies the update staged in dir and applies it over
+// the current version with apply while holding the update lock taken by
+// acquireLock, printing its progress to w. The staging area is cleared once
+// the update is applied, and invalid staged updates are discarded.
+func applyStagedUpdateFrom(w io.Writer, dir, current string, apply func(path string) error, acquireLock func() (*UpdateLock, error)) (bool, error) {
+	staged, err := LoadStagedUpdate(dir)
+	if staged == nil {
+		if errors.Is(err, ErrInvalidStagedUpdate) {
+			ClearStagedUpdate(dir)
+		}
+		return false, err
+	}
+
+	lock, err := acquireLock()
+	if err != nil {
+		return false, err
+	}
+	defer lock.Release()
+
+	if !stagedUpdateApplies(staged, current) {
+		// Already running the staged version or a newer one, e.g. after
+		// another process applied it or the binary was upgraded manually.
+		return false, ClearStagedUpdate(dir)
+	}
+
+	binary := filepath.Join(dir, stagedBinaryName)
+	sum, err := fileSHA256(binary)
+	if err != nil || sum != staged.SHA256 {
+		ClearStagedUpdate(dir)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrInvalidStagedUpdate, err)
+		}
+		return false, fmt.Errorf("%w: checksum of %s does not match the staged checksum", ErrInvalidStagedUpdate, staged.Version)
+	}
+
+	fmt.Fprintf(w, "Applying staged update %s (current: %s)...\n", staged.Version, current)
+	if err := apply(binary); err != nil {
+		return false, err
+	}
+	return true, ClearStagedUpdate(dir)
+}
+
+// stagedUpdateApplies reports whether staged is still an update for current:
+// a newer version, or a downgrade staged over current itself.
+func stagedUpdateApplies(staged *StagedUpdate, current string) bool {
+	switch semver.Compare(canonicalVersion(staged.Version), canonicalVersion(current)) {
+	case 1:
+		return true
+	case -1:
+		return staged.From != "" && canonicalVersion(staged.From) == canonicalVersion(current)
+	}
+	return false
+}
+
+// applyToExecutable replaces the running executable with the update at path.
+func applyToExecutable(path string) error {
+	if err := ApplyUpdateFile(path, ""); err != nil {
+		return err
+	}
+	fmt.Println("Update applied successfully.")
+	return nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 checksum of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stagingDir returns the configured staging directory, or the default staging
+// area of the target binary.
+func (s *UpdateService) stagingDir() (string, error) {
+	if s.config.StagingDir != "" {
+		return s.config.StagingDir, nil
+	}
+	target, err := s.TargetPath()
+	if err != nil {
+		return "", err
+	}
+	return DefaultStagingDir(target)
+}
+
+// StagedUpdate returns the update waiting to be applied on the next start, or
+// nil if there is none.
+func (s *UpdateService) StagedUpdate() (*StagedUpdate, error) {
+	dir, err := s.stagingDir()
+	if err != nil {
+		return nil, err
+	}
+	return LoadStagedUpdate(dir)
+}
+
+// ApplyStagedUpdate is like the package-level ApplyStagedUpdate, but uses the
+// service's StagingDir, TargetPath, Elevate hook and update lock.
+func (s *UpdateService) ApplyStagedUpdate() (bool, error) {
+	dir, err := s.stagingDir()
+	if err != nil {
+		return false, err
+	}
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return false, err
+	}
+	return applyStagedUpdateFrom(s.out(), dir, current, s.applyFile, s.acquireLock)
+}
+
+// stageUpdate downloads the update from current to version from url into the
+// staging area, verified against sum, to be applied by ApplyStagedUpdate on
+// the next start.
+func (s *UpdateService) stageUpdate(version, current, url string, sum assetChecksum) error {
+	dir, err := s.stagingDir()
+	if err != nil {
+		return err
+	}
+
+	display := formatVersionForDisplay(version, s.config.ForceSemVerPrefix)
+	if staged, err := LoadStagedUpdate(dir); err == nil && staged != nil &&
+		canonicalVersion(staged.Version) == canonicalVersion(version) &&
+		canonicalVersion(staged.From) == canonicalVersion(current) {
+		fmt.Fprintf(s.out(), "Update %s is already staged; it will be applied on the next start.\n", display)
+		s.record(UpdateStaged, version, current)
+		return nil
+	}
+
+	s.announce(version, current, "Staging...")
+
+	if _, err := stageDownload(s.downloader(), dir, version, current, url, sum, s.binaryName()); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out(), "Update %s staged; it will be applied on the next start.\n", display)
+	s.record(UpdateStaged, version, current)
+	return nil
+}
@@ -0,0 +1,248 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mockStagingIO replaces DownloadUpdate with one that writes content, and
+// ApplyUpdateFile with one that records what was applied.
+func mockStagingIO(t *testing.T, content string) *[]string {
+	t.Helper()
+	originalDownloadUpdate := DownloadUpdate
+	originalApplyUpdateFile := ApplyUpdateFile
+	t.Cleanup(func() {
+		DownloadUpdate = originalDownloadUpdate
+		ApplyUpdateFile = originalApplyUpdateFile
+	})
+
+	var applied []string
+	DownloadUpdate = func(url, path string) error {
+		return os.WriteFile(path, []byte(content), 0644)
+	}
+	ApplyUpdateFile = func(path, target string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		applied = append(applied, string(data))
+		return nil
+	}
+	return &applied
+}
+
+func TestApplyStagedUpdate(t *testing.T) {
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "1.2.0"
+
+	lockPath := filepath.Join(t.TempDir(), "update.lock")
+	acquire := func() (*UpdateLock, error) { return AcquireUpdateLock(lockPath, 0) }
+
+	testCases := []struct {
+		name         string
+		stage        bool
+		version      string
+		from         string
+		tamper       func(dir string)
+		expectApply  bool
+		expectError  error
+		expectRemain bool
+	}{
+		{name: "nothing staged"},
+		{name: "valid", stage: true, version: "1.3.0", expectApply: true},
+		{name: "already running", stage: true, version: "v1.2.0"},
+		{name: "older than running", stage: true, version: "1.1.0"},
+		{name: "downgrade staged over running", stage: true, version: "1.1.0", from: "v1.2.0", expectApply: true},
+		{name: "downgrade staged over another version", stage: true, version: "1.1.0", from: "1.3.0"},
+		{
+			name: "checksum mismatch", stage: true, version: "1.3.0",
+			tamper: func(dir string) {
+				os.WriteFile(filepath.Join(dir, stagedBinaryName), []byte("tampered"), 0644)
+			},
+			expectError: ErrInvalidStagedUpdate,
+		},
+		{
+			name: "missing binary", stage: true, version: "1.3.0",
+			tamper: func(dir string) {
+				os.Remove(filepath.Join(dir, stagedBinaryName))
+			},
+			expectError: ErrInvalidStagedUpdate,
+		},
+		{
+			name: "corrupt manifest", stage: true, version: "1.3.0",
+			tamper: func(dir string) {
+				os.WriteFile(filepath.Join(dir, stagedManifestName), []byte("{"), 0644)
+			},
+			expectError: ErrInvalidStagedUpdate,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			applied := mockStagingIO(t, "new binary")
+			dir := filepath.Join(t.TempDir(), "staged")
+
+			if tc.stage {
+				staged, err := StageUpdate(dir, tc.version, tc.from, "https://example.com/binary")
+				if err != nil {
+					t.Fatalf("StageUpdate failed: %v", err)
+				}
+				if staged.Version != tc.version || len(staged.SHA256) != 64 {
+					t.Fatalf("unexpected staged update %+v", staged)
+				}
+			}
+			if tc.tamper != nil {
+				tc.tamper(dir)
+			}
+
+			ok, err := applyStagedUpdateFrom(io.Discard, dir, Version, applyToExecutable, acquire)
+			if tc.expectError != nil {
+				if !errors.Is(err, tc.expectError) {
+					t.Fatalf("expected %v, got %v", tc.expectError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("applyStagedUpdateFrom failed: %v", err)
+			}
+			if ok != tc.expectApply || (len(*applied) == 1) != tc.expectApply {
+				t.Errorf("expected applied %v, got %v (%v)", tc.expectApply, ok, *applied)
+			}
+			if staged, _ := LoadStagedUpdate(dir); staged != nil {
+				t.Errorf("expected the staging area to be cleared, got %+v", staged)
+			}
+		})
+	}
+}
+
+func TestClearStagedUpdate_SharedDir(t *testing.T) {
+	mockStagingIO(t, "new binary")
+	dir := t.TempDir()
+	unrelated := filepath.Join(dir, "cache.db")
+	if err := os.WriteFile(unrelated, []byte("keep me"), 0644); err != nil {
+		t.Fatalf("failed to write unrelated file: %v", err)
+	}
+
+	if _, err := StageUpdate(dir, "1.3.0", "1.2.0", "https://example.com/binary"); err != nil {
+		t.Fatalf("StageUpdate failed: %v", err)
+	}
+	if _, err := StageUpdate(dir, "1.4.0", "1.2.0", "https://example.com/binary"); err != nil {
+		t.Fatalf("StageUpdate failed: %v", err)
+	}
+	if err := ClearStagedUpdate(dir); err != nil {
+		t.Fatalf("ClearStagedUpdate failed: %v", err)
+	}
+
+	if data, err := os.ReadFile(unrelated); err != nil || string(data) != "keep me" {
+		t.Errorf("expected the unrelated file to be kept, got %q, %v", data, err)
+	}
+	for _, name := range []string{stagedManifestName, stagedBinaryName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s to be removed, got %v", name, err)
+		}
+	}
+}
+
+func TestApplyStagedUpdate_Locked(t *testing.T) {
+	mockStagingIO(t, "new binary")
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+	Version = "1.2.0"
+
+	dir := filepath.Join(t.TempDir(), "staged")
+	if _, err := StageUpdate(dir, "1.3.0", "1.2.0", "https://example.com/binary"); err != nil {
+		t.Fatalf("StageUpdate failed: %v", err)
+	}
+
+	lockPath := filepath.Join(t.TempDir(), "update.lock")
+	held, err := AcquireUpdateLock(lockPath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+
+	_, err = applyStagedUpdateFrom(io.Discard, dir, Version, applyToExecutable, func() (*UpdateLock, error) { return AcquireUpdateLock(lockPath, 0) })
+	if !errors.Is(err, ErrUpdateLocked) {
+		t.Errorf("expected ErrUpdateLocked, got %v", err)
+	}
+	if staged, _ := LoadStagedUpdate(dir); staged == nil {
+		t.Errorf("expected the staged update to be kept while locked")
+	}
+}
+
+func TestUpdateService_StageUpdates(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprintf(w, `{"version": "1.3.0", "url": "http://%s/binary"}`, r.Host)
+		case "/binary":
+			downloads++
+			fmt.Fprint(w, "new binary")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalVersion := Version
+	defer func() {
+		ApplyUpdateFile = originalApplyUpdateFile
+		Version = originalVersion
+	}()
+
+	var applied []string
+	ApplyUpdateFile = func(path, target string) error {
+		data, _ := os.ReadFile(path)
+		applied = append(applied, string(data))
+		return nil
+	}
+	Version = "1.2.0"
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        server.URL,
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		LockPath:       filepath.Join(t.TempDir(), "update.lock"),
+		StageUpdates:   true,
+		StagingDir:     filepath.Join(t.TempDir(), "staged"),
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("expected the update to be staged, not applied")
+	}
+	staged, err := service.StagedUpdate()
+	if err != nil || staged == nil || staged.Version != "1.3.0" {
+		t.Fatalf("expected 1.3.0 to be staged, got %+v (%v)", staged, err)
+	}
+
+	// A later check finds the same update already staged, even if the running
+	// version is now reported with a 'v' prefix.
+	Version = "v1.2.0"
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if downloads != 1 {
+		t.Errorf("expected the staged update not to be downloaded again, got %d downloads", downloads)
+	}
+
+	// On the next start, the staged update is applied before anything else.
+	ok, err := service.ApplyStagedUpdate()
+	if err != nil || !ok {
+		t.Fatalf("ApplyStagedUpdate failed: %v (applied %v)", err, ok)
+	}
+	if len(applied) != 1 || applied[0] != "new binary" {
+		t.Errorf("expected the staged binary to be applied, got %v", applied)
+	}
+}
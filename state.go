@@ -0,0 +1,118 @@
+package updater
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// State is the updater's persistent, per-installation state. It is stored as
+// JSON, by default in the user configuration directory.
+type State struct {
+	// InstallID is a random identifier for this installation, used to place
+	// it in a stable bucket for staged rollouts.
+	InstallID string `json:"install_id,omitempty"`
+	// Channel is the release channel chosen by the user. If empty, the
+	// channel is determined from the running version.
+	Channel string `json:"channel,omitempty"`
+	// SkippedVersions are versions the user chose not to be offered again by
+	// PromptOnStartup.
+	SkippedVersions []string `json:"skipped_versions,omitempty"`
+}
+
+// LoadState reads the state file at path. A missing file yields an empty state.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Save writes the state to path, creating its directory if needed. The file is
+// replaced atomically so that a crash never leaves a truncated state behind.
+func (st *State) Save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+// DefaultStatePath returns the state file for the installation of the binary at
+// target, in the user configuration directory.
+func DefaultStatePath(target string) (string, error) {
+	key, err := installationKey(target)
+	if err != nil {
+		return "", err
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine config directory: %w", err)
+	}
+	return filepath.Join(configDir, "updater", key+".json"), nil
+}
+
+// executableStatePath returns the default state file of the running executable.
+func executableStatePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate executable: %w", err)
+	}
+	return DefaultStatePath(exe)
+}
+
+// loadOrCreateInstallID returns the installation ID stored in the state file at
+// path, generating and saving a new one if there is none yet.
+func loadOrCreateInstallID(path string) (string, error) {
+	state, err := LoadState(path)
+	if err != nil {
+		return "", err
+	}
+	if state.InstallID != "" {
+		return state.InstallID, nil
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate install ID: %w", err)
+	}
+	state.InstallID = hex.EncodeToString(id)
+	if err := state.Save(path); err != nil {
+		return "", err
+	}
+	return state.InstallID, nil
+}
@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadState_Missing(t *testing.T) {
+	state, err := LoadState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if state.InstallID != "" {
+		t.Errorf("expected an empty state, got %+v", state)
+	}
+}
+
+func TestState_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+	if err := (&State{InstallID: "abc"}).Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if state.InstallID != "abc" {
+		t.Errorf("expected install ID abc, got %q", state.InstallID)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the state file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestLoadState_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadState(path); err == nil {
+		t.Errorf("expected an error for invalid state")
+	}
+}
+
+func TestLoadOrCreateInstallID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	id, err := loadOrCreateInstallID(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateInstallID failed: %v", err)
+	}
+	if len(id) != 32 {
+		t.Errorf("expected a 32 character ID, got %q", id)
+	}
+
+	again, err := loadOrCreateInstallID(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateInstallID failed: %v", err)
+	}
+	if again != id {
+		t.Errorf("expected the install ID to persist, got %q then %q", id, again)
+	}
+}
+
+func TestDefaultStatePath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	path, err := DefaultStatePath("/opt/tools/myapp")
+	if err != nil {
+		t.Fatalf("DefaultStatePath failed: %v", err)
+	}
+	if !strings.HasPrefix(filepath.Base(path), "myapp-") || filepath.Ext(path) != ".json" {
+		t.Errorf("unexpected state file name: %s", path)
+	}
+}
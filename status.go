@@ -0,0 +1,101 @@
+package updater
+
+// UpdateStatus describes the update available for the target binary, as
+// determined by the service's source and release policy.
+type UpdateStatus struct {
+	// Current is the installed version.
+	Current string
+	// Channel is the channel the installation tracks.
+	Channel string
+	// Latest is the newest release offered to the installation, or empty if
+	// the source offers none.
+	Latest string
+	// UpdateAvailable reports whether Latest would be installed.
+	UpdateAvailable bool
+	// Required reports whether Current is below the minimum supported version
+	// declared by Latest.
+	Required bool
+	// Pending is a newer release that is not yet rolled out to this
+	// installation, if any.
+	Pending string
+	// AssetURL is the download URL of Latest for this platform, if an update
+	// is available.
+	AssetURL string
+
+	// checksum is what the download from AssetURL is verified against.
+	checksum assetChecksum
+}
+
+// Status determines the update available for the target binary without
+// printing anything or applying it. It applies the same channel, constraint,
+// block list and rollout rules as Start.
+func (s *UpdateService) Status() (*UpdateStatus, error) {
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+	channel, err := s.channelFor(current)
+	if err != nil {
+		return nil, err
+	}
+	status := &UpdateStatus{Current: current, Channel: channel}
+
+	if s.listsReleases() {
+		release, updateAvailable, pending, err := s.resolveRelease(current, channel)
+		if err != nil {
+			return nil, err
+		}
+		if pending != nil {
+			status.Pending = pending.TagName
+		}
+		if release == nil {
+			return status, nil
+		}
+		status.Latest = release.TagName
+		status.UpdateAvailable = updateAvailable
+		if updateAvailable {
+			minVersion, err := releaseMinimumVersion(release)
+			if err != nil {
+				return nil, err
+			}
+			status.Required = checkMinimumVersion(current, minVersion, release.TagName) != nil
+			if status.AssetURL, err = GetDownloadURL(release, s.config.ReleaseURLFormat); err != nil {
+				return nil, err
+			}
+			status.checksum = releaseChecksum(release, status.AssetURL)
+		}
+		return status, nil
+	}
+
+	info, err := GetLatestUpdateFromURL(s.config.RepoURL)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := s.blockedVersions()
+	if err != nil {
+		return nil, err
+	}
+	if blocked.has(info.Version) || (s.constraint != nil && !s.constraint.Check(info.Version)) {
+		return status, nil
+	}
+
+	status.Latest = info.Version
+	if !s.shouldMoveTo(info.Version, current, blocked) {
+		return status, nil
+	}
+	status.Required = checkMinimumVersion(current, info.MinVersion, info.Version) != nil
+	if !status.Required {
+		held, err := checkRollout(info.Version, info.rolloutPercent(), s.installID)
+		if err != nil {
+			return nil, err
+		}
+		if held {
+			status.Pending = info.Version
+			return status, nil
+		}
+	}
+	status.UpdateAvailable = true
+	status.AssetURL = info.URL
+	status.checksum = info.checksum()
+	return status, nil
+}
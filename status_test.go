@@ -0,0 +1,107 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestUpdateService_Status(t *testing.T) {
+	var latest string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest.json" {
+			fmt.Fprintln(w, latest)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	originalVersion := Version
+	originalNewGithubClient := NewGithubClient
+	defer func() {
+		Version = originalVersion
+		NewGithubClient = originalNewGithubClient
+	}()
+	Version = "1.1.0"
+
+	asset := fmt.Sprintf("agent_%s_%s", runtime.GOOS, runtime.GOARCH)
+	NewGithubClient = func() GithubClient {
+		return &MockGithubClient{
+			ListReleasesFunc: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{
+					{TagName: "v1.3.0-beta.1", PreRelease: true, Assets: []ReleaseAsset{{Name: asset, DownloadURL: "https://example.com/beta"}}},
+					{TagName: "v1.2.0", Assets: []ReleaseAsset{{Name: asset, DownloadURL: "https://example.com/stable"}}},
+				}, nil
+			},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		config   UpdateServiceConfig
+		latest   string
+		expected UpdateStatus
+	}{
+		{
+			name:     "HTTP up to date",
+			latest:   `{"version": "1.1.0", "url": "https://example.com/update"}`,
+			expected: UpdateStatus{Current: "1.1.0", Channel: "stable", Latest: "1.1.0"},
+		},
+		{
+			name:     "HTTP update available",
+			latest:   `{"version": "1.2.0", "url": "https://example.com/update"}`,
+			expected: UpdateStatus{Current: "1.1.0", Channel: "stable", Latest: "1.2.0", UpdateAvailable: true, AssetURL: "https://example.com/update"},
+		},
+		{
+			name:     "HTTP update required",
+			latest:   `{"version": "1.3.0", "url": "https://example.com/update", "min_version": "1.2.0", "rollout": 0}`,
+			expected: UpdateStatus{Current: "1.1.0", Channel: "stable", Latest: "1.3.0", UpdateAvailable: true, Required: true, AssetURL: "https://example.com/update"},
+		},
+		{
+			name:     "HTTP pending rollout",
+			latest:   `{"version": "1.2.0", "url": "https://example.com/update", "rollout": 0}`,
+			expected: UpdateStatus{Current: "1.1.0", Channel: "stable", Latest: "1.2.0", Pending: "1.2.0"},
+		},
+		{
+			name:     "HTTP blocked",
+			config:   UpdateServiceConfig{BlockedVersions: []string{"1.2.0"}},
+			latest:   `{"version": "1.2.0", "url": "https://example.com/update"}`,
+			expected: UpdateStatus{Current: "1.1.0", Channel: "stable"},
+		},
+		{
+			name:     "GitHub stable",
+			config:   UpdateServiceConfig{RepoURL: "https://github.com/owner/repo"},
+			expected: UpdateStatus{Current: "1.1.0", Channel: "stable", Latest: "v1.2.0", UpdateAvailable: true, AssetURL: "https://example.com/stable"},
+		},
+		{
+			name:     "GitHub beta",
+			config:   UpdateServiceConfig{RepoURL: "https://github.com/owner/repo", Channel: "beta"},
+			expected: UpdateStatus{Current: "1.1.0", Channel: "beta", Latest: "v1.3.0-beta.1", UpdateAvailable: true, AssetURL: "https://example.com/beta"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			latest = tc.latest
+			if tc.config.RepoURL == "" {
+				tc.config.RepoURL = server.URL
+			}
+			tc.config.StatePath = filepath.Join(t.TempDir(), "state.json")
+			service, err := NewUpdateService(tc.config)
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+			status, err := service.Status()
+			if err != nil {
+				t.Fatalf("Status failed: %v", err)
+			}
+			if *status != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, *status)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// versionDetectTimeout bounds how long DetectVersion waits for a binary to
+// print its version.
+const versionDetectTimeout = 10 * time.Second
+
+// versionPattern matches a semantic version, with or without a 'v' prefix.
+var versionPattern = regexp.MustCompile(`v?\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?`)
+
+// DetectVersion is a variable that holds the function to determine the version
+// of a binary that is not the running executable. By default it runs the
+// binary with --version and takes the first semantic version in its output,
+// e.g. "myagent version v1.4.2". This can be replaced to support other
+// conventions, or in tests.
+var DetectVersion = func(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionDetectTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect version of %s: %w", path, err)
+	}
+	version := versionPattern.Find(out)
+	if version == nil {
+		return "", fmt.Errorf("failed to detect version of %s: no version in output %q", path, out)
+	}
+	return string(version), nil
+}
+
+// TargetPath returns the binary the service updates: the configured TargetPath,
+// or the running executable.
+func (s *UpdateService) TargetPath() (string, error) {
+	if s.config.TargetPath != "" {
+		return s.config.TargetPath, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate executable: %w", err)
+	}
+	return exe, nil
+}
+
+// binaryName returns the name of the target binary without .exe, to find it in
+// release archives.
+func (s *UpdateService) binaryName() string {
+	target, err := s.TargetPath()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.Base(target), ".exe")
+}
+
+// executableName returns the name of the running executable without .exe, or
+// "" if it cannot be located.
+func executableName() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(filepath.Base(exe), ".exe")
+}
+
+// CurrentVersion returns the version of the binary the service updates: the
+// running Version, or the version DetectVersion reports for TargetPath. Once a
+// pending update has replaced the running executable, it is the version of
+// that update.
+func (s *UpdateService) CurrentVersion() (string, error) {
+	if s.config.TargetPath == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.replaced != "" {
+			return s.replaced, nil
+		}
+		return Version, nil
+	}
+	return DetectVersion(s.config.TargetPath)
+}
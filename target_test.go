@@ -0,0 +1,142 @@
+package updater
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetectVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not executable on Windows")
+	}
+
+	testCases := []struct {
+		name        string
+		script      string
+		expected    string
+		expectError bool
+	}{
+		{"plain", "echo 1.4.2", "1.4.2", false},
+		{"with name", "echo 'agent version v1.4.2-rc.1 (linux/amd64)'", "v1.4.2-rc.1", false},
+		{"no version", "echo 'agent'", "", true},
+		{"failure", "exit 3", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent")
+			if err := os.WriteFile(path, []byte("#!/bin/sh\n"+tc.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			version, err := DetectVersion(path)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error %v, got %v", tc.expectError, err)
+			}
+			if version != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, version)
+			}
+		})
+	}
+}
+
+func TestApplyBinary_PreservesMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not preserved on Windows")
+	}
+
+	target := filepath.Join(t.TempDir(), "agent")
+	if err := os.WriteFile(target, []byte("old"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyBinary(strings.NewReader("new"), target); err != nil {
+		t.Fatalf("applyBinary failed: %v", err)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil || string(data) != "new" {
+		t.Errorf("expected the target to be replaced, got %q (%v)", data, err)
+	}
+	info, err := os.Stat(target)
+	if err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("expected mode 0700 to be kept, got %v (%v)", info.Mode().Perm(), err)
+	}
+}
+
+func TestUpdateService_TargetPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprintf(w, `{"version": "1.3.0", "url": "http://%s/agent"}`, r.Host)
+		case "/agent":
+			fmt.Fprint(w, "new agent")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalDetectVersion := DetectVersion
+	originalApplyUpdateFile := ApplyUpdateFile
+	originalDoUpdate := DoUpdate
+	defer func() {
+		DetectVersion = originalDetectVersion
+		ApplyUpdateFile = originalApplyUpdateFile
+		DoUpdate = originalDoUpdate
+	}()
+
+	target := filepath.Join(t.TempDir(), "agent")
+	DetectVersion = func(path string) (string, error) {
+		if path != target {
+			t.Errorf("unexpected version detection of %s", path)
+		}
+		return "1.2.0", nil
+	}
+	var applied string
+	ApplyUpdateFile = func(path, to string) error {
+		if to != target {
+			t.Errorf("expected the update to be applied to %s, got %q", target, to)
+		}
+		data, err := os.ReadFile(path)
+		applied = string(data)
+		return err
+	}
+	DoUpdate = func(url string) error {
+		t.Errorf("the running executable should not be updated")
+		return nil
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	service, err := NewUpdateService(UpdateServiceConfig{
+		RepoURL:        server.URL,
+		CheckOnStartup: CheckAndUpdateOnStartup,
+		TargetPath:     target,
+	})
+	if err != nil {
+		t.Fatalf("NewUpdateService failed: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if applied != "new agent" {
+		t.Errorf("expected the downloaded agent to be applied, got %q", applied)
+	}
+
+	// The lock and state belong to the target, not the running executable.
+	exe, _ := os.Executable()
+	targetLock, _ := DefaultLockPath(target)
+	exeLock, _ := DefaultLockPath(exe)
+	if targetLock == exeLock {
+		t.Errorf("expected a separate lock for the target")
+	}
+	if path, _ := service.statePath(); !strings.HasPrefix(filepath.Base(path), "agent-") {
+		t.Errorf("expected the target's state file, got %s", path)
+	}
+}
@@ -1,10 +1,14 @@
 package updater
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/minio/selfupdate"
@@ -15,6 +19,15 @@ import (
 // It is set at build time via ldflags or fallback to the version in package.json.
 var Version = PkgVersion
 
+// ErrUpdateRequired is returned when the running version is below the minimum
+// supported version declared by the latest release. Applications can detect it
+// with errors.Is and refuse to continue until the update has been applied.
+var ErrUpdateRequired = errors.New("update required")
+
+// ErrDowngradeNotAllowed is returned when asked to install a version older than
+// the running version without allowing downgrades.
+var ErrDowngradeNotAllowed = errors.New("downgrade not allowed")
+
 // NewGithubClient is a variable that holds a function to create a new GithubClient.
 // This can be replaced in tests to inject a mock client.
 //
@@ -27,10 +40,15 @@ var NewGithubClient = func() GithubClient {
 	return &githubClient{}
 }
 
-// DoUpdate is a variable that holds the function to perform the actual update.
+// DoUpdate is a variable that holds the function to perform the actual update
+// of the running executable from url. The update functions, and an
+// UpdateService without Output or TargetPath that needs no elevation, apply
+// every update through it: an update with a published checksum or packed in a
+// release archive is first downloaded with DownloadUpdate, verified and
+// extracted, and DoUpdate receives the file:// URL of the verified binary.
 // This can be replaced in tests to prevent actual updates.
 var DoUpdate = func(url string) error {
-	resp, err := http.Get(url)
+	resp, err := clientFor(url).Get(url)
 	if err != nil {
 		return err
 	}
@@ -41,15 +59,108 @@ var DoUpdate = func(url string) error {
 		}
 	}(resp.Body)
 
-	err = selfupdate.Apply(resp.Body, selfupdate.Options{})
+	if err := applyBinary(resp.Body, ""); err != nil {
+		return err
+	}
+	fmt.Println("Update applied successfully.")
+	return nil
+}
+
+// DownloadUpdate is a variable that holds the function to download an update
+// from url to the file at path without applying it. This can be replaced in
+// tests to prevent network access.
+var DownloadUpdate = func(url, path string) error {
+	return downloadFile(clientFor(url), url, path)
+}
+
+// downloadFunc downloads an update from url to the file at path, like
+// DownloadUpdate.
+type downloadFunc func(url, path string) error
+
+// downloadFile downloads url to the file at path with client.
+func downloadFile(client *http.Client, url, path string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download update: status code %d", resp.StatusCode)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create download file: %w", err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to download update: %w", err)
+	}
+	return f.Close()
+}
+
+// installUpdate applies the update at url to the running executable with
+// DoUpdate. An update with a published checksum or packed in a release archive
+// is downloaded, verified and extracted first, and applied from the file:// URL
+// of the verified binary.
+func installUpdate(url string, sum assetChecksum) error {
+	if sum == (assetChecksum{}) && archiveSuffix(assetName(url)) == "" {
+		return DoUpdate(url)
+	}
+
+	dir, err := os.MkdirTemp("", "updater-download-")
+	if err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "update")
+	if err := downloadVerified(DownloadUpdate, url, path, sum, executableName()); err != nil {
+		return err
+	}
+	verified, err := FileURL(path)
+	if err != nil {
+		return err
+	}
+	return DoUpdate(verified)
+}
+
+// ApplyUpdateFile is a variable that holds the function to replace the binary
+// at target with the downloaded binary at path. An empty target means the
+// running executable. This can be replaced in tests to prevent actual updates.
+var ApplyUpdateFile = func(path, target string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open downloaded update: %w", err)
+	}
+	defer f.Close()
+	return applyBinary(f, target)
+}
+
+// applyBinary replaces the binary at target, or the running executable if
+// target is empty, with update. The file mode of the replaced binary is kept.
+func applyBinary(update io.Reader, target string) error {
+	opts := selfupdate.Options{TargetPath: target}
+	path := target
+	if path == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate executable: %w", err)
+		}
+		path = exe
+	}
+	if info, err := os.Stat(path); err == nil {
+		opts.TargetMode = info.Mode().Perm()
+	}
+
+	err := selfupdate.Apply(update, opts)
 	if err != nil {
 		if rerr := selfupdate.RollbackError(err); rerr != nil {
 			return fmt.Errorf("failed to rollback from failed update: %v", rerr)
 		}
 		return fmt.Errorf("update failed: %v", err)
 	}
-
-	fmt.Println("Update applied successfully.")
 	return nil
 }
 
@@ -87,7 +198,39 @@ var CheckForUpdates = func(owner, repo, channel string, forceSemVerPrefix bool,
 	if err != nil {
 		return err
 	}
+	if updateAvailable {
+		if held, err := releaseHeldBack(os.Stdout, release, forceSemVerPrefix, InstallID); err != nil || held {
+			return err
+		}
+	}
+	return updateToNewerVersion(release, updateAvailable, forceSemVerPrefix, releaseURLFormat)
+}
 
+// CheckOnly checks for new updates on GitHub without applying them.
+// It prints a message indicating if a new release is available. If the new
+// release declares a minimum supported version above the running version, an
+// error wrapping ErrUpdateRequired is returned.
+var CheckOnly = func(owner, repo, channel string, forceSemVerPrefix bool, releaseURLFormat string) error {
+	release, updateAvailable, err := CheckForNewerVersion(owner, repo, channel, forceSemVerPrefix)
+	if err != nil {
+		return err
+	}
+	if updateAvailable {
+		if held, err := releaseHeldBack(os.Stdout, release, forceSemVerPrefix, InstallID); err != nil || held {
+			return err
+		}
+	}
+	reportNewerVersion(os.Stdout, release, Version, updateAvailable, forceSemVerPrefix)
+	if updateAvailable {
+		return checkReleaseMinimumVersion(Version, release)
+	}
+	return nil
+}
+
+// updateToNewerVersion applies release if updateAvailable is set, printing the
+// outcome of the check. A release older than the running version is applied as
+// a downgrade.
+func updateToNewerVersion(release *Release, updateAvailable, forceSemVerPrefix bool, releaseURLFormat string) error {
 	if !updateAvailable {
 		if release != nil {
 			fmt.Printf("Current version %s is up-to-date with latest release %s.\n",
@@ -98,55 +241,132 @@ var CheckForUpdates = func(owner, repo, channel string, forceSemVerPrefix bool,
 		}
 		return nil
 	}
+	if err := CheckInstallation(""); err != nil {
+		return err
+	}
 
-	fmt.Printf("Newer version %s found (current: %s). Applying update...\n",
-		formatVersionForDisplay(release.TagName, forceSemVerPrefix),
-		formatVersionForDisplay(Version, forceSemVerPrefix))
+	if isNewerVersion(release.TagName) {
+		fmt.Printf("Newer version %s found (current: %s). Applying update...\n",
+			formatVersionForDisplay(release.TagName, forceSemVerPrefix),
+			formatVersionForDisplay(Version, forceSemVerPrefix))
+	} else {
+		fmt.Printf("Version %s found (current: %s). Applying downgrade...\n",
+			formatVersionForDisplay(release.TagName, forceSemVerPrefix),
+			formatVersionForDisplay(Version, forceSemVerPrefix))
+	}
 
 	downloadURL, err := GetDownloadURL(release, releaseURLFormat)
 	if err != nil {
 		return fmt.Errorf("error getting download URL: %w", err)
 	}
 
-	return DoUpdate(downloadURL)
+	return installUpdate(downloadURL, releaseChecksum(release, downloadURL))
 }
 
-// CheckOnly checks for new updates on GitHub without applying them.
-// It prints a message indicating if a new release is available.
-var CheckOnly = func(owner, repo, channel string, forceSemVerPrefix bool, releaseURLFormat string) error {
-	release, updateAvailable, err := CheckForNewerVersion(owner, repo, channel, forceSemVerPrefix)
-	if err != nil {
-		return err
-	}
-
+// reportNewerVersion prints to w whether release is available as an update of
+// the current version.
+func reportNewerVersion(w io.Writer, release *Release, current string, updateAvailable, forceSemVerPrefix bool) {
 	if !updateAvailable {
 		if release != nil {
-			fmt.Printf("Current version %s is up-to-date with latest release %s.\n",
-				formatVersionForDisplay(Version, forceSemVerPrefix),
+			fmt.Fprintf(w, "Current version %s is up-to-date with latest release %s.\n",
+				formatVersionForDisplay(current, forceSemVerPrefix),
 				formatVersionForDisplay(release.TagName, forceSemVerPrefix))
 		} else {
-			fmt.Println("No new release found.")
+			fmt.Fprintln(w, "No new release found.")
 		}
-		return nil
+		return
+	}
+
+	if isNewerThan(release.TagName, current) {
+		fmt.Fprintf(w, "New release found: %s (current version: %s)\n",
+			formatVersionForDisplay(release.TagName, forceSemVerPrefix),
+			formatVersionForDisplay(current, forceSemVerPrefix))
+	} else {
+		fmt.Fprintf(w, "Older release found: %s (current version: %s)\n",
+			formatVersionForDisplay(release.TagName, forceSemVerPrefix),
+			formatVersionForDisplay(current, forceSemVerPrefix))
+	}
+}
+
+// UpdateToVersion installs the GitHub release tagged with the given version,
+// regardless of channel. Installing a version older than the running version
+// fails with ErrDowngradeNotAllowed unless allowDowngrade is set, and releases
+// marked as blocked are refused.
+var UpdateToVersion = func(owner, repo, version string, allowDowngrade, forceSemVerPrefix bool, releaseURLFormat string) error {
+	client := NewGithubClient()
+	ctx := context.Background()
+
+	releases, err := listGithubReleases(ctx, client, owner, repo)
+	if err != nil {
+		return fmt.Errorf("error fetching releases: %w", err)
+	}
+
+	release := findReleaseByVersion(releases, version)
+	if release == nil {
+		return fmt.Errorf("release %s not found", version)
+	}
+	if isReleaseBlocked(release) {
+		return fmt.Errorf("release %s is blocked", release.TagName)
 	}
 
-	fmt.Printf("New release found: %s (current version: %s)\n",
-		formatVersionForDisplay(release.TagName, forceSemVerPrefix),
-		formatVersionForDisplay(Version, forceSemVerPrefix))
+	proceed, err := checkTargetVersion(os.Stdout, release.TagName, Version, allowDowngrade, forceSemVerPrefix)
+	if err != nil || !proceed {
+		return err
+	}
+	return updateToNewerVersion(release, true, forceSemVerPrefix, releaseURLFormat)
+}
+
+// findReleaseByVersion returns the release whose tag matches version, ignoring
+// any 'v' prefix.
+func findReleaseByVersion(releases []Release, version string) *Release {
+	for i := range releases {
+		if canonicalVersion(releases[i].TagName) == canonicalVersion(version) {
+			return &releases[i]
+		}
+	}
 	return nil
 }
 
+// checkTargetVersion reports whether the current version should be replaced by
+// target. It returns false if target is already installed, which is printed to
+// w, and an error wrapping ErrDowngradeNotAllowed if target is older and
+// downgrades are not allowed.
+func checkTargetVersion(w io.Writer, target, current string, allowDowngrade, forceSemVerPrefix bool) (bool, error) {
+	switch semver.Compare(formatVersionForComparison(current), formatVersionForComparison(target)) {
+	case 0:
+		fmt.Fprintf(w, "Current version %s is already %s.\n",
+			formatVersionForDisplay(current, forceSemVerPrefix),
+			formatVersionForDisplay(target, forceSemVerPrefix))
+		return false, nil
+	case 1:
+		if !allowDowngrade {
+			return false, fmt.Errorf("%w: %s is older than the current version %s",
+				ErrDowngradeNotAllowed,
+				formatVersionForDisplay(target, forceSemVerPrefix),
+				formatVersionForDisplay(current, forceSemVerPrefix))
+		}
+	}
+	return true, nil
+}
+
 // CheckForUpdatesByTag checks for and applies updates from GitHub based on the channel
-// determined by the current application's version tag (e.g., 'stable' or 'prerelease').
+// saved with SetChannel, or else the channel determined by the current application's
+// version tag (e.g., 'stable' or 'beta').
 var CheckForUpdatesByTag = func(owner, repo string) error {
-	channel := determineChannel(Version, false) // isPreRelease is false for current version
+	channel, err := CurrentChannel()
+	if err != nil {
+		return err
+	}
 	return CheckForUpdates(owner, repo, channel, true, "")
 }
 
-// CheckOnlyByTag checks for updates from GitHub based on the channel determined by the
-// current version tag, without applying them.
+// CheckOnlyByTag checks for updates from GitHub based on the saved channel, or the
+// channel determined by the current version tag, without applying them.
 var CheckOnlyByTag = func(owner, repo string) error {
-	channel := determineChannel(Version, false) // isPreRelease is false for current version
+	channel, err := CurrentChannel()
+	if err != nil {
+		return err
+	}
 	return CheckOnly(owner, repo, channel, true, "")
 }
 
@@ -166,6 +386,9 @@ var CheckForUpdatesByPullRequest = func(owner, repo string, prNumber int, releas
 		return nil
 	}
 
+	if err := CheckInstallation(""); err != nil {
+		return err
+	}
 	fmt.Printf("Release %s found for PR #%d. Applying update...\n", release.TagName, prNumber)
 
 	downloadURL, err := GetDownloadURL(release, releaseURLFormat)
@@ -173,47 +396,177 @@ var CheckForUpdatesByPullRequest = func(owner, repo string, prNumber int, releas
 		return fmt.Errorf("error getting download URL: %w", err)
 	}
 
-	return DoUpdate(downloadURL)
+	return installUpdate(downloadURL, releaseChecksum(release, downloadURL))
 }
 
 // CheckForUpdatesHTTP checks for and applies updates from a generic HTTP endpoint.
 // The endpoint is expected to provide update information in a structured format.
+// Versions listed in the endpoint's blocked.json are never installed.
 var CheckForUpdatesHTTP = func(baseURL string) error {
+	info, err := latestUnblockedUpdateFromURL(baseURL)
+	if err != nil || info == nil {
+		return err
+	}
+	updateAvailable := isNewerVersion(info.Version)
+	if updateAvailable {
+		if held, err := updateHeldBack(os.Stdout, info, Version, InstallID); err != nil || held {
+			return err
+		}
+	}
+	return updateToHTTPVersion(info, updateAvailable)
+}
+
+// CheckOnlyHTTP checks for updates from a generic HTTP endpoint without applying them.
+// It prints a message if a new version is available. If latest.json declares a
+// min_version above the running version, an error wrapping ErrUpdateRequired is
+// returned.
+var CheckOnlyHTTP = func(baseURL string) error {
+	info, err := latestUnblockedUpdateFromURL(baseURL)
+	if err != nil || info == nil {
+		return err
+	}
+	updateAvailable := isNewerVersion(info.Version)
+	if updateAvailable {
+		if held, err := updateHeldBack(os.Stdout, info, Version, InstallID); err != nil || held {
+			return err
+		}
+	}
+	reportHTTPVersion(os.Stdout, info, Version, updateAvailable)
+	if updateAvailable {
+		return checkMinimumVersion(Version, info.MinVersion, info.Version)
+	}
+	return nil
+}
+
+// latestUnblockedUpdateFromURL fetches latest.json from baseURL, returning nil
+// if the latest version is listed in the server's blocked.json.
+func latestUnblockedUpdateFromURL(baseURL string) (*GenericUpdateInfo, error) {
 	info, err := GetLatestUpdateFromURL(baseURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	vCurrent := formatVersionForComparison(Version)
-	vLatest := formatVersionForComparison(info.Version)
+	blockListURL, err := blockListURLFor(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	blocked := fetchImplicitBlockList(os.Stdout, blockListURL)
+	if newVersionSet(blocked.Versions...).has(info.Version) {
+		fmt.Printf("Latest release %s is blocked; skipping.\n", info.Version)
+		return nil, nil
+	}
+	return info, nil
+}
 
-	if semver.Compare(vCurrent, vLatest) >= 0 {
+// updateToHTTPVersion applies the update described by info if updateAvailable
+// is set, printing the outcome of the check.
+func updateToHTTPVersion(info *GenericUpdateInfo, updateAvailable bool) error {
+	if !updateAvailable {
 		fmt.Printf("Current version %s is up-to-date with latest release %s.\n", Version, info.Version)
 		return nil
 	}
+	if err := CheckInstallation(""); err != nil {
+		return err
+	}
 
-	fmt.Printf("Newer version %s found (current: %s). Applying update...\n", info.Version, Version)
-	return DoUpdate(info.URL)
+	if isNewerVersion(info.Version) {
+		fmt.Printf("Newer version %s found (current: %s). Applying update...\n", info.Version, Version)
+	} else {
+		fmt.Printf("Version %s found (current: %s). Applying downgrade...\n", info.Version, Version)
+	}
+	return installUpdate(info.URL, info.checksum())
 }
 
-// CheckOnlyHTTP checks for updates from a generic HTTP endpoint without applying them.
-// It prints a message if a new version is available.
-var CheckOnlyHTTP = func(baseURL string) error {
-	info, err := GetLatestUpdateFromURL(baseURL)
-	if err != nil {
-		return err
+// reportHTTPVersion prints to w whether the update described by info is
+// available for the current version.
+func reportHTTPVersion(w io.Writer, info *GenericUpdateInfo, current string, updateAvailable bool) {
+	if !updateAvailable {
+		fmt.Fprintf(w, "Current version %s is up-to-date with latest release %s.\n", current, info.Version)
+		return
 	}
 
-	vCurrent := formatVersionForComparison(Version)
-	vLatest := formatVersionForComparison(info.Version)
+	if isNewerThan(info.Version, current) {
+		fmt.Fprintf(w, "New release found: %s (current version: %s)\n", info.Version, current)
+	} else {
+		fmt.Fprintf(w, "Older release found: %s (current version: %s)\n", info.Version, current)
+	}
+}
 
-	if semver.Compare(vCurrent, vLatest) >= 0 {
-		fmt.Printf("Current version %s is up-to-date with latest release %s.\n", Version, info.Version)
+// checkMinimumVersion returns an error wrapping ErrUpdateRequired if the current
+// version is below minVersion. Empty or invalid minimum versions are ignored.
+func checkMinimumVersion(current, minVersion, latest string) error {
+	vMin := formatVersionForComparison(minVersion)
+	if !semver.IsValid(vMin) {
+		return nil
+	}
+	if semver.Compare(formatVersionForComparison(current), vMin) >= 0 {
 		return nil
 	}
+	return fmt.Errorf("%w: version %s is below the minimum supported version %s, update to %s",
+		ErrUpdateRequired, current, minVersion, latest)
+}
 
-	fmt.Printf("New release found: %s (current version: %s)\n", info.Version, Version)
-	return nil
+// MinVersionAssetName is the name of a release asset that declares the minimum
+// supported version of a GitHub release. The asset holds the version, e.g.
+// "1.2.0", on its first line. Alternatively, add an "updater:min-version=X.Y.Z"
+// directive to the release body, which takes precedence.
+const MinVersionAssetName = "MIN_VERSION"
+
+// releaseMinimumVersion returns the minimum supported version declared by a
+// release with an "updater:min-version=X.Y.Z" directive in its body or, failing
+// that, in a MIN_VERSION asset.
+func releaseMinimumVersion(release *Release) (string, error) {
+	if minVersion, ok := parseReleaseDirectives(release.Body)["min-version"]; ok {
+		return minVersion, nil
+	}
+	for _, asset := range release.Assets {
+		if strings.EqualFold(asset.Name, MinVersionAssetName) {
+			return fetchMinimumVersion(asset.DownloadURL)
+		}
+	}
+	return "", nil
+}
+
+// fetchMinimumVersion returns the version on the first line of the
+// MIN_VERSION asset at url.
+func fetchMinimumVersion(url string) (string, error) {
+	resp, err := clientFor(url).Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch minimum version: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch minimum version: status code %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 1024))
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("failed to read minimum version: %w", err)
+		}
+		return "", nil
+	}
+	return strings.TrimSpace(scanner.Text()), nil
+}
+
+// checkReleaseMinimumVersion is checkMinimumVersion for the minimum supported
+// version declared by release.
+func checkReleaseMinimumVersion(current string, release *Release) error {
+	minVersion, err := releaseMinimumVersion(release)
+	if err != nil {
+		return err
+	}
+	return checkMinimumVersion(current, minVersion, release.TagName)
+}
+
+// isNewerVersion reports whether version is newer than the running Version.
+func isNewerVersion(version string) bool {
+	return isNewerThan(version, Version)
+}
+
+// isNewerThan reports whether version is newer than current.
+func isNewerThan(version, current string) bool {
+	return semver.Compare(formatVersionForComparison(current), formatVersionForComparison(version)) < 0
 }
 
 // formatVersionForComparison ensures the version string has a 'v' prefix for semver comparison.
@@ -2,10 +2,13 @@ package updater
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"testing"
 )
 
 // mockGithubClient is a mock implementation of the GithubClient interface for testing.
@@ -13,9 +16,11 @@ type mockGithubClient struct {
 	getLatestRelease      func(ctx context.Context, owner, repo, channel string) (*Release, error)
 	getReleaseByPR        func(ctx context.Context, owner, repo string, prNumber int) (*Release, error)
 	getPublicRepos        func(ctx context.Context, userOrOrg string) ([]string, error)
+	listReleases          func(ctx context.Context, owner, repo string) ([]Release, error)
 	getLatestReleaseCount int
 	getReleaseByPRCount   int
 	getPublicReposCount   int
+	listReleasesCount     int
 }
 
 func (m *mockGithubClient) GetLatestRelease(ctx context.Context, owner, repo, channel string) (*Release, error) {
@@ -36,6 +41,14 @@ func (m *mockGithubClient) GetPublicRepos(ctx context.Context, userOrOrg string)
 	return nil, fmt.Errorf("GetPublicRepos not implemented")
 }
 
+func (m *mockGithubClient) ListReleases(ctx context.Context, owner, repo string) ([]Release, error) {
+	m.listReleasesCount++
+	if m.listReleases != nil {
+		return m.listReleases(ctx, owner, repo)
+	}
+	return nil, fmt.Errorf("ListReleases not implemented")
+}
+
 func ExampleCheckForNewerVersion() {
 	originalNewGithubClient := NewGithubClient
 	defer func() { NewGithubClient = originalNewGithubClient }()
@@ -258,3 +271,184 @@ func ExampleCheckOnlyHTTP() {
 	}
 	// Output: New release found: 1.1.0 (current version: 1.0.0)
 }
+
+func TestCheckOnly_MinimumVersion(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	defer func() { NewGithubClient = originalNewGithubClient }()
+	NewGithubClient = func() GithubClient {
+		return &mockGithubClient{
+			getLatestRelease: func(ctx context.Context, owner, repo, channel string) (*Release, error) {
+				return &Release{TagName: "v1.3.0", Body: "Security fix\n<!-- updater:min-version=1.2.0 -->"}, nil
+			},
+		}
+	}
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+
+	testCases := []struct {
+		version     string
+		expectError bool
+	}{
+		{version: "1.1.0", expectError: true},
+		{version: "1.2.0", expectError: false},
+		{version: "1.3.0", expectError: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			Version = tc.version
+			err := CheckOnly("owner", "repo", "stable", true, "")
+			if tc.expectError != errors.Is(err, ErrUpdateRequired) {
+				t.Errorf("Expected ErrUpdateRequired: %v, got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestCheckOnly_MinimumVersionAsset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1.3.0/MIN_VERSION" {
+			fmt.Fprintln(w, "1.2.0")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	originalNewGithubClient := NewGithubClient
+	defer func() { NewGithubClient = originalNewGithubClient }()
+	NewGithubClient = func() GithubClient {
+		return &mockGithubClient{
+			getLatestRelease: func(ctx context.Context, owner, repo, channel string) (*Release, error) {
+				return &Release{TagName: "v1.3.0", Assets: []ReleaseAsset{
+					{Name: "app-linux-amd64", DownloadURL: server.URL + "/v1.3.0/app-linux-amd64"},
+					{Name: MinVersionAssetName, DownloadURL: server.URL + "/v1.3.0/MIN_VERSION"},
+				}}, nil
+			},
+		}
+	}
+
+	originalVersion := Version
+	defer func() { Version = originalVersion }()
+
+	testCases := []struct {
+		version     string
+		expectError bool
+	}{
+		{version: "1.1.0", expectError: true},
+		{version: "1.2.0", expectError: false},
+		{version: "1.3.0", expectError: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			Version = tc.version
+			err := CheckOnly("owner", "repo", "stable", true, "")
+			if tc.expectError != errors.Is(err, ErrUpdateRequired) {
+				t.Errorf("Expected ErrUpdateRequired: %v, got: %v", tc.expectError, err)
+			}
+		})
+	}
+
+	t.Run("unreadable asset", func(t *testing.T) {
+		Version = "1.1.0"
+		release := &Release{TagName: "v1.3.0", Assets: []ReleaseAsset{{Name: MinVersionAssetName, DownloadURL: server.URL + "/missing"}}}
+		if err := checkReleaseMinimumVersion(Version, release); err == nil || errors.Is(err, ErrUpdateRequired) {
+			t.Errorf("expected the failed fetch to be reported, got %v", err)
+		}
+	})
+}
+
+func TestUpdateToVersion(t *testing.T) {
+	originalNewGithubClient := NewGithubClient
+	originalDoUpdate := DoUpdate
+	originalVersion := Version
+	defer func() {
+		NewGithubClient = originalNewGithubClient
+		DoUpdate = originalDoUpdate
+		Version = originalVersion
+	}()
+
+	NewGithubClient = func() GithubClient {
+		return &mockGithubClient{
+			listReleases: func(ctx context.Context, owner, repo string) ([]Release, error) {
+				return []Release{
+					{TagName: "v1.3.0-beta.2", PreRelease: true, Assets: []ReleaseAsset{{Name: "app-linux-amd64", DownloadURL: "http://example.com/beta"}}},
+					{TagName: "v1.2.0", Assets: []ReleaseAsset{{Name: "app-linux-amd64", DownloadURL: "http://example.com/stable"}}},
+					{TagName: "v1.1.0", Assets: []ReleaseAsset{{Name: BlockedAssetName}}},
+				}, nil
+			},
+		}
+	}
+
+	var applied []string
+	DoUpdate = func(url string) error {
+		applied = append(applied, url)
+		return nil
+	}
+
+	testCases := []struct {
+		name           string
+		version        string
+		allowDowngrade bool
+		expectError    error
+		expectAnyError bool
+		expectApplied  int
+	}{
+		{name: "Downgrade without permission", version: "1.2.0", expectError: ErrDowngradeNotAllowed},
+		{name: "Downgrade with permission", version: "1.2.0", allowDowngrade: true, expectApplied: 1},
+		{name: "Same version", version: "v1.3.0-beta.2"},
+		{name: "Missing release", version: "v9.9.9", expectAnyError: true},
+		{name: "Blocked release", version: "v1.1.0", allowDowngrade: true, expectAnyError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			applied = nil
+			Version = "v1.3.0-beta.2"
+			err := UpdateToVersion("owner", "repo", tc.version, tc.allowDowngrade, true, "")
+			switch {
+			case tc.expectError != nil:
+				if !errors.Is(err, tc.expectError) {
+					t.Errorf("Expected %v, got: %v", tc.expectError, err)
+				}
+			case tc.expectAnyError:
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+			case err != nil:
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if len(applied) != tc.expectApplied {
+				t.Errorf("Expected %d updates applied, got %v", tc.expectApplied, applied)
+			}
+		})
+	}
+}
+
+func TestCheckTargetVersion(t *testing.T) {
+	testCases := []struct {
+		target, current string
+		allowDowngrade  bool
+		proceed         bool
+		output          string
+		err             error
+	}{
+		{target: "v1.3.0", current: "v1.2.0", proceed: true},
+		{target: "v1.2.0", current: "v1.2.0", output: "Current version v1.2.0 is already v1.2.0.\n"},
+		{target: "v1.1.0", current: "v1.2.0", err: ErrDowngradeNotAllowed},
+		{target: "v1.1.0", current: "v1.2.0", allowDowngrade: true, proceed: true},
+	}
+
+	for _, tc := range testCases {
+		var out strings.Builder
+		proceed, err := checkTargetVersion(&out, tc.target, tc.current, tc.allowDowngrade, true)
+		if proceed != tc.proceed || !errors.Is(err, tc.err) {
+			t.Errorf("%s over %s: expected %v, %v, got %v, %v", tc.target, tc.current, tc.proceed, tc.err, proceed, err)
+		}
+		if out.String() != tc.output {
+			t.Errorf("%s over %s: expected output %q, got %q", tc.target, tc.current, tc.output, out.String())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package updater
+
+import (
+	"fmt"
+)
+
+// Verification is the outcome of comparing an installed binary with the
+// release asset of its version.
+type Verification struct {
+	// Path is the installed binary.
+	Path string
+	// Version is the installed version.
+	Version string
+	// Asset is the file name of the release asset.
+	Asset string
+	// URL is the location of the release asset.
+	URL string
+	// Expected is the hex-encoded SHA-256 checksum of the release asset, or of
+	// the binary extracted from it if it is a release archive.
+	Expected string
+	// Actual is the hex-encoded SHA-256 checksum of the installed binary.
+	Actual string
+}
+
+// OK reports whether the installed binary matches the release asset.
+func (v *Verification) OK() bool {
+	return v.Expected == v.Actual
+}
+
+// Verify downloads the release asset of the installed version and compares it
+// with the target binary, to detect corrupted or tampered installations. For
+// generic HTTP sources, only the version in latest.json can be verified.
+func (s *UpdateService) Verify() (*Verification, error) {
+	path, err := s.TargetPath()
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.CurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := s.Releases()
+	if err != nil {
+		return nil, err
+	}
+	release := findReleaseByVersion(releases, current)
+	if release == nil {
+		return nil, fmt.Errorf("release %s not found", formatVersionForDisplay(current, s.config.ForceSemVerPrefix))
+	}
+	url, err := s.DownloadURL(release)
+	if err != nil {
+		return nil, fmt.Errorf("error getting download URL: %w", err)
+	}
+	if err := checkSourceURL(s.config.RepoURL, url); err != nil {
+		return nil, err
+	}
+
+	asset, err := dryRunDownload(s.downloader(), url, releaseChecksum(release, url), s.binaryName())
+	if err != nil {
+		return nil, err
+	}
+	actual, err := fileSHA256(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read installed binary: %w", err)
+	}
+	return &Verification{
+		Path:     path,
+		Version:  current,
+		Asset:    asset.Asset,
+		URL:      url,
+		Expected: asset.SHA256,
+		Actual:   actual,
+	}, nil
+}
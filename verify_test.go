@@ -0,0 +1,69 @@
+package updater
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateService_Verify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			fmt.Fprintf(w, `{"version": "1.2.0", "url": "http://%s/agent_linux_amd64"}`, r.Host)
+		case "/agent_linux_amd64":
+			fmt.Fprint(w, "release binary")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	originalDetectVersion := DetectVersion
+	defer func() { DetectVersion = originalDetectVersion }()
+
+	testCases := []struct {
+		name        string
+		installed   string
+		content     string
+		expectOK    bool
+		expectError bool
+	}{
+		{name: "matches", installed: "1.2.0", content: "release binary", expectOK: true},
+		{name: "modified", installed: "1.2.0", content: "tampered binary"},
+		{name: "version not offered", installed: "1.1.0", content: "release binary", expectError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := filepath.Join(t.TempDir(), "agent")
+			if err := os.WriteFile(target, []byte(tc.content), 0755); err != nil {
+				t.Fatal(err)
+			}
+			DetectVersion = func(path string) (string, error) { return tc.installed, nil }
+
+			service, err := NewUpdateService(UpdateServiceConfig{RepoURL: server.URL, TargetPath: target})
+			if err != nil {
+				t.Fatalf("NewUpdateService failed: %v", err)
+			}
+			verification, err := service.Verify()
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if verification.OK() != tc.expectOK {
+				t.Errorf("expected OK %v, got %+v", tc.expectOK, verification)
+			}
+			if verification.Path != target || verification.Version != "1.2.0" || verification.Asset != "agent_linux_amd64" {
+				t.Errorf("unexpected verification %+v", verification)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package updater
+
+import "golang.org/x/sys/unix"
+
+// canWriteFile returns an error if the current user may not write the file at
+// path. It checks permissions without opening the file, which fails for a
+// running executable on some systems.
+func canWriteFile(path string) error {
+	return unix.Access(path, unix.W_OK)
+}
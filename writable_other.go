@@ -0,0 +1,20 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package updater
+
+import (
+	"fmt"
+	"os"
+)
+
+// canWriteFile returns an error if the file at path is marked read-only.
+func canWriteFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("%s is read-only", path)
+	}
+	return nil
+}